@@ -0,0 +1,53 @@
+// Command reencrypt rewrites snapshots and append-only files with the current encryption
+// key, after a key rotation or to encrypt files written before encryption was enabled.
+// It reads the keys from the same environment variables as the server:
+//
+//	ENCRYPTION_KEYS=old:<base64>,new:<base64> reencrypt data.json appendonly.aof
+//
+// Every key a file may be encrypted with must be configured. Stop the server before
+// re-encrypting its append-only file; a running server re-encrypts it on the next
+// POST /admin/aof/rewrite instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang-memory-store/internal/persistence"
+)
+
+func main() {
+	aof := flag.Bool("aof", false, "treat every file as an append-only file, whatever its extension")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: reencrypt [-aof] file...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	keyring, err := persistence.LoadKeyring(os.Getenv("ENCRYPTION_KEYS"), os.Getenv("ENCRYPTION_KEY_FILE"), os.Getenv("ENCRYPTION_KEY_ID"))
+	if err != nil {
+		log.Fatal("Invalid encryption keys: ", err)
+	}
+	if keyring == nil {
+		log.Fatal("No encryption keys: set ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE")
+	}
+
+	for _, filename := range flag.Args() {
+		if *aof || filepath.Ext(filename) == ".aof" {
+			err = persistence.ReencryptAOF(filename, keyring)
+		} else {
+			err = persistence.ReencryptSnapshot(filename, keyring)
+		}
+		if err != nil {
+			log.Fatalf("Failed to re-encrypt %s: %v", filename, err)
+		}
+		log.Printf("Re-encrypted %s with key %q", filename, keyring.CurrentID())
+	}
+}
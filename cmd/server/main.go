@@ -2,10 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"golang-memory-store/internal/api"
@@ -19,9 +21,18 @@ import (
 var (
 	EnablePersistence = os.Getenv("ENABLE_PERSISTENCE") == "true"
 	UseDatabase       = os.Getenv("DB_TYPE") != ""
-	FILE_PATH         = "data.json"
+	AppendOnly        = os.Getenv("APPENDONLY") == "true"
+	FILE_PATH         = envOr("SNAPSHOT_FILE", "data.json")
+	AOF_PATH          = envOr("AOF_FILE", "appendonly.aof")
 )
 
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
 func initDB() {
 	dbType := os.Getenv("DB_TYPE")
 	dsn := os.Getenv("DB_DSN")
@@ -37,8 +48,104 @@ func initDB() {
 	}
 }
 
+// storeConfig reads the memory budget, eviction policy, Pub/Sub and keyspace event buffer
+// sizes, AOF rewrite thresholds and encryption keys from the environment.
+func storeConfig() core.Config {
+	var config core.Config
+
+	if maxKeys := os.Getenv("MAX_KEYS"); maxKeys != "" {
+		n, err := strconv.ParseInt(maxKeys, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid MAX_KEYS:", err)
+		}
+		config.MaxKeys = n
+	}
+
+	if maxMemory := os.Getenv("MAX_MEMORY"); maxMemory != "" {
+		n, err := strconv.ParseInt(maxMemory, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid MAX_MEMORY:", err)
+		}
+		config.MaxMemory = n
+	}
+
+	if policy := os.Getenv("EVICTION_POLICY"); policy != "" {
+		p, err := core.ParseEvictionPolicy(policy)
+		if err != nil {
+			log.Fatal("Invalid EVICTION_POLICY:", err)
+		}
+		config.EvictionPolicy = p
+	}
+
+	if buffer := os.Getenv("SUBSCRIBER_BUFFER"); buffer != "" {
+		n, err := strconv.Atoi(buffer)
+		if err != nil {
+			log.Fatal("Invalid SUBSCRIBER_BUFFER:", err)
+		}
+		config.SubscriberBuffer = n
+	}
+
+	if queue := os.Getenv("EVENT_QUEUE"); queue != "" {
+		n, err := strconv.Atoi(queue)
+		if err != nil {
+			log.Fatal("Invalid EVENT_QUEUE:", err)
+		}
+		config.EventQueue = n
+	}
+
+	if percentage := os.Getenv("AOF_REWRITE_PERCENTAGE"); percentage != "" {
+		n, err := strconv.Atoi(percentage)
+		if err != nil {
+			log.Fatal("Invalid AOF_REWRITE_PERCENTAGE:", err)
+		}
+		config.AOFRewritePercentage = n
+	}
+
+	if minSize := os.Getenv("AOF_REWRITE_MIN_SIZE"); minSize != "" {
+		n, err := strconv.ParseInt(minSize, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid AOF_REWRITE_MIN_SIZE:", err)
+		}
+		config.AOFRewriteMinSize = n
+	}
+
+	keyring, err := persistence.LoadKeyring(os.Getenv("ENCRYPTION_KEYS"), os.Getenv("ENCRYPTION_KEY_FILE"), os.Getenv("ENCRYPTION_KEY_ID"))
+	if err != nil {
+		log.Fatal("Invalid encryption keys:", err)
+	}
+	if keyring != nil && os.Getenv("ENCRYPTION_ALLOW_PLAINTEXT") == "true" {
+		keyring.AllowPlaintext(true)
+	}
+	config.Encryption = keyring
+
+	return config
+}
+
+// snapshotOptions reads the snapshot format and compression from the environment.
+func snapshotOptions() core.SaveOptions {
+	var options core.SaveOptions
+
+	if format := os.Getenv("SNAPSHOT_FORMAT"); format != "" {
+		f, err := persistence.ParseSnapshotFormat(format)
+		if err != nil {
+			log.Fatal("Invalid SNAPSHOT_FORMAT:", err)
+		}
+		options.Format = f
+	}
+
+	if compression := os.Getenv("SNAPSHOT_COMPRESSION"); compression != "" {
+		c, err := persistence.ParseCompression(compression)
+		if err != nil {
+			log.Fatal("Invalid SNAPSHOT_COMPRESSION:", err)
+		}
+		options.Compression = c
+	}
+
+	return options
+}
+
 func main() {
-	store := core.NewShardedStore()
+	store := core.NewShardedStoreWithConfig(storeConfig())
 	handler := api.NewHandler(store)
 
 	// Initialize Database if enabled
@@ -46,16 +153,51 @@ func main() {
 		initDB()
 	}
 
+	// The append-only file is the most recent copy of the data when it exists;
+	// otherwise start from the snapshot and let OpenAOF seed a new log from it.
+	_, err := os.Stat(AOF_PATH)
+	aofExists := AppendOnly && err == nil
+
 	// Load data from file or DB
-	if UseDatabase {
+	if aofExists {
+		log.Println("Append-only file found, skipping snapshot load.")
+	} else if UseDatabase {
 		err := store.LoadStoreFromDB()
 		if err != nil {
 			log.Println("Error loading from DB:", err)
 		}
 	} else if EnablePersistence {
 		err := store.LoadStoreFromFile(FILE_PATH)
-		if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
 			log.Println("No previous data found, starting fresh.")
+		} else if err != nil {
+			// Starting empty would overwrite the damaged snapshot on the next save.
+			log.Fatal("Failed to load the snapshot:", err)
+		}
+	}
+
+	// Save the snapshot in the background at the save points, not only on shutdown
+	if EnablePersistence && !UseDatabase {
+		spec, set := os.LookupEnv("SAVE_POINTS")
+		if !set {
+			spec = core.DefaultSavePoints
+		}
+		points, err := core.ParseSavePoints(spec)
+		if err != nil {
+			log.Fatal("Invalid SAVE_POINTS:", err)
+		}
+		if err := store.ScheduleSnapshots(FILE_PATH, points, snapshotOptions()); err != nil {
+			log.Fatal("Invalid snapshot options:", err)
+		}
+	}
+
+	if AppendOnly {
+		policy, err := persistence.ParseFsyncPolicy(envOr("APPENDFSYNC", string(persistence.FsyncEverySec)))
+		if err != nil {
+			log.Fatal("Invalid APPENDFSYNC:", err)
+		}
+		if err := store.OpenAOF(AOF_PATH, policy); err != nil {
+			log.Fatal("Failed to open the append-only file:", err)
 		}
 	}
 
@@ -83,8 +225,83 @@ func main() {
 	apiRouter.HandleFunc("/set", handler.Set).Methods("POST")
 	apiRouter.HandleFunc("/get/{key}", handler.Get).Methods("GET")
 	apiRouter.HandleFunc("/delete/{key}", handler.Delete).Methods("DELETE")
+	apiRouter.HandleFunc("/keys", handler.Keys).Methods("GET")
+	apiRouter.HandleFunc("/version/{key}", handler.Version).Methods("GET")
+	apiRouter.HandleFunc("/ttl/{key}", handler.TTL).Methods("GET")
+	apiRouter.HandleFunc("/expire", handler.Expire).Methods("POST")
+	apiRouter.HandleFunc("/pexpire", handler.PExpire).Methods("POST")
+	apiRouter.HandleFunc("/expireat", handler.ExpireAt).Methods("POST")
+	apiRouter.HandleFunc("/pexpireat", handler.PExpireAt).Methods("POST")
+	apiRouter.HandleFunc("/persist/{key}", handler.Persist).Methods("POST")
+	apiRouter.HandleFunc("/tx", handler.Transaction).Methods("POST")
+	apiRouter.HandleFunc("/mget", handler.MGet).Methods("POST")
+	apiRouter.HandleFunc("/mset", handler.MSet).Methods("POST")
+	apiRouter.HandleFunc("/mdel", handler.MDel).Methods("POST")
+	apiRouter.HandleFunc("/incr/{key}", handler.Incr).Methods("POST")
+	apiRouter.HandleFunc("/decr/{key}", handler.Decr).Methods("POST")
+	apiRouter.HandleFunc("/incrby", handler.IncrBy).Methods("POST")
+	apiRouter.HandleFunc("/decrby", handler.DecrBy).Methods("POST")
+	apiRouter.HandleFunc("/incrbyfloat", handler.IncrByFloat).Methods("POST")
 	apiRouter.HandleFunc("/list/push", handler.Push).Methods("POST")
 	apiRouter.HandleFunc("/list/pop/{key}", handler.Pop).Methods("POST")
+	apiRouter.HandleFunc("/list/lpush", handler.LPush).Methods("POST")
+	apiRouter.HandleFunc("/list/rpush", handler.RPush).Methods("POST")
+	apiRouter.HandleFunc("/list/lpop/{key}", handler.LPop).Methods("POST")
+	apiRouter.HandleFunc("/list/rpop/{key}", handler.RPop).Methods("POST")
+	apiRouter.HandleFunc("/list/bpop", handler.BPop).Methods("POST")
+	apiRouter.HandleFunc("/list/range/{key}", handler.LRange).Methods("GET")
+	apiRouter.HandleFunc("/list/index/{key}/{index}", handler.LIndex).Methods("GET")
+	apiRouter.HandleFunc("/list/set", handler.LSet).Methods("POST")
+	apiRouter.HandleFunc("/list/insert", handler.LInsert).Methods("POST")
+	apiRouter.HandleFunc("/list/rem", handler.LRem).Methods("POST")
+	apiRouter.HandleFunc("/list/trim", handler.LTrim).Methods("POST")
+	apiRouter.HandleFunc("/list/len/{key}", handler.LLen).Methods("GET")
+	apiRouter.HandleFunc("/hash/set", handler.HSet).Methods("POST")
+	apiRouter.HandleFunc("/hash/get/{key}/{field}", handler.HGet).Methods("GET")
+	apiRouter.HandleFunc("/hash/mget", handler.HMGet).Methods("POST")
+	apiRouter.HandleFunc("/hash/del", handler.HDel).Methods("POST")
+	apiRouter.HandleFunc("/hash/incrby", handler.HIncrBy).Methods("POST")
+	apiRouter.HandleFunc("/hash/getall/{key}", handler.HGetAll).Methods("GET")
+	apiRouter.HandleFunc("/hash/keys/{key}", handler.HKeys).Methods("GET")
+	apiRouter.HandleFunc("/hash/len/{key}", handler.HLen).Methods("GET")
+	apiRouter.HandleFunc("/hash/scan/{key}", handler.HScan).Methods("GET")
+	apiRouter.HandleFunc("/set/add", handler.SAdd).Methods("POST")
+	apiRouter.HandleFunc("/set/rem", handler.SRem).Methods("POST")
+	apiRouter.HandleFunc("/set/ismember/{key}/{member}", handler.SIsMember).Methods("GET")
+	apiRouter.HandleFunc("/set/members/{key}", handler.SMembers).Methods("GET")
+	apiRouter.HandleFunc("/set/card/{key}", handler.SCard).Methods("GET")
+	apiRouter.HandleFunc("/set/pop/{key}", handler.SPop).Methods("POST")
+	apiRouter.HandleFunc("/set/randmember/{key}", handler.SRandMember).Methods("GET")
+	apiRouter.HandleFunc("/set/union", handler.SUnion).Methods("POST")
+	apiRouter.HandleFunc("/set/inter", handler.SInter).Methods("POST")
+	apiRouter.HandleFunc("/set/diff", handler.SDiff).Methods("POST")
+	apiRouter.HandleFunc("/zset/add", handler.ZAdd).Methods("POST")
+	apiRouter.HandleFunc("/zset/incrby", handler.ZIncrBy).Methods("POST")
+	apiRouter.HandleFunc("/zset/score/{key}/{member}", handler.ZScore).Methods("GET")
+	apiRouter.HandleFunc("/zset/rank/{key}/{member}", handler.ZRank).Methods("GET")
+	apiRouter.HandleFunc("/zset/card/{key}", handler.ZCard).Methods("GET")
+	apiRouter.HandleFunc("/zset/range/{key}", handler.ZRange).Methods("GET")
+	apiRouter.HandleFunc("/zset/rangebyscore/{key}", handler.ZRangeByScore).Methods("GET")
+	apiRouter.HandleFunc("/zset/rangebylex/{key}", handler.ZRangeByLex).Methods("GET")
+	apiRouter.HandleFunc("/zset/rem", handler.ZRem).Methods("POST")
+	apiRouter.HandleFunc("/zset/remrangebyscore", handler.ZRemRangeByScore).Methods("POST")
+	apiRouter.HandleFunc("/zset/popmin/{key}", handler.ZPopMin).Methods("POST")
+	apiRouter.HandleFunc("/zset/popmax/{key}", handler.ZPopMax).Methods("POST")
+	apiRouter.HandleFunc("/stream/add", handler.XAdd).Methods("POST")
+	apiRouter.HandleFunc("/stream/len/{key}", handler.XLen).Methods("GET")
+	apiRouter.HandleFunc("/stream/range/{key}", handler.XRange).Methods("GET")
+	apiRouter.HandleFunc("/stream/trim", handler.XTrim).Methods("POST")
+	apiRouter.HandleFunc("/stream/read", handler.XRead).Methods("POST")
+	apiRouter.HandleFunc("/stream/group/create", handler.XGroupCreate).Methods("POST")
+	apiRouter.HandleFunc("/stream/readgroup", handler.XReadGroup).Methods("POST")
+	apiRouter.HandleFunc("/stream/ack", handler.XAck).Methods("POST")
+	apiRouter.HandleFunc("/stream/pending/{key}/{group}", handler.XPending).Methods("GET")
+	apiRouter.HandleFunc("/stream/claim", handler.XClaim).Methods("POST")
+	apiRouter.HandleFunc("/publish", handler.Publish).Methods("POST")
+	apiRouter.HandleFunc("/subscribe", handler.Subscribe).Methods("GET")
+	apiRouter.HandleFunc("/events", handler.Events).Methods("GET")
+	apiRouter.HandleFunc("/stats", handler.Stats).Methods("GET")
+	apiRouter.HandleFunc("/admin/aof/rewrite", handler.RewriteAOF).Methods("POST")
 
 	// Start the server asynchronously
 	go func() {
@@ -104,10 +321,11 @@ func main() {
 
 	// Save data on shutdown
 	if UseDatabase {
-		store.SaveStoreToDBAsync()
+		store.SaveStoreToDB()
 	} else if EnablePersistence {
-		store.SaveStoreToFileAsync(FILE_PATH)
+		store.SaveStoreToFileWithOptions(FILE_PATH, snapshotOptions())
 	}
+	store.Close()
 
 	log.Println("Data saved successfully. Goodbye!")
 }
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) Incr(w http.ResponseWriter, r *http.Request) {
+	value, err := h.store.Incr(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) Decr(w http.ResponseWriter, r *http.Request) {
+	value, err := h.store.Decr(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) IncrBy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string `json:"key"`
+		Delta int64  `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Delta must be an integer", http.StatusBadRequest)
+		return
+	}
+	value, err := h.store.IncrBy(req.Key, req.Delta)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) DecrBy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string `json:"key"`
+		Delta int64  `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Delta must be an integer", http.StatusBadRequest)
+		return
+	}
+	value, err := h.store.DecrBy(req.Key, req.Delta)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) IncrByFloat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string  `json:"key"`
+		Delta float64 `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Delta must be a number", http.StatusBadRequest)
+		return
+	}
+	value, err := h.store.IncrByFloat(req.Key, req.Delta)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
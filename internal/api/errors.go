@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"golang-memory-store/internal/core"
+	"net/http"
+)
+
+// writeError maps errors returned by the core store to HTTP status codes
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrOutOfMemory):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case errors.Is(err, core.ErrWrongType):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, core.ErrNoSuchKey),
+		errors.Is(err, core.ErrNoGroup):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrIndexOutOfRange),
+		errors.Is(err, core.ErrInvalidOptions),
+		errors.Is(err, core.ErrInvalidCursor),
+		errors.Is(err, core.ErrInvalidRange),
+		errors.Is(err, core.ErrInvalidZAddOptions),
+		errors.Is(err, core.ErrInvalidStreamID),
+		errors.Is(err, core.ErrStreamIDTooSmall),
+		errors.Is(err, core.ErrBusyGroup),
+		errors.Is(err, core.ErrAOFDisabled),
+		errors.Is(err, core.ErrNotInteger),
+		errors.Is(err, core.ErrNotFloat),
+		errors.Is(err, core.ErrOverflow):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang-memory-store/internal/core"
+	"net/http"
+	"sync/atomic"
+)
+
+// Events streams keyspace events as Server-Sent Events, filtered by the optional key
+// pattern and event types. Like Pub/Sub subscribers, a client that reads too slowly
+// loses events and the next one it receives carries the number of dropped events. The
+// stream ends when the client goes away or the store is closed.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := core.EventFilter{Pattern: query.Get("pattern")}
+	for _, typ := range query["type"] {
+		filter.Types = append(filter.Types, core.EventType(typ))
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan core.KeyEvent, core.DefaultSubscriberBuffer)
+	var dropped atomic.Int64
+	cancel := h.store.OnKeyEvent(filter, func(event core.KeyEvent) {
+		select {
+		case events <- event:
+		default:
+			dropped.Add(1)
+		}
+	})
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": subscribed\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.store.Done():
+			return
+		case event := <-events:
+			event.Dropped += dropped.Swap(0)
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
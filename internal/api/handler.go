@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"golang-memory-store/internal/core"
 	"net/http"
 
@@ -16,24 +17,55 @@ func NewHandler(store *core.ShardedStore) *Handler {
 	return &Handler{store: store}
 }
 
+// Set writes a value. The optional nx, xx and version fields make the write
+// conditional and get returns the previous value; the response tells whether
+// the value was written and its new version.
 func (h *Handler) Set(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Key   string      `json:"key"`
-		Value interface{} `json:"value"`
-		TTL   int         `json:"ttl"`
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		TTL     int         `json:"ttl"`
+		NX      bool        `json:"nx"`
+		XX      bool        `json:"xx"`
+		Get     bool        `json:"get"`
+		Version *uint64     `json:"version"`
 	}
 	json.NewDecoder(r.Body).Decode(&req)
-	h.store.Set(req.Key, req.Value, req.TTL)
-	w.WriteHeader(http.StatusOK)
+	result, err := h.store.SetWithOptions(req.Key, req.Value, core.SetOptions{
+		TTL:       req.TTL,
+		NX:        req.NX,
+		XX:        req.XX,
+		Get:       req.Get,
+		IfVersion: req.Version,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{"written": result.Written, "version": result.Version}
+	if req.Get {
+		resp["old"] = result.Old
+		resp["found"] = result.HadOld
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	key := mux.Vars(r)["key"]
-	value, found := h.store.Get(key)
+	value, version, found, err := h.store.GetWithVersion(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	if !found {
 		http.Error(w, "Key not found", http.StatusNotFound)
 		return
 	}
+	if r.URL.Query().Get("withVersion") == "true" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": value, "version": version})
+		return
+	}
 	json.NewEncoder(w).Encode(value)
 }
 
@@ -49,18 +81,38 @@ func (h *Handler) Push(w http.ResponseWriter, r *http.Request) {
 		Value interface{} `json:"value"`
 	}
 	json.NewDecoder(r.Body).Decode(&req)
-	list := h.store.GetList(req.Key)
-	list.Push(req.Value)
+	if _, err := h.store.RPush(req.Key, req.Value); err != nil {
+		writeError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) Pop(w http.ResponseWriter, r *http.Request) {
 	key := mux.Vars(r)["key"]
-	list := h.store.GetList(key)
-	value, found := list.Pop()
+	value, found, err := h.store.RPop(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	if !found {
 		http.Error(w, "No items in list", http.StatusNotFound)
 		return
 	}
 	json.NewEncoder(w).Encode(value)
 }
+
+func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(h.store.Stats())
+}
+
+// RewriteAOF starts compacting the append-only file in the background. started is false
+// when a rewrite is already running.
+func (h *Handler) RewriteAOF(w http.ResponseWriter, r *http.Request) {
+	err := h.store.BackgroundRewriteAOF()
+	if err != nil && !errors.Is(err, core.ErrAOFRewriteInProgress) {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"started": err == nil})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"golang-memory-store/internal/core"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type hashFieldsRequest struct {
+	Key    string   `json:"key"`
+	Fields []string `json:"fields"`
+}
+
+func (h *Handler) HSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key    string                 `json:"key"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	added, err := h.store.HSet(req.Key, req.Fields)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(added)
+}
+
+func (h *Handler) HGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	value, found, err := h.store.HGet(vars["key"], vars["field"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Field not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) HMGet(w http.ResponseWriter, r *http.Request) {
+	var req hashFieldsRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	values, err := h.store.HMGet(req.Key, req.Fields...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(values)
+}
+
+func (h *Handler) HDel(w http.ResponseWriter, r *http.Request) {
+	var req hashFieldsRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	removed, err := h.store.HDel(req.Key, req.Fields...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) HIncrBy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string `json:"key"`
+		Field string `json:"field"`
+		Delta int64  `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Delta must be an integer", http.StatusBadRequest)
+		return
+	}
+	value, err := h.store.HIncrBy(req.Key, req.Field, req.Delta)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) HGetAll(w http.ResponseWriter, r *http.Request) {
+	fields, err := h.store.HGetAll(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(fields)
+}
+
+func (h *Handler) HKeys(w http.ResponseWriter, r *http.Request) {
+	fields, err := h.store.HKeys(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(fields)
+}
+
+func (h *Handler) HLen(w http.ResponseWriter, r *http.Request) {
+	length, err := h.store.HLen(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(length)
+}
+
+// HScan pages through the fields of a hash with a stateless cursor, like Keys does over the keyspace.
+func (h *Handler) HScan(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	count, err := queryInt(r, "count", core.DefaultScanCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cursor, fields, err := h.store.HScan(mux.Vars(r)["key"], query.Get("cursor"), core.ScanOptions{
+		Match: query.Get("match"),
+		Count: count,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"cursor": cursor, "fields": fields})
+}
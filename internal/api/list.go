@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pushRequest accepts a single "value" or several "values" to push
+type pushRequest struct {
+	Key    string        `json:"key"`
+	Value  interface{}   `json:"value"`
+	Values []interface{} `json:"values"`
+}
+
+func (req pushRequest) items() []interface{} {
+	if len(req.Values) > 0 {
+		return req.Values
+	}
+	return []interface{}{req.Value}
+}
+
+func (h *Handler) LPush(w http.ResponseWriter, r *http.Request) {
+	h.push(w, r, true)
+}
+
+func (h *Handler) RPush(w http.ResponseWriter, r *http.Request) {
+	h.push(w, r, false)
+}
+
+func (h *Handler) push(w http.ResponseWriter, r *http.Request, left bool) {
+	var req pushRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	var length int
+	var err error
+	if left {
+		length, err = h.store.LPush(req.Key, req.items()...)
+	} else {
+		length, err = h.store.RPush(req.Key, req.items()...)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(length)
+}
+
+// BPop long-polls for an item on the first non-empty list among the requested keys.
+// A timeout of zero waits until the client disconnects.
+func (h *Handler) BPop(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys      []string `json:"keys"`
+		Timeout   float64  `json:"timeout"`
+		Direction string   `json:"direction"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if len(req.Keys) == 0 {
+		http.Error(w, "At least one key is required", http.StatusBadRequest)
+		return
+	}
+	if req.Direction != "" && req.Direction != "left" && req.Direction != "right" {
+		http.Error(w, "Direction must be 'left' or 'right'", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout*float64(time.Second)))
+		defer cancel()
+	}
+
+	var key string
+	var value interface{}
+	var err error
+	if req.Direction == "right" {
+		key, value, err = h.store.BRPop(ctx, req.Keys...)
+	} else {
+		key, value, err = h.store.BLPop(ctx, req.Keys...)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Timed out waiting for items", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// The client went away; there is nobody left to answer.
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "value": value})
+}
+
+func (h *Handler) LPop(w http.ResponseWriter, r *http.Request) {
+	h.pop(w, r, true)
+}
+
+func (h *Handler) RPop(w http.ResponseWriter, r *http.Request) {
+	h.pop(w, r, false)
+}
+
+func (h *Handler) pop(w http.ResponseWriter, r *http.Request, left bool) {
+	key := mux.Vars(r)["key"]
+	var value interface{}
+	var found bool
+	var err error
+	if left {
+		value, found, err = h.store.LPop(key)
+	} else {
+		value, found, err = h.store.RPop(key)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "No items in list", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) LRange(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	start, err := queryInt(r, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stop, err := queryInt(r, "stop", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values, err := h.store.LRange(key, start, stop)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(values)
+}
+
+func (h *Handler) LIndex(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		http.Error(w, "Invalid index", http.StatusBadRequest)
+		return
+	}
+
+	value, found, err := h.store.LIndex(vars["key"], index)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Index out of range", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+func (h *Handler) LSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string      `json:"key"`
+		Index int         `json:"index"`
+		Value interface{} `json:"value"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if err := h.store.LSet(req.Key, req.Index, req.Value); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) LInsert(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key      string      `json:"key"`
+		Position string      `json:"position"`
+		Pivot    interface{} `json:"pivot"`
+		Value    interface{} `json:"value"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Position != "before" && req.Position != "after" {
+		http.Error(w, "Position must be 'before' or 'after'", http.StatusBadRequest)
+		return
+	}
+
+	length, err := h.store.LInsert(req.Key, req.Position == "before", req.Pivot, req.Value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(length)
+}
+
+func (h *Handler) LRem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string      `json:"key"`
+		Count int         `json:"count"`
+		Value interface{} `json:"value"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	removed, err := h.store.LRem(req.Key, req.Count, req.Value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) LTrim(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string `json:"key"`
+		Start int    `json:"start"`
+		Stop  int    `json:"stop"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if err := h.store.LTrim(req.Key, req.Start, req.Stop); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) LLen(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	length, err := h.store.LLen(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(length)
+}
+
+// queryInt parses an integer query parameter, falling back to def when it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (h *Handler) MGet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	json.NewEncoder(w).Encode(h.store.MGet(req.Keys...))
+}
+
+func (h *Handler) MSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Entries map[string]interface{} `json:"entries"`
+		TTL     int                    `json:"ttl"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if err := h.store.MSet(req.Entries, req.TTL); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) MDel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	json.NewEncoder(w).Encode(h.store.MDel(req.Keys...))
+}
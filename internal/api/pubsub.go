@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Channel string      `json:"channel"`
+		Message interface{} `json:"message"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Channel == "" {
+		http.Error(w, "Channel is required", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(h.store.Publish(req.Channel, req.Message))
+}
+
+// Subscribe streams the messages of the requested channels and patterns as
+// Server-Sent Events until the client disconnects or the server shuts down.
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	channels, patterns := query["channel"], query["pattern"]
+	if len(channels) == 0 && len(patterns) == 0 {
+		http.Error(w, "At least one channel or pattern is required", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := h.store.Subscribe(channels, patterns)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": subscribed\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, open := <-sub.Messages():
+			if !open {
+				return
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"encoding/json"
+	"golang-memory-store/internal/core"
+	"net/http"
+)
+
+// Keys pages through the keyspace with a stateless cursor. Start with cursor=0 and
+// repeat with the returned cursor until it comes back as "0".
+func (h *Handler) Keys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	count, err := queryInt(r, "count", core.DefaultScanCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cursor, keys, err := h.store.Scan(query.Get("cursor"), core.ScanOptions{
+		Match: query.Get("match"),
+		Type:  core.ValueType(query.Get("type")),
+		Count: count,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"cursor": cursor, "keys": keys})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type membersRequest struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+}
+
+type setAlgebraRequest struct {
+	Keys        []string `json:"keys"`
+	Destination string   `json:"destination"`
+}
+
+func (h *Handler) SAdd(w http.ResponseWriter, r *http.Request) {
+	var req membersRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	added, err := h.store.SAdd(req.Key, req.Members...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(added)
+}
+
+func (h *Handler) SRem(w http.ResponseWriter, r *http.Request) {
+	var req membersRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	removed, err := h.store.SRem(req.Key, req.Members...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) SIsMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	found, err := h.store.SIsMember(vars["key"], vars["member"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(found)
+}
+
+func (h *Handler) SMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := h.store.SMembers(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+func (h *Handler) SCard(w http.ResponseWriter, r *http.Request) {
+	card, err := h.store.SCard(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *Handler) SPop(w http.ResponseWriter, r *http.Request) {
+	count, err := queryInt(r, "count", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := h.store.SPop(mux.Vars(r)["key"], count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+func (h *Handler) SRandMember(w http.ResponseWriter, r *http.Request) {
+	count, err := queryInt(r, "count", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := h.store.SRandMember(mux.Vars(r)["key"], count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+// SUnion, SInter and SDiff return the combined members, or store them in the
+// optional destination and return its size.
+func (h *Handler) SUnion(w http.ResponseWriter, r *http.Request) {
+	h.combineSets(w, r, h.store.SUnion, h.store.SUnionStore)
+}
+
+func (h *Handler) SInter(w http.ResponseWriter, r *http.Request) {
+	h.combineSets(w, r, h.store.SInter, h.store.SInterStore)
+}
+
+func (h *Handler) SDiff(w http.ResponseWriter, r *http.Request) {
+	h.combineSets(w, r, h.store.SDiff, h.store.SDiffStore)
+}
+
+func (h *Handler) combineSets(w http.ResponseWriter, r *http.Request,
+	combine func(...string) ([]string, error), store func(string, ...string) (int, error)) {
+	var req setAlgebraRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	var result interface{}
+	var err error
+	if req.Destination != "" {
+		result, err = store(req.Destination, req.Keys...)
+	} else {
+		result, err = combine(req.Keys...)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golang-memory-store/internal/core"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// streamReadRequest is shared by XRead and XReadGroup. Block waits for new entries,
+// for at most Timeout seconds when it is positive.
+type streamReadRequest struct {
+	Keys    []string `json:"keys"`
+	IDs     []string `json:"ids"`
+	Count   int      `json:"count"`
+	Block   bool     `json:"block"`
+	Timeout float64  `json:"timeout"`
+}
+
+func (h *Handler) XAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key    string                 `json:"key"`
+		ID     string                 `json:"id"`
+		Fields map[string]interface{} `json:"fields"`
+		MaxLen int                    `json:"maxlen"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	id, err := h.store.XAdd(req.Key, req.Fields, core.XAddOptions{ID: req.ID, MaxLen: req.MaxLen})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(id)
+}
+
+func (h *Handler) XLen(w http.ResponseWriter, r *http.Request) {
+	length, err := h.store.XLen(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(length)
+}
+
+// XRange returns the entries between the start and end IDs, "-" and "+" by default.
+func (h *Handler) XRange(w http.ResponseWriter, r *http.Request) {
+	count, err := queryInt(r, "count", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := h.store.XRange(mux.Vars(r)["key"], queryDefault(r, "start", "-"), queryDefault(r, "end", "+"), count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (h *Handler) XTrim(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key    string `json:"key"`
+		MaxLen int    `json:"maxlen"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	removed, err := h.store.XTrim(req.Key, req.MaxLen)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) XRead(w http.ResponseWriter, r *http.Request) {
+	var req streamReadRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	h.streamRead(w, r, req.Timeout, func(ctx context.Context) (map[string][]core.StreamEntry, error) {
+		return h.store.XRead(ctx, req.Keys, req.IDs, core.XReadOptions{Count: req.Count, Block: req.Block})
+	})
+}
+
+func (h *Handler) XGroupCreate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key      string `json:"key"`
+		Group    string `json:"group"`
+		Start    string `json:"start"`
+		MkStream bool   `json:"mkstream"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Start == "" {
+		req.Start = "$"
+	}
+	if err := h.store.XGroupCreate(req.Key, req.Group, req.Start, req.MkStream); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) XReadGroup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		streamReadRequest
+		Group    string `json:"group"`
+		Consumer string `json:"consumer"`
+		NoAck    bool   `json:"noack"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	h.streamRead(w, r, req.Timeout, func(ctx context.Context) (map[string][]core.StreamEntry, error) {
+		opts := core.XReadOptions{Count: req.Count, Block: req.Block, NoAck: req.NoAck}
+		return h.store.XReadGroup(ctx, req.Group, req.Consumer, req.Keys, req.IDs, opts)
+	})
+}
+
+// streamRead answers a possibly blocking stream read. A read that times out returns no
+// streams, like a read that finds nothing new.
+func (h *Handler) streamRead(w http.ResponseWriter, r *http.Request, timeout float64, read func(context.Context) (map[string][]core.StreamEntry, error)) {
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout*float64(time.Second)))
+		defer cancel()
+	}
+
+	result, err := read(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		result, err = map[string][]core.StreamEntry{}, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		// The client went away; there is nobody left to answer.
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) XAck(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string   `json:"key"`
+		Group string   `json:"group"`
+		IDs   []string `json:"ids"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	acked, err := h.store.XAck(req.Key, req.Group, req.IDs...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(acked)
+}
+
+// XPending lists the group's pending entries between start and end, optionally for one consumer.
+func (h *Handler) XPending(w http.ResponseWriter, r *http.Request) {
+	count, err := queryInt(r, "count", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	vars := mux.Vars(r)
+	pending, err := h.store.XPending(vars["key"], vars["group"],
+		queryDefault(r, "start", "-"), queryDefault(r, "end", "+"), count, r.URL.Query().Get("consumer"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(pending)
+}
+
+// XClaim transfers pending entries idle for at least min_idle milliseconds to consumer.
+func (h *Handler) XClaim(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key      string   `json:"key"`
+		Group    string   `json:"group"`
+		Consumer string   `json:"consumer"`
+		MinIdle  int64    `json:"min_idle"`
+		IDs      []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "min_idle must be an integer number of milliseconds", http.StatusBadRequest)
+		return
+	}
+	claimed, err := h.store.XClaim(req.Key, req.Group, req.Consumer, time.Duration(req.MinIdle)*time.Millisecond, req.IDs...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(claimed)
+}
+
+func queryDefault(r *http.Request, name, def string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	return def
+}
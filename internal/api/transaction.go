@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-memory-store/internal/core"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// txCommand is one entry of the ordered command array accepted by /tx
+type txCommand struct {
+	Op     string        `json:"op"`
+	Key    string        `json:"key"`
+	Value  interface{}   `json:"value"`
+	Values []interface{} `json:"values"`
+	TTL    int           `json:"ttl"`
+	Delta  int64         `json:"delta"`
+}
+
+// Transaction executes the commands atomically. It is aborted, without running any
+// command, when one of the watched keys is no longer at the given version.
+func (h *Handler) Transaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Watch    map[string]uint64 `json:"watch"`
+		Commands []txCommand       `json:"commands"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid transaction body", http.StatusBadRequest)
+		return
+	}
+
+	tx := h.store.Multi()
+	for key, version := range req.Watch {
+		tx.WatchVersion(key, version)
+	}
+	for i, cmd := range req.Commands {
+		switch cmd.Op {
+		case "set":
+			tx.Set(cmd.Key, cmd.Value, cmd.TTL)
+		case "del":
+			tx.Delete(cmd.Key)
+		case "lpush":
+			tx.LPush(cmd.Key, cmd.Values...)
+		case "rpush":
+			tx.RPush(cmd.Key, cmd.Values...)
+		case "incr":
+			tx.IncrBy(cmd.Key, 1)
+		case "decr":
+			tx.IncrBy(cmd.Key, -1)
+		case "incrby":
+			tx.IncrBy(cmd.Key, cmd.Delta)
+		default:
+			http.Error(w, fmt.Sprintf("Unknown op %q in command %d", cmd.Op, i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := tx.Exec()
+	if errors.Is(err, core.ErrTxAborted) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"committed": false})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	encoded := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		encoded[i] = map[string]interface{}{"value": result.Value}
+		if result.Err != nil {
+			encoded[i]["error"] = result.Err.Error()
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"committed": true, "results": encoded})
+}
+
+// Version returns the current version of a key of any type, 0 when it does not exist
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(h.store.Version(mux.Vars(r)["key"]))
+}
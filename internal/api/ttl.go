@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type expireRequest struct {
+	Key string `json:"key"`
+	TTL int64  `json:"ttl"`
+}
+
+type expireAtRequest struct {
+	Key string `json:"key"`
+	At  int64  `json:"at"`
+}
+
+// TTL returns the remaining time to live in seconds and milliseconds; both are -1 for a key without TTL.
+func (h *Handler) TTL(w http.ResponseWriter, r *http.Request) {
+	ttl, found := h.store.TTL(mux.Vars(r)["key"])
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	resp := map[string]int64{"ttl": -1, "pttl": -1}
+	if ttl >= 0 {
+		resp["ttl"] = int64((ttl + time.Second/2) / time.Second)
+		resp["pttl"] = ttl.Milliseconds()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Expire sets a TTL in seconds
+func (h *Handler) Expire(w http.ResponseWriter, r *http.Request) {
+	h.expire(w, r, time.Second)
+}
+
+// PExpire sets a TTL in milliseconds
+func (h *Handler) PExpire(w http.ResponseWriter, r *http.Request) {
+	h.expire(w, r, time.Millisecond)
+}
+
+func (h *Handler) expire(w http.ResponseWriter, r *http.Request, unit time.Duration) {
+	var req expireRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "TTL must be an integer", http.StatusBadRequest)
+		return
+	}
+	if !inDurationRange(req.TTL, unit) {
+		http.Error(w, "TTL is out of range", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(h.store.Expire(req.Key, time.Duration(req.TTL)*unit))
+}
+
+// ExpireAt makes a key expire at a Unix timestamp in seconds
+func (h *Handler) ExpireAt(w http.ResponseWriter, r *http.Request) {
+	h.expireAt(w, r, time.Second)
+}
+
+// PExpireAt makes a key expire at a Unix timestamp in milliseconds
+func (h *Handler) PExpireAt(w http.ResponseWriter, r *http.Request) {
+	h.expireAt(w, r, time.Millisecond)
+}
+
+func (h *Handler) expireAt(w http.ResponseWriter, r *http.Request, unit time.Duration) {
+	var req expireAtRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Timestamp must be an integer", http.StatusBadRequest)
+		return
+	}
+	if !inDurationRange(req.At, unit) {
+		http.Error(w, "Timestamp is out of range", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(h.store.ExpireAt(req.Key, time.Unix(0, req.At*int64(unit))))
+}
+
+// inDurationRange reports whether n units fit in a time.Duration, so that converting
+// them cannot wrap around to a time in the past
+func inDurationRange(n int64, unit time.Duration) bool {
+	limit := math.MaxInt64 / int64(unit)
+	return n <= limit && n >= -limit
+}
+
+// Persist removes the TTL of a key
+func (h *Handler) Persist(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(h.store.Persist(mux.Vars(r)["key"]))
+}
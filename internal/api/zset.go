@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"golang-memory-store/internal/core"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) ZAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key     string         `json:"key"`
+		Members []core.ZMember `json:"members"`
+		NX      bool           `json:"nx"`
+		XX      bool           `json:"xx"`
+		GT      bool           `json:"gt"`
+		LT      bool           `json:"lt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Scores must be numbers", http.StatusBadRequest)
+		return
+	}
+	added, err := h.store.ZAdd(req.Key, req.Members, core.ZAddOptions{NX: req.NX, XX: req.XX, GT: req.GT, LT: req.LT})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(added)
+}
+
+func (h *Handler) ZIncrBy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key    string  `json:"key"`
+		Member string  `json:"member"`
+		Delta  float64 `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Delta must be a number", http.StatusBadRequest)
+		return
+	}
+	score, err := h.store.ZIncrBy(req.Key, req.Member, req.Delta)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(score)
+}
+
+func (h *Handler) ZScore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	score, found, err := h.store.ZScore(vars["key"], vars["member"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(score)
+}
+
+// ZRank returns the 0-based rank of a member; rev=true ranks by descending score.
+func (h *Handler) ZRank(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rank, found, err := h.store.ZRank(vars["key"], vars["member"], r.URL.Query().Get("rev") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(rank)
+}
+
+func (h *Handler) ZCard(w http.ResponseWriter, r *http.Request) {
+	card, err := h.store.ZCard(mux.Vars(r)["key"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(card)
+}
+
+// ZRange returns members by rank; rev=true orders them by descending score.
+func (h *Handler) ZRange(w http.ResponseWriter, r *http.Request) {
+	start, err := queryInt(r, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stop, err := queryInt(r, "stop", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := h.store.ZRange(mux.Vars(r)["key"], start, stop, r.URL.Query().Get("rev") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+// ZRangeByScore returns members with a score between min and max, e.g. min=(10&max=+inf.
+func (h *Handler) ZRangeByScore(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	scores, err := core.ParseScoreRange(query.Get("min"), query.Get("max"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	offset, count, err := queryLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := h.store.ZRangeByScore(mux.Vars(r)["key"], scores, offset, count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+// ZRangeByLex returns members between min and max lexically, e.g. min=[a&max=(c or min=-&max=+.
+func (h *Handler) ZRangeByLex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lex, err := core.ParseLexRange(query.Get("min"), query.Get("max"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	offset, count, err := queryLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := h.store.ZRangeByLex(mux.Vars(r)["key"], lex, offset, count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+func (h *Handler) ZRem(w http.ResponseWriter, r *http.Request) {
+	var req membersRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	removed, err := h.store.ZRem(req.Key, req.Members...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) ZRemRangeByScore(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key string `json:"key"`
+		Min string `json:"min"`
+		Max string `json:"max"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	scores, err := core.ParseScoreRange(req.Min, req.Max)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	removed, err := h.store.ZRemRangeByScore(req.Key, scores)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(removed)
+}
+
+func (h *Handler) ZPopMin(w http.ResponseWriter, r *http.Request) {
+	h.zpop(w, r, h.store.ZPopMin)
+}
+
+func (h *Handler) ZPopMax(w http.ResponseWriter, r *http.Request) {
+	h.zpop(w, r, h.store.ZPopMax)
+}
+
+func (h *Handler) zpop(w http.ResponseWriter, r *http.Request, pop func(string, int) ([]core.ZMember, error)) {
+	count, err := queryInt(r, "count", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, err := pop(mux.Vars(r)["key"], count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+// queryLimit parses the optional offset and count query parameters; count defaults to all
+func queryLimit(r *http.Request) (int, int, error) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := queryInt(r, "count", -1)
+	return offset, count, err
+}
@@ -2,10 +2,23 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the key, element or index does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrWrongType is returned when an operation targets a key holding another kind of value
+	ErrWrongType = errors.New("wrong type")
 )
 
 type Client struct {
@@ -13,6 +26,40 @@ type Client struct {
 	Token   string
 }
 
+// Stats mirrors the server's key count, memory estimate and eviction counters
+type Stats struct {
+	Keys           int64         `json:"keys"`
+	UsedMemory     int64         `json:"used_memory"`
+	MaxKeys        int64         `json:"max_keys"`
+	MaxMemory      int64         `json:"max_memory"`
+	EvictionPolicy string        `json:"eviction_policy"`
+	EvictedKeys    int64         `json:"evicted_keys"`
+	ExpiredKeys    int64         `json:"expired_keys"`
+	Snapshot       SnapshotStats `json:"snapshot"`
+	AOF            *AOFStats     `json:"aof,omitempty"` // nil when the append-only file is disabled
+}
+
+// SnapshotStats mirrors the outcome of the server's last snapshot save
+type SnapshotStats struct {
+	ChangesSinceSave int64     `json:"changes_since_save"`
+	SaveInProgress   bool      `json:"save_in_progress"`
+	Saves            int64     `json:"saves"`
+	LastSave         time.Time `json:"last_save"`
+	LastSaveDuration int64     `json:"last_save_ms"`
+	LastSaveStatus   string    `json:"last_save_status,omitempty"`
+	LastSaveError    string    `json:"last_save_error,omitempty"`
+}
+
+// AOFStats mirrors the size of the server's append-only file and the state of its rewrites
+type AOFStats struct {
+	Size                int64     `json:"size"`
+	RewriteInProgress   bool      `json:"rewrite_in_progress"`
+	Rewrites            int64     `json:"rewrites"`
+	LastRewrite         time.Time `json:"last_rewrite"`
+	LastRewriteDuration int64     `json:"last_rewrite_ms"`
+	LastRewriteError    string    `json:"last_rewrite_error,omitempty"`
+}
+
 func NewClient(baseURL, username string) (*Client, error) {
 	client := &Client{BaseURL: baseURL}
 
@@ -138,3 +185,86 @@ func (c *Client) Pop(key string) (interface{}, error) {
 
 	return result, nil
 }
+
+func (c *Client) Stats() (*Stats, error) {
+	url := fmt.Sprintf("%s/stats", c.BaseURL)
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get stats: %s", resp.Status)
+	}
+
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// RewriteAOF starts compacting the server's append-only file in the background. It
+// returns false when a rewrite is already running.
+func (c *Client) RewriteAOF() (bool, error) {
+	var result struct {
+		Started bool `json:"started"`
+	}
+	if err := c.do("POST", "/admin/aof/rewrite", nil, &result); err != nil {
+		return false, err
+	}
+	return result.Started, nil
+}
+
+// do sends an authenticated request with an optional JSON payload and decodes
+// the JSON response into result when it is non-nil.
+func (c *Client) do(method, path string, payload interface{}, result interface{}) error {
+	return c.doContext(context.Background(), method, path, payload, result)
+}
+
+// doContext is like do but aborts the request when ctx is done.
+func (c *Client) doContext(ctx context.Context, method, path string, payload interface{}, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSpace(string(message)))
+		case http.StatusConflict:
+			return fmt.Errorf("%w: %s", ErrWrongType, strings.TrimSpace(string(message)))
+		}
+		return fmt.Errorf("%s %s failed: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"net/url"
+)
+
+// setResult mirrors the response of /set
+type setResult struct {
+	Written bool        `json:"written"`
+	Version uint64      `json:"version"`
+	Old     interface{} `json:"old"`
+	Found   bool        `json:"found"`
+}
+
+func (c *Client) setWithOptions(key string, value interface{}, ttl int, options map[string]interface{}) (*setResult, error) {
+	body := map[string]interface{}{"key": key, "value": value, "ttl": ttl}
+	for name, option := range options {
+		body[name] = option
+	}
+	var result setResult
+	if err := c.do("POST", "/set", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetNX writes value only if key does not exist and reports whether it was written
+func (c *Client) SetNX(key string, value interface{}, ttl int) (bool, error) {
+	result, err := c.setWithOptions(key, value, ttl, map[string]interface{}{"nx": true})
+	if err != nil {
+		return false, err
+	}
+	return result.Written, nil
+}
+
+// SetXX writes value only if key already exists and reports whether it was written
+func (c *Client) SetXX(key string, value interface{}, ttl int) (bool, error) {
+	result, err := c.setWithOptions(key, value, ttl, map[string]interface{}{"xx": true})
+	if err != nil {
+		return false, err
+	}
+	return result.Written, nil
+}
+
+// GetSet writes value and returns the previous value, or nil when the key did not exist
+func (c *Client) GetSet(key string, value interface{}, ttl int) (interface{}, error) {
+	result, err := c.setWithOptions(key, value, ttl, map[string]interface{}{"get": true})
+	if err != nil {
+		return nil, err
+	}
+	return result.Old, nil
+}
+
+// CompareAndSwap writes value only if key is still at version, as returned by GetWithVersion.
+// A version of 0 expects the key not to exist.
+func (c *Client) CompareAndSwap(key string, version uint64, value interface{}, ttl int) (bool, error) {
+	result, err := c.setWithOptions(key, value, ttl, map[string]interface{}{"version": version})
+	if err != nil {
+		return false, err
+	}
+	return result.Written, nil
+}
+
+// GetWithVersion returns the value stored at key together with its version
+func (c *Client) GetWithVersion(key string) (interface{}, uint64, error) {
+	var result struct {
+		Value   interface{} `json:"value"`
+		Version uint64      `json:"version"`
+	}
+	err := c.do("GET", "/get/"+url.PathEscape(key)+"?withVersion=true", nil, &result)
+	return result.Value, result.Version, err
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"net/url"
+)
+
+// Incr atomically adds one to the counter at key and returns the new value
+func (c *Client) Incr(key string) (int64, error) {
+	var value int64
+	err := c.do("POST", "/incr/"+url.PathEscape(key), nil, &value)
+	return value, err
+}
+
+// Decr atomically subtracts one from the counter at key and returns the new value
+func (c *Client) Decr(key string) (int64, error) {
+	var value int64
+	err := c.do("POST", "/decr/"+url.PathEscape(key), nil, &value)
+	return value, err
+}
+
+// IncrBy atomically adds delta to the counter at key and returns the new value
+func (c *Client) IncrBy(key string, delta int64) (int64, error) {
+	var value int64
+	err := c.do("POST", "/incrby", map[string]interface{}{"key": key, "delta": delta}, &value)
+	return value, err
+}
+
+// DecrBy atomically subtracts delta from the counter at key and returns the new value
+func (c *Client) DecrBy(key string, delta int64) (int64, error) {
+	var value int64
+	err := c.do("POST", "/decrby", map[string]interface{}{"key": key, "delta": delta}, &value)
+	return value, err
+}
+
+// IncrByFloat atomically adds delta to the number at key and returns the new value
+func (c *Client) IncrByFloat(key string, delta float64) (float64, error) {
+	var value float64
+	err := c.do("POST", "/incrbyfloat", map[string]interface{}{"key": key, "delta": delta}, &value)
+	return value, err
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// KeyEvent reports a change to a key, such as "set", "del", "expired" or a command
+// name like "lpush". Dropped counts the events the server discarded for this stream,
+// because it was reading too slowly, since the previous event.
+type KeyEvent struct {
+	Key     string    `json:"key"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Dropped int64     `json:"dropped,omitempty"`
+}
+
+// KeyEvents streams keyspace events for keys matching a glob pattern ("" matches every
+// key) and, when types are given, of those types only. The returned channel is closed
+// when ctx is done or the connection ends.
+func (c *Client) KeyEvents(ctx context.Context, pattern string, types ...string) (<-chan KeyEvent, error) {
+	query := url.Values{"type": types}
+	if pattern != "" {
+		query.Set("pattern", pattern)
+	}
+	return stream[KeyEvent](ctx, c, "/events?"+query.Encode())
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// HSet writes fields into the hash at key and returns how many were added rather than updated
+func (c *Client) HSet(key string, fields map[string]interface{}) (int, error) {
+	var added int
+	err := c.do("POST", "/hash/set", map[string]interface{}{"key": key, "fields": fields}, &added)
+	return added, err
+}
+
+// HGet returns the value of field, or ErrNotFound when the key or field does not exist
+func (c *Client) HGet(key, field string) (interface{}, error) {
+	var value interface{}
+	err := c.do("GET", "/hash/get/"+url.PathEscape(key)+"/"+url.PathEscape(field), nil, &value)
+	return value, err
+}
+
+// HMGet returns the values of fields in order, with nil for missing fields
+func (c *Client) HMGet(key string, fields ...string) ([]interface{}, error) {
+	var values []interface{}
+	err := c.do("POST", "/hash/mget", map[string]interface{}{"key": key, "fields": fields}, &values)
+	return values, err
+}
+
+// HDel removes fields from the hash and returns how many existed
+func (c *Client) HDel(key string, fields ...string) (int, error) {
+	var removed int
+	err := c.do("POST", "/hash/del", map[string]interface{}{"key": key, "fields": fields}, &removed)
+	return removed, err
+}
+
+// HIncrBy atomically adds delta to the integer in field and returns the new value
+func (c *Client) HIncrBy(key, field string, delta int64) (int64, error) {
+	var value int64
+	err := c.do("POST", "/hash/incrby", map[string]interface{}{"key": key, "field": field, "delta": delta}, &value)
+	return value, err
+}
+
+// HGetAll returns all fields and values of the hash
+func (c *Client) HGetAll(key string) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	err := c.do("GET", "/hash/getall/"+url.PathEscape(key), nil, &fields)
+	return fields, err
+}
+
+// HKeys returns the field names of the hash in lexical order
+func (c *Client) HKeys(key string) ([]string, error) {
+	var fields []string
+	err := c.do("GET", "/hash/keys/"+url.PathEscape(key), nil, &fields)
+	return fields, err
+}
+
+// HLen returns the number of fields in the hash
+func (c *Client) HLen(key string) (int, error) {
+	var length int
+	err := c.do("GET", "/hash/len/"+url.PathEscape(key), nil, &length)
+	return length, err
+}
+
+// HScan returns one page of the fields matching a glob pattern and the cursor of the next page.
+// Start with cursor "0" and stop when "0" comes back.
+func (c *Client) HScan(key, cursor, match string, count int) (string, map[string]interface{}, error) {
+	query := url.Values{"cursor": {cursor}}
+	if match != "" {
+		query.Set("match", match)
+	}
+	if count > 0 {
+		query.Set("count", fmt.Sprint(count))
+	}
+	var page struct {
+		Cursor string                 `json:"cursor"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	err := c.do("GET", "/hash/scan/"+url.PathEscape(key)+"?"+query.Encode(), nil, &page)
+	return page.Cursor, page.Fields, err
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// LPush inserts values at the head of the list and returns its new length
+func (c *Client) LPush(key string, values ...interface{}) (int, error) {
+	var length int
+	err := c.do("POST", "/list/lpush", map[string]interface{}{"key": key, "values": values}, &length)
+	return length, err
+}
+
+// RPush appends values at the tail of the list and returns its new length
+func (c *Client) RPush(key string, values ...interface{}) (int, error) {
+	var length int
+	err := c.do("POST", "/list/rpush", map[string]interface{}{"key": key, "values": values}, &length)
+	return length, err
+}
+
+// LPop removes and returns the head of the list, or ErrNotFound when it is empty
+func (c *Client) LPop(key string) (interface{}, error) {
+	var value interface{}
+	err := c.do("POST", "/list/lpop/"+url.PathEscape(key), nil, &value)
+	return value, err
+}
+
+// RPop removes and returns the tail of the list, or ErrNotFound when it is empty
+func (c *Client) RPop(key string) (interface{}, error) {
+	var value interface{}
+	err := c.do("POST", "/list/rpop/"+url.PathEscape(key), nil, &value)
+	return value, err
+}
+
+// BLPop waits up to timeout for an item on the first non-empty list among keys and
+// pops its head. It returns the key the item came from, or ErrNotFound on timeout.
+// A zero timeout waits until ctx is done.
+func (c *Client) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, interface{}, error) {
+	return c.blockingPop(ctx, timeout, "left", keys)
+}
+
+// BRPop is like BLPop but pops the tail of the list.
+func (c *Client) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, interface{}, error) {
+	return c.blockingPop(ctx, timeout, "right", keys)
+}
+
+func (c *Client) blockingPop(ctx context.Context, timeout time.Duration, direction string, keys []string) (string, interface{}, error) {
+	var result struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	err := c.doContext(ctx, "POST", "/list/bpop", map[string]interface{}{
+		"keys":      keys,
+		"timeout":   timeout.Seconds(),
+		"direction": direction,
+	}, &result)
+	return result.Key, result.Value, err
+}
+
+// LRange returns the elements between start and stop inclusive; negative indexes count from the tail
+func (c *Client) LRange(key string, start, stop int) ([]interface{}, error) {
+	var values []interface{}
+	path := fmt.Sprintf("/list/range/%s?start=%d&stop=%d", url.PathEscape(key), start, stop)
+	err := c.do("GET", path, nil, &values)
+	return values, err
+}
+
+// LIndex returns the element at index, or ErrNotFound when it is out of range
+func (c *Client) LIndex(key string, index int) (interface{}, error) {
+	var value interface{}
+	err := c.do("GET", fmt.Sprintf("/list/index/%s/%d", url.PathEscape(key), index), nil, &value)
+	return value, err
+}
+
+// LSet replaces the element at index
+func (c *Client) LSet(key string, index int, value interface{}) error {
+	return c.do("POST", "/list/set", map[string]interface{}{"key": key, "index": index, "value": value}, nil)
+}
+
+// LInsert places value before or after pivot and returns the new length, or -1 when pivot is missing
+func (c *Client) LInsert(key string, before bool, pivot, value interface{}) (int, error) {
+	position := "after"
+	if before {
+		position = "before"
+	}
+	var length int
+	err := c.do("POST", "/list/insert", map[string]interface{}{
+		"key":      key,
+		"position": position,
+		"pivot":    pivot,
+		"value":    value,
+	}, &length)
+	return length, err
+}
+
+// LRem removes up to count occurrences of value and returns how many were removed
+func (c *Client) LRem(key string, count int, value interface{}) (int, error) {
+	var removed int
+	err := c.do("POST", "/list/rem", map[string]interface{}{"key": key, "count": count, "value": value}, &removed)
+	return removed, err
+}
+
+// LTrim keeps only the elements between start and stop inclusive
+func (c *Client) LTrim(key string, start, stop int) error {
+	return c.do("POST", "/list/trim", map[string]interface{}{"key": key, "start": start, "stop": stop}, nil)
+}
+
+// LLen returns the length of the list
+func (c *Client) LLen(key string) (int, error) {
+	var length int
+	err := c.do("GET", "/list/len/"+url.PathEscape(key), nil, &length)
+	return length, err
+}
@@ -0,0 +1,20 @@
+package client
+
+// MGet returns the values stored at keys, in order, with nil for missing keys
+func (c *Client) MGet(keys ...string) ([]interface{}, error) {
+	var values []interface{}
+	err := c.do("POST", "/mget", map[string]interface{}{"keys": keys}, &values)
+	return values, err
+}
+
+// MSet writes all entries atomically with the same optional TTL (in seconds)
+func (c *Client) MSet(entries map[string]interface{}, ttl int) error {
+	return c.do("POST", "/mset", map[string]interface{}{"entries": entries, "ttl": ttl}, nil)
+}
+
+// MDel removes keys atomically and returns how many of them existed
+func (c *Client) MDel(keys ...string) (int, error) {
+	var removed int
+	err := c.do("POST", "/mdel", map[string]interface{}{"keys": keys}, &removed)
+	return removed, err
+}
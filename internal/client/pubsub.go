@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"net/url"
+)
+
+// Message is a payload received from a channel. Pattern is set for messages received
+// through a pattern subscription. Dropped counts the messages the server discarded for
+// this subscriber, because it was reading too slowly, since the previous message.
+type Message struct {
+	Channel string      `json:"channel"`
+	Pattern string      `json:"pattern,omitempty"`
+	Payload interface{} `json:"payload"`
+	Dropped int64       `json:"dropped,omitempty"`
+}
+
+// Publish sends message to a channel and returns the number of subscribers that received it
+func (c *Client) Publish(channel string, message interface{}) (int, error) {
+	var received int
+	err := c.do("POST", "/publish", map[string]interface{}{"channel": channel, "message": message}, &received)
+	return received, err
+}
+
+// Subscribe streams the messages published on channels and on channels matching the glob
+// patterns. The returned channel is closed when ctx is done or the connection ends.
+func (c *Client) Subscribe(ctx context.Context, channels, patterns []string) (<-chan Message, error) {
+	query := url.Values{"channel": channels, "pattern": patterns}
+	return stream[Message](ctx, c, "/subscribe?"+query.Encode())
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ScanIterator walks the keyspace page by page through the /keys endpoint
+//
+//	it := client.Scan("user:*", "", 100)
+//	for it.Next() {
+//		fmt.Println(it.Key())
+//	}
+//	if err := it.Err(); err != nil { ... }
+type ScanIterator struct {
+	client *Client
+	query  url.Values
+	cursor string
+	keys   []string
+	key    string
+	done   bool
+	err    error
+}
+
+// Scan returns an iterator over keys matching a glob pattern and, when typ is not
+// empty, holding that type. count is a hint for the page size; 0 uses the server default.
+func (c *Client) Scan(match, typ string, count int) *ScanIterator {
+	query := url.Values{}
+	if match != "" {
+		query.Set("match", match)
+	}
+	if typ != "" {
+		query.Set("type", typ)
+	}
+	if count > 0 {
+		query.Set("count", fmt.Sprint(count))
+	}
+	return &ScanIterator{client: c, query: query, cursor: "0"}
+}
+
+// Next advances to the next key, fetching pages as needed. It returns false when
+// the iteration is complete or a request failed.
+func (it *ScanIterator) Next() bool {
+	for len(it.keys) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		it.query.Set("cursor", it.cursor)
+		var page struct {
+			Cursor string   `json:"cursor"`
+			Keys   []string `json:"keys"`
+		}
+		if it.err = it.client.do("GET", "/keys?"+it.query.Encode(), nil, &page); it.err != nil {
+			return false
+		}
+		it.keys = page.Keys
+		it.cursor = page.Cursor
+		it.done = page.Cursor == "0"
+	}
+
+	it.key, it.keys = it.keys[0], it.keys[1:]
+	return true
+}
+
+// Key returns the current key
+func (it *ScanIterator) Key() string {
+	return it.key
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ScanIterator) Err() error {
+	return it.err
+}
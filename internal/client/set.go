@@ -0,0 +1,97 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// SAdd adds members to the set at key and returns how many were new
+func (c *Client) SAdd(key string, members ...string) (int, error) {
+	var added int
+	err := c.do("POST", "/set/add", map[string]interface{}{"key": key, "members": members}, &added)
+	return added, err
+}
+
+// SRem removes members from the set at key and returns how many existed
+func (c *Client) SRem(key string, members ...string) (int, error) {
+	var removed int
+	err := c.do("POST", "/set/rem", map[string]interface{}{"key": key, "members": members}, &removed)
+	return removed, err
+}
+
+// SIsMember reports whether member is in the set at key
+func (c *Client) SIsMember(key, member string) (bool, error) {
+	var found bool
+	err := c.do("GET", "/set/ismember/"+url.PathEscape(key)+"/"+url.PathEscape(member), nil, &found)
+	return found, err
+}
+
+// SMembers returns the members of the set in lexical order
+func (c *Client) SMembers(key string) ([]string, error) {
+	var members []string
+	err := c.do("GET", "/set/members/"+url.PathEscape(key), nil, &members)
+	return members, err
+}
+
+// SCard returns the number of members in the set
+func (c *Client) SCard(key string) (int, error) {
+	var card int
+	err := c.do("GET", "/set/card/"+url.PathEscape(key), nil, &card)
+	return card, err
+}
+
+// SPop removes and returns up to count random members
+func (c *Client) SPop(key string, count int) ([]string, error) {
+	var members []string
+	err := c.do("POST", fmt.Sprintf("/set/pop/%s?count=%d", url.PathEscape(key), count), nil, &members)
+	return members, err
+}
+
+// SRandMember returns random members without removing them. A negative count may repeat members.
+func (c *Client) SRandMember(key string, count int) ([]string, error) {
+	var members []string
+	err := c.do("GET", fmt.Sprintf("/set/randmember/%s?count=%d", url.PathEscape(key), count), nil, &members)
+	return members, err
+}
+
+// SUnion returns the members of any of the sets at keys
+func (c *Client) SUnion(keys ...string) ([]string, error) {
+	return c.combineSets("/set/union", keys)
+}
+
+// SInter returns the members present in all the sets at keys
+func (c *Client) SInter(keys ...string) ([]string, error) {
+	return c.combineSets("/set/inter", keys)
+}
+
+// SDiff returns the members of the first set that are in none of the others
+func (c *Client) SDiff(keys ...string) ([]string, error) {
+	return c.combineSets("/set/diff", keys)
+}
+
+// SUnionStore stores the union of the sets at keys in dest and returns its size
+func (c *Client) SUnionStore(dest string, keys ...string) (int, error) {
+	return c.combineSetsStore("/set/union", dest, keys)
+}
+
+// SInterStore stores the intersection of the sets at keys in dest and returns its size
+func (c *Client) SInterStore(dest string, keys ...string) (int, error) {
+	return c.combineSetsStore("/set/inter", dest, keys)
+}
+
+// SDiffStore stores the difference of the sets at keys in dest and returns its size
+func (c *Client) SDiffStore(dest string, keys ...string) (int, error) {
+	return c.combineSetsStore("/set/diff", dest, keys)
+}
+
+func (c *Client) combineSets(path string, keys []string) ([]string, error) {
+	var members []string
+	err := c.do("POST", path, map[string]interface{}{"keys": keys}, &members)
+	return members, err
+}
+
+func (c *Client) combineSetsStore(path, dest string, keys []string) (int, error) {
+	var card int
+	err := c.do("POST", path, map[string]interface{}{"keys": keys, "destination": dest}, &card)
+	return card, err
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// StreamEntry is an entry of a stream. IDs have the form "ms-seq".
+type StreamEntry struct {
+	ID     string                 `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// PendingEntry is an entry delivered to a consumer group and not acknowledged yet
+type PendingEntry struct {
+	ID          string    `json:"id"`
+	Consumer    string    `json:"consumer"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Deliveries  int       `json:"deliveries"`
+}
+
+// XReadOptions controls XRead and XReadGroup. A positive Count limits the entries per
+// stream. Block waits for new entries, for at most Timeout when it is positive. NoAck
+// makes XReadGroup skip the pending entries list.
+type XReadOptions struct {
+	Count   int
+	Block   bool
+	Timeout time.Duration
+	NoAck   bool
+}
+
+// XAdd appends an entry to the stream at key and returns its ID. An empty id lets the
+// server generate one; a positive maxLen trims the stream to that many entries.
+func (c *Client) XAdd(key, id string, fields map[string]interface{}, maxLen int) (string, error) {
+	var added string
+	err := c.do("POST", "/stream/add", map[string]interface{}{
+		"key":    key,
+		"id":     id,
+		"fields": fields,
+		"maxlen": maxLen,
+	}, &added)
+	return added, err
+}
+
+// XLen returns the number of entries in the stream
+func (c *Client) XLen(key string) (int, error) {
+	var length int
+	err := c.do("GET", "/stream/len/"+url.PathEscape(key), nil, &length)
+	return length, err
+}
+
+// XRange returns up to count entries between start and end inclusive; a count that is not
+// positive returns all. "-" and "+" are open bounds and a "(" prefix excludes the ID.
+func (c *Client) XRange(key, start, end string, count int) ([]StreamEntry, error) {
+	var entries []StreamEntry
+	query := url.Values{"start": {start}, "end": {end}, "count": {fmt.Sprint(count)}}
+	err := c.do("GET", "/stream/range/"+url.PathEscape(key)+"?"+query.Encode(), nil, &entries)
+	return entries, err
+}
+
+// XTrim removes the oldest entries so that at most maxLen remain and returns how many were removed
+func (c *Client) XTrim(key string, maxLen int) (int, error) {
+	var removed int
+	err := c.do("POST", "/stream/trim", map[string]interface{}{"key": key, "maxlen": maxLen}, &removed)
+	return removed, err
+}
+
+// XRead returns the entries added after ids[i] to the stream at keys[i], by stream. The
+// ID "$" stands for the last entry of the stream. A blocking read that times out returns
+// no streams.
+func (c *Client) XRead(ctx context.Context, opts XReadOptions, keys, ids []string) (map[string][]StreamEntry, error) {
+	var result map[string][]StreamEntry
+	err := c.doContext(ctx, "POST", "/stream/read", map[string]interface{}{
+		"keys":    keys,
+		"ids":     ids,
+		"count":   opts.Count,
+		"block":   opts.Block,
+		"timeout": opts.Timeout.Seconds(),
+	}, &result)
+	return result, err
+}
+
+// XGroupCreate creates a consumer group delivering the entries after start; "$" only
+// delivers new entries. mkStream creates the stream when it does not exist.
+func (c *Client) XGroupCreate(key, group, start string, mkStream bool) error {
+	return c.do("POST", "/stream/group/create", map[string]interface{}{
+		"key":      key,
+		"group":    group,
+		"start":    start,
+		"mkstream": mkStream,
+	}, nil)
+}
+
+// XReadGroup reads the streams on behalf of consumer in group. The ID ">" delivers new
+// entries; any other ID returns the consumer's own pending entries after it.
+func (c *Client) XReadGroup(ctx context.Context, group, consumer string, opts XReadOptions, keys, ids []string) (map[string][]StreamEntry, error) {
+	var result map[string][]StreamEntry
+	err := c.doContext(ctx, "POST", "/stream/readgroup", map[string]interface{}{
+		"group":    group,
+		"consumer": consumer,
+		"keys":     keys,
+		"ids":      ids,
+		"count":    opts.Count,
+		"block":    opts.Block,
+		"timeout":  opts.Timeout.Seconds(),
+		"noack":    opts.NoAck,
+	}, &result)
+	return result, err
+}
+
+// XAck acknowledges entries delivered to group and returns how many were pending
+func (c *Client) XAck(key, group string, ids ...string) (int, error) {
+	var acked int
+	err := c.do("POST", "/stream/ack", map[string]interface{}{"key": key, "group": group, "ids": ids}, &acked)
+	return acked, err
+}
+
+// XPending returns up to count entries pending in group between start and end, limited
+// to consumer unless it is empty
+func (c *Client) XPending(key, group, start, end string, count int, consumer string) ([]PendingEntry, error) {
+	var pending []PendingEntry
+	query := url.Values{"start": {start}, "end": {end}, "count": {fmt.Sprint(count)}, "consumer": {consumer}}
+	path := "/stream/pending/" + url.PathEscape(key) + "/" + url.PathEscape(group) + "?" + query.Encode()
+	err := c.do("GET", path, nil, &pending)
+	return pending, err
+}
+
+// XClaim gives consumer the pending entries among ids that have been idle for at least
+// minIdle and returns them
+func (c *Client) XClaim(key, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry, error) {
+	var claimed []StreamEntry
+	err := c.do("POST", "/stream/claim", map[string]interface{}{
+		"key":      key,
+		"group":    group,
+		"consumer": consumer,
+		"min_idle": minIdle.Milliseconds(),
+		"ids":      ids,
+	}, &claimed)
+	return claimed, err
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"errors"
+	"net/url"
+)
+
+// ErrTxAborted is returned by Tx.Exec when a watched key changed
+var ErrTxAborted = errors.New("transaction aborted: a watched key was modified")
+
+// Tx builds a transaction that is sent to the server in one request by Exec.
+// Builder methods can be chained; the first error is reported by Exec.
+type Tx struct {
+	client   *Client
+	watch    map[string]uint64
+	commands []map[string]interface{}
+	err      error
+}
+
+// TxResult is the outcome of one command of a transaction
+type TxResult struct {
+	Value interface{} `json:"value"`
+	Error string      `json:"error,omitempty"`
+}
+
+// Multi starts building a transaction
+func (c *Client) Multi() *Tx {
+	return &Tx{client: c, watch: make(map[string]uint64)}
+}
+
+// Version returns the current version of key, 0 when it does not exist
+func (c *Client) Version(key string) (uint64, error) {
+	var version uint64
+	err := c.do("GET", "/version/"+url.PathEscape(key), nil, &version)
+	return version, err
+}
+
+// Watch fetches the current version of keys; Exec aborts if any of them changes in the meantime
+func (tx *Tx) Watch(keys ...string) *Tx {
+	for _, key := range keys {
+		if tx.err != nil {
+			return tx
+		}
+		tx.watch[key], tx.err = tx.client.Version(key)
+	}
+	return tx
+}
+
+// WatchVersion makes Exec abort unless key is still at version; 0 means the key must not exist
+func (tx *Tx) WatchVersion(key string, version uint64) *Tx {
+	tx.watch[key] = version
+	return tx
+}
+
+func (tx *Tx) Set(key string, value interface{}, ttl int) *Tx {
+	return tx.queue(map[string]interface{}{"op": "set", "key": key, "value": value, "ttl": ttl})
+}
+
+func (tx *Tx) Delete(key string) *Tx {
+	return tx.queue(map[string]interface{}{"op": "del", "key": key})
+}
+
+func (tx *Tx) LPush(key string, values ...interface{}) *Tx {
+	return tx.queue(map[string]interface{}{"op": "lpush", "key": key, "values": values})
+}
+
+func (tx *Tx) RPush(key string, values ...interface{}) *Tx {
+	return tx.queue(map[string]interface{}{"op": "rpush", "key": key, "values": values})
+}
+
+func (tx *Tx) Incr(key string) *Tx {
+	return tx.queue(map[string]interface{}{"op": "incr", "key": key})
+}
+
+func (tx *Tx) IncrBy(key string, delta int64) *Tx {
+	return tx.queue(map[string]interface{}{"op": "incrby", "key": key, "delta": delta})
+}
+
+func (tx *Tx) queue(command map[string]interface{}) *Tx {
+	tx.commands = append(tx.commands, command)
+	return tx
+}
+
+// Exec sends the transaction and returns the per-command results in order.
+// It returns ErrTxAborted when a watched key changed and nothing was executed.
+func (tx *Tx) Exec() ([]TxResult, error) {
+	if tx.err != nil {
+		return nil, tx.err
+	}
+
+	var result struct {
+		Committed bool       `json:"committed"`
+		Results   []TxResult `json:"results"`
+	}
+	err := tx.client.do("POST", "/tx", map[string]interface{}{"watch": tx.watch, "commands": tx.commands}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Committed {
+		return nil, ErrTxAborted
+	}
+	return result.Results, nil
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"net/url"
+	"time"
+)
+
+// NoExpiration is returned by TTL for a key that exists but does not expire
+const NoExpiration time.Duration = -1
+
+// TTL returns the remaining time to live of key with millisecond precision,
+// NoExpiration when it does not expire and ErrNotFound when it does not exist.
+func (c *Client) TTL(key string) (time.Duration, error) {
+	var resp struct {
+		PTTL int64 `json:"pttl"`
+	}
+	if err := c.do("GET", "/ttl/"+url.PathEscape(key), nil, &resp); err != nil {
+		return 0, err
+	}
+	if resp.PTTL < 0 {
+		return NoExpiration, nil
+	}
+	return time.Duration(resp.PTTL) * time.Millisecond, nil
+}
+
+// Expire sets the time to live of key, truncated to milliseconds. A ttl that is not positive
+// deletes the key. It reports whether the key existed.
+func (c *Client) Expire(key string, ttl time.Duration) (bool, error) {
+	var updated bool
+	err := c.do("POST", "/pexpire", map[string]interface{}{"key": key, "ttl": ttl.Milliseconds()}, &updated)
+	return updated, err
+}
+
+// ExpireAt makes key expire at the given time. A time in the past deletes the key.
+// It reports whether the key existed.
+func (c *Client) ExpireAt(key string, at time.Time) (bool, error) {
+	var updated bool
+	err := c.do("POST", "/pexpireat", map[string]interface{}{"key": key, "at": at.UnixMilli()}, &updated)
+	return updated, err
+}
+
+// Persist removes the time to live of key and reports whether it had one
+func (c *Client) Persist(key string) (bool, error) {
+	var removed bool
+	err := c.do("POST", "/persist/"+url.PathEscape(key), nil, &removed)
+	return removed, err
+}
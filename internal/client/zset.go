@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ZMember is a member of a sorted set with its score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZAddOptions makes ZAdd conditional: NX only adds new members, XX only updates existing
+// ones, GT and LT only update a member when the new score is greater or lower
+type ZAddOptions struct {
+	NX, XX, GT, LT bool
+}
+
+// ZAdd adds members to the sorted set at key or updates their scores, and returns how many were added
+func (c *Client) ZAdd(key string, opts ZAddOptions, members ...ZMember) (int, error) {
+	var added int
+	err := c.do("POST", "/zset/add", map[string]interface{}{
+		"key":     key,
+		"members": members,
+		"nx":      opts.NX,
+		"xx":      opts.XX,
+		"gt":      opts.GT,
+		"lt":      opts.LT,
+	}, &added)
+	return added, err
+}
+
+// ZIncrBy adds delta to the score of member and returns the new score
+func (c *Client) ZIncrBy(key, member string, delta float64) (float64, error) {
+	var score float64
+	err := c.do("POST", "/zset/incrby", map[string]interface{}{"key": key, "member": member, "delta": delta}, &score)
+	return score, err
+}
+
+// ZScore returns the score of member, or ErrNotFound when it is not in the sorted set
+func (c *Client) ZScore(key, member string) (float64, error) {
+	var score float64
+	err := c.do("GET", "/zset/score/"+url.PathEscape(key)+"/"+url.PathEscape(member), nil, &score)
+	return score, err
+}
+
+// ZRank returns the 0-based rank of member by ascending score, or descending when rev is set
+func (c *Client) ZRank(key, member string, rev bool) (int, error) {
+	var rank int
+	path := fmt.Sprintf("/zset/rank/%s/%s?rev=%t", url.PathEscape(key), url.PathEscape(member), rev)
+	err := c.do("GET", path, nil, &rank)
+	return rank, err
+}
+
+// ZCard returns the number of members in the sorted set
+func (c *Client) ZCard(key string) (int, error) {
+	var card int
+	err := c.do("GET", "/zset/card/"+url.PathEscape(key), nil, &card)
+	return card, err
+}
+
+// ZRange returns the members between ranks start and stop inclusive; negative ranks count from the end
+func (c *Client) ZRange(key string, start, stop int, rev bool) ([]ZMember, error) {
+	var members []ZMember
+	path := fmt.Sprintf("/zset/range/%s?start=%d&stop=%d&rev=%t", url.PathEscape(key), start, stop, rev)
+	err := c.do("GET", path, nil, &members)
+	return members, err
+}
+
+// ZRangeByScore returns up to count members with a score between min and max, skipping offset of them.
+// Bounds use the Redis syntax: "(1.5" excludes 1.5, "-inf" and "+inf" are open. A negative count returns all.
+func (c *Client) ZRangeByScore(key, min, max string, offset, count int) ([]ZMember, error) {
+	return c.zrangeBy("/zset/rangebyscore/", key, min, max, offset, count)
+}
+
+// ZRangeByLex returns up to count members between min and max lexically, skipping offset of them.
+// Bounds use the Redis syntax: "[a" includes a, "(a" excludes it, "-" and "+" are open.
+func (c *Client) ZRangeByLex(key, min, max string, offset, count int) ([]ZMember, error) {
+	return c.zrangeBy("/zset/rangebylex/", key, min, max, offset, count)
+}
+
+func (c *Client) zrangeBy(path, key, min, max string, offset, count int) ([]ZMember, error) {
+	query := url.Values{
+		"min":    {min},
+		"max":    {max},
+		"offset": {fmt.Sprint(offset)},
+		"count":  {fmt.Sprint(count)},
+	}
+	var members []ZMember
+	err := c.do("GET", path+url.PathEscape(key)+"?"+query.Encode(), nil, &members)
+	return members, err
+}
+
+// ZRem removes members from the sorted set and returns how many existed
+func (c *Client) ZRem(key string, members ...string) (int, error) {
+	var removed int
+	err := c.do("POST", "/zset/rem", map[string]interface{}{"key": key, "members": members}, &removed)
+	return removed, err
+}
+
+// ZRemRangeByScore removes the members with a score between min and max and returns how many were removed
+func (c *Client) ZRemRangeByScore(key, min, max string) (int, error) {
+	var removed int
+	err := c.do("POST", "/zset/remrangebyscore", map[string]interface{}{"key": key, "min": min, "max": max}, &removed)
+	return removed, err
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores
+func (c *Client) ZPopMin(key string, count int) ([]ZMember, error) {
+	var members []ZMember
+	err := c.do("POST", fmt.Sprintf("/zset/popmin/%s?count=%d", url.PathEscape(key), count), nil, &members)
+	return members, err
+}
+
+// ZPopMax removes and returns up to count members with the highest scores, highest first
+func (c *Client) ZPopMax(key string, count int) ([]ZMember, error) {
+	var members []ZMember
+	err := c.do("POST", fmt.Sprintf("/zset/popmax/%s?count=%d", url.PathEscape(key), count), nil, &members)
+	return members, err
+}
@@ -0,0 +1,409 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+// aofRecord is one mutation in the append-only file. Records describe effects rather
+// than the commands that caused them, so replaying them is deterministic: SPOP is logged
+// as the SREM of the members it popped, relative TTLs as absolute times, and XADD with
+// the ID it generated. Only the fields used by the operation are set. User values are
+// encoded with their types, see aofValues.
+type aofRecord struct {
+	Time int64  `json:"t"` // Unix milliseconds when the mutation was applied
+	Op   string `json:"op"`
+	Key  string `json:"key"`
+
+	Value    interface{}            `json:"-"`
+	At       int64                  `json:"at,omitempty"` // expiration in Unix milliseconds, 0 for none
+	Values   []interface{}          `json:"-"`
+	Fields   map[string]interface{} `json:"-"`
+	Names    []string               `json:"names,omitempty"`
+	Scores   []ZMember              `json:"scores,omitempty"`
+	Count    int                    `json:"count,omitempty"`
+	Index    int                    `json:"index,omitempty"`
+	Stop     int                    `json:"stop,omitempty"`
+	Before   bool                   `json:"before,omitempty"`
+	Pivot    interface{}            `json:"-"`
+	ID       string                 `json:"id,omitempty"`
+	IDs      []string               `json:"ids,omitempty"`
+	Group    string                 `json:"group,omitempty"`
+	Consumer string                 `json:"consumer,omitempty"`
+	NoAck    bool                   `json:"noack,omitempty"`
+	MinIdle  time.Duration          `json:"min_idle,omitempty"`
+}
+
+// aofValues holds the user values of a record in the tagged encoding of
+// persistence.EncodeValue, so that replay restores integers, blobs and collections with
+// the types they were written with rather than as float64 and base64 strings.
+type aofValues struct {
+	Value  *persistence.TaggedValue `json:"value,omitempty"`
+	Values *persistence.TaggedValue `json:"values,omitempty"`
+	Fields *persistence.TaggedValue `json:"fields,omitempty"`
+	Pivot  *persistence.TaggedValue `json:"pivot,omitempty"`
+}
+
+// taggedRecord is the JSON form of an aofRecord
+type taggedRecord struct {
+	plainRecord
+	aofValues
+}
+
+type plainRecord aofRecord
+
+func (rec aofRecord) MarshalJSON() ([]byte, error) {
+	encoded := taggedRecord{plainRecord: plainRecord(rec)}
+	var err error
+	if encoded.aofValues.Value, err = encodeAOFValue(rec.Value, rec.Value != nil); err != nil {
+		return nil, err
+	}
+	if encoded.aofValues.Values, err = encodeAOFValue(rec.Values, len(rec.Values) > 0); err != nil {
+		return nil, err
+	}
+	if encoded.aofValues.Fields, err = encodeAOFValue(rec.Fields, len(rec.Fields) > 0); err != nil {
+		return nil, err
+	}
+	if encoded.aofValues.Pivot, err = encodeAOFValue(rec.Pivot, rec.Pivot != nil); err != nil {
+		return nil, err
+	}
+	return json.Marshal(encoded)
+}
+
+func (rec *aofRecord) UnmarshalJSON(data []byte) error {
+	var decoded taggedRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*rec = aofRecord(decoded.plainRecord)
+	var err error
+	if rec.Value, err = decodeAOFValue(decoded.aofValues.Value); err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	if rec.Pivot, err = decodeAOFValue(decoded.aofValues.Pivot); err != nil {
+		return fmt.Errorf("pivot: %w", err)
+	}
+	values, err := decodeAOFValue(decoded.aofValues.Values)
+	if err != nil {
+		return fmt.Errorf("values: %w", err)
+	}
+	fields, err := decodeAOFValue(decoded.aofValues.Fields)
+	if err != nil {
+		return fmt.Errorf("fields: %w", err)
+	}
+	var ok bool
+	if rec.Values, ok = values.([]interface{}); values != nil && !ok {
+		return fmt.Errorf("values hold %T", values)
+	}
+	if rec.Fields, ok = fields.(map[string]interface{}); fields != nil && !ok {
+		return fmt.Errorf("fields hold %T", fields)
+	}
+	return nil
+}
+
+// encodeAOFValue encodes value when present, and returns nil otherwise
+func encodeAOFValue(value interface{}, present bool) (*persistence.TaggedValue, error) {
+	if !present {
+		return nil, nil
+	}
+	tagged, err := persistence.EncodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &tagged, nil
+}
+
+// decodeAOFValue decodes a value encoded by encodeAOFValue, or returns nil when absent
+func decodeAOFValue(tagged *persistence.TaggedValue) (interface{}, error) {
+	if tagged == nil {
+		return nil, nil
+	}
+	return persistence.DecodeValue(*tagged)
+}
+
+// commandLog appends the mutations of every shard to the append-only file. Records are
+// appended under the shard lock, so the records of a key are in the order its changes
+// were applied. A nil log records nothing.
+type commandLog struct {
+	ss   *ShardedStore
+	aof  *persistence.AOF
+	path string
+
+	mutex     sync.Mutex  // orders appends with the start and end of a rewrite
+	rewriting *aofRewrite // running rewrite, nil when there is none
+	rewrites  sync.WaitGroup
+	closed    bool
+
+	growth   int   // percentage of growth over baseSize that triggers a rewrite, see Config
+	minSize  int64 // smallest log rewritten automatically
+	baseSize int64 // size of the log after the last rewrite, or when it was opened
+
+	rewriteCount        int64
+	lastRewrite         time.Time
+	lastRewriteDuration time.Duration
+	lastRewriteErr      error
+}
+
+func (l *commandLog) append(rec aofRecord) {
+	if l == nil {
+		return
+	}
+	if rec.Time == 0 {
+		rec.Time = time.Now().UnixMilli()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Println("Error appending to AOF:", err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Writes racing with Close come after the store has stopped; the file may be closed
+	if l.closed {
+		log.Printf("Dropped AOF record for key %q: the log is closed", rec.Key)
+		return
+	}
+	if err := l.aof.Append(data); err != nil {
+		log.Println("Error appending to AOF:", err)
+	}
+	if rw := l.rewriting; rw != nil {
+		if rw.captured[shardIndex(rec.Key)] {
+			rw.buffer = append(rw.buffer, data)
+		}
+	} else if l.grown() {
+		go l.ss.rewriteAOF(l.begin())
+	}
+}
+
+// setRecord records that key now holds entry, replacing any previous value.
+func setRecord(key string, entry Entry) aofRecord {
+	return aofRecord{Op: "set", Key: key, Value: entry.Value, At: entry.Expiration}
+}
+
+func (l *commandLog) appendSet(key string, entry Entry) {
+	l.append(setRecord(key, entry))
+}
+
+func (l *commandLog) appendDel(key string) {
+	l.append(aofRecord{Op: "del", Key: key})
+}
+
+// OpenAOF replays the append-only file at filename into the store and then logs every
+// mutation to it, syncing according to policy. When the file does not exist yet, it is
+// written from the current contents of the store first, so that the log alone can
+// rebuild the store; if that fails, no file is left behind. Call it before serving
+// requests; Close closes the file.
+func (ss *ShardedStore) OpenAOF(filename string, policy persistence.FsyncPolicy) error {
+	_, statErr := os.Stat(filename)
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err := ss.seedAOF(filename); err != nil {
+			return err
+		}
+	}
+
+	replayed, err := ss.replayAOF(filename)
+	if err != nil {
+		return err
+	}
+	aof, err := persistence.OpenAOF(filename, policy, ss.config.Encryption)
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d records from %s", replayed, filename)
+	}
+
+	l := &commandLog{
+		ss:       ss,
+		aof:      aof,
+		path:     filename,
+		growth:   ss.config.AOFRewritePercentage,
+		minSize:  ss.config.AOFRewriteMinSize,
+		baseSize: aof.Size(),
+	}
+	for i := range ss.shards {
+		shard := &ss.shards[i]
+		shard.mutex.Lock()
+		shard.aof = l
+		shard.mutex.Unlock()
+	}
+	ss.aof = l
+	// Evicted only now, so that the deletions are logged
+	ss.evictToBudget()
+	return nil
+}
+
+// seedAOF writes a set record for every live key to a new log at filename. The log is
+// built beside filename and renamed into place once complete, so a failed seed does not
+// leave an empty log that the next start would replay instead of the snapshot.
+func (ss *ShardedStore) seedAOF(filename string) error {
+	next, err := persistence.NewAOFRewrite(filename, ss.config.Encryption)
+	if err != nil {
+		return err
+	}
+	for i := range ss.shards {
+		shard := &ss.shards[i]
+		shard.mutex.RLock()
+		records, err := shard.setRecords(time.Now().UnixMilli())
+		shard.mutex.RUnlock()
+		if err == nil {
+			err = appendAll(next, records)
+		}
+		if err != nil {
+			next.Abort()
+			return err
+		}
+	}
+	return next.Install(filename)
+}
+
+// aofReplay applies records to a store. While replaying, entries are kept without
+// expiration and the TTLs are tracked here instead: every record is applied as of the
+// time it was logged, so a key that had expired by then is dropped first, and a key that
+// was live then is not mistaken for expired because the replay runs later.
+type aofReplay struct {
+	ss          *ShardedStore
+	expirations map[string]int64
+}
+
+// replayAOF applies the records of filename. Like snapshot loads, the records bypass the
+// memory budget, which would otherwise evict keys already replayed or stop the replay
+// halfway, and emit no keyspace events.
+func (ss *ShardedStore) replayAOF(filename string) (int, error) {
+	ss.replaying.Store(true)
+	ss.events.muted.Store(true)
+	defer ss.replaying.Store(false)
+	defer ss.events.muted.Store(false)
+
+	r := &aofReplay{ss: ss, expirations: make(map[string]int64)}
+	applied, err := persistence.ReplayAOF(filename, ss.config.Encryption, func(data []byte) error {
+		var rec aofRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		return r.apply(rec)
+	})
+	if err != nil {
+		return applied, err
+	}
+	r.finish()
+	return applied, nil
+}
+
+func (r *aofReplay) apply(rec aofRecord) error {
+	ss := r.ss
+	if expiration, found := r.expirations[rec.Key]; found && rec.Time > expiration {
+		ss.Delete(rec.Key)
+		delete(r.expirations, rec.Key)
+	}
+
+	var err error
+	switch rec.Op {
+	case "set":
+		shard := ss.getShard(rec.Key)
+		shard.mutex.Lock()
+		shard.put(rec.Key, Entry{Value: rec.Value})
+		shard.mutex.Unlock()
+		r.expire(rec.Key, rec.At)
+	case "del":
+		ss.Delete(rec.Key)
+		delete(r.expirations, rec.Key)
+	case "pexpireat":
+		r.expire(rec.Key, rec.At)
+	case "lpush":
+		_, err = ss.LPush(rec.Key, rec.Values...)
+	case "rpush":
+		_, err = ss.RPush(rec.Key, rec.Values...)
+	case "lpop", "rpop":
+		for i := 0; i < rec.Count && err == nil; i++ {
+			_, _, err = ss.pop(rec.Key, rec.Op == "lpop")
+		}
+	case "lset":
+		err = ss.LSet(rec.Key, rec.Index, rec.Value)
+	case "linsert":
+		_, err = ss.LInsert(rec.Key, rec.Before, rec.Pivot, rec.Value)
+	case "lrem":
+		_, err = ss.LRem(rec.Key, rec.Count, rec.Value)
+	case "ltrim":
+		err = ss.LTrim(rec.Key, rec.Index, rec.Stop)
+	case "hset":
+		_, err = ss.HSet(rec.Key, rec.Fields)
+	case "hdel":
+		_, err = ss.HDel(rec.Key, rec.Names...)
+	case "sadd":
+		_, err = ss.SAdd(rec.Key, rec.Names...)
+	case "srem":
+		_, err = ss.SRem(rec.Key, rec.Names...)
+	case "zadd":
+		_, err = ss.ZAdd(rec.Key, rec.Scores, ZAddOptions{})
+	case "zrem":
+		_, err = ss.ZRem(rec.Key, rec.Names...)
+	case "xadd":
+		_, err = ss.XAdd(rec.Key, rec.Fields, XAddOptions{ID: rec.ID, MaxLen: rec.Count})
+	case "xtrim":
+		_, err = ss.XTrim(rec.Key, rec.Count)
+	case "xgroup":
+		err = ss.XGroupCreate(rec.Key, rec.Group, rec.ID, true)
+	case "xreadgroup":
+		err = ss.withGroup(rec.Key, func(_ *Store, stream *Stream) error {
+			_, err := stream.ReadGroup(rec.Group, rec.Consumer, rec.Count, rec.NoAck, time.UnixMilli(rec.Time))
+			return err
+		})
+	case "xack":
+		_, err = ss.XAck(rec.Key, rec.Group, rec.IDs...)
+	case "xclaim":
+		ids, parseErr := parseStreamIDs(rec.IDs)
+		if parseErr != nil {
+			return parseErr
+		}
+		err = ss.withGroup(rec.Key, func(_ *Store, stream *Stream) error {
+			_, err := stream.Claim(rec.Group, rec.Consumer, rec.MinIdle, ids, time.UnixMilli(rec.Time))
+			return err
+		})
+	default:
+		err = fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", rec.Op, rec.Key, err)
+	}
+	// A record that emptied a collection removed the key along with it; logs written
+	// before the deletion was logged as well rely on this to drop the TTL.
+	if _, found := r.expirations[rec.Key]; found && ss.Type(rec.Key) == TypeNone {
+		delete(r.expirations, rec.Key)
+	}
+	return nil
+}
+
+func (r *aofReplay) expire(key string, at int64) {
+	if at > 0 {
+		r.expirations[key] = at
+	} else {
+		delete(r.expirations, key)
+	}
+}
+
+// finish puts the tracked TTLs back on the replayed entries.
+func (r *aofReplay) finish() {
+	now := time.Now().UnixMilli()
+	for key, expiration := range r.expirations {
+		shard := r.ss.getShard(key)
+		shard.mutex.Lock()
+		if entry, found := shard.data[key]; found {
+			if expiration < now {
+				shard.remove(key)
+			} else {
+				shard.setExpiration(key, entry, expiration)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
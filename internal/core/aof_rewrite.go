@@ -0,0 +1,228 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+const (
+	// DefaultAOFRewritePercentage rewrites the log once it has doubled since the last rewrite
+	DefaultAOFRewritePercentage = 100
+
+	// DefaultAOFRewriteMinSize keeps small logs from being rewritten over and over
+	DefaultAOFRewriteMinSize = 64 << 20
+)
+
+var (
+	// ErrAOFDisabled is returned by RewriteAOF when the store has no append-only file
+	ErrAOFDisabled = errors.New("append-only file is not enabled")
+
+	// ErrAOFRewriteInProgress is returned by RewriteAOF while another rewrite is running
+	ErrAOFRewriteInProgress = errors.New("AOF rewrite already in progress")
+)
+
+// AOFStats describes the append-only file and its rewrites
+type AOFStats struct {
+	Size                int64     `json:"size"`
+	RewriteInProgress   bool      `json:"rewrite_in_progress"`
+	Rewrites            int64     `json:"rewrites"`
+	LastRewrite         time.Time `json:"last_rewrite"`
+	LastRewriteDuration int64     `json:"last_rewrite_ms"`
+	LastRewriteError    string    `json:"last_rewrite_error,omitempty"`
+}
+
+// aofRewrite is the state of a running rewrite. The shards are copied to the new log one
+// at a time; once a shard is captured, the records for its keys are buffered as well,
+// and the buffer is appended to the new log after the copy.
+type aofRewrite struct {
+	started  time.Time
+	captured [ShardCount]bool
+	buffer   [][]byte
+}
+
+// RewriteAOF replaces the append-only file with the shortest log that rebuilds the
+// current contents of the store, one set record per key. Writes continue while the new
+// log is written; the ones that arrive meanwhile are appended to both logs, and the new
+// log is swapped in atomically once it has caught up. It blocks until the rewrite ends.
+func (ss *ShardedStore) RewriteAOF() error {
+	rw, err := ss.beginRewrite()
+	if err != nil {
+		return err
+	}
+	return ss.rewriteAOF(rw)
+}
+
+// BackgroundRewriteAOF starts RewriteAOF in the background. It returns
+// ErrAOFRewriteInProgress when a rewrite is already running.
+func (ss *ShardedStore) BackgroundRewriteAOF() error {
+	rw, err := ss.beginRewrite()
+	if err != nil {
+		return err
+	}
+	go ss.rewriteAOF(rw)
+	return nil
+}
+
+func (ss *ShardedStore) beginRewrite() (*aofRewrite, error) {
+	l := ss.aof
+	if l == nil {
+		return nil, ErrAOFDisabled
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return nil, ErrAOFDisabled
+	}
+	if l.rewriting != nil {
+		return nil, ErrAOFRewriteInProgress
+	}
+	return l.begin(), nil
+}
+
+// begin marks a rewrite as running. Caller must hold the log mutex.
+func (l *commandLog) begin() *aofRewrite {
+	l.rewriting = &aofRewrite{started: time.Now()}
+	l.rewrites.Add(1)
+	return l.rewriting
+}
+
+// grown reports whether the log has grown enough since the last rewrite to be rewritten
+// again. Caller must hold the log mutex.
+func (l *commandLog) grown() bool {
+	if l.growth <= 0 || l.closed {
+		return false
+	}
+	size := l.aof.Size()
+	return size >= l.minSize && size >= l.baseSize+l.baseSize*int64(l.growth)/100
+}
+
+func (ss *ShardedStore) rewriteAOF(rw *aofRewrite) error {
+	l := ss.aof
+	defer l.rewrites.Done()
+
+	next, err := persistence.NewAOFRewrite(l.path, ss.config.Encryption)
+	if err == nil {
+		err = ss.copyShards(rw, next)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if next != nil {
+		if err == nil {
+			err = appendAll(next, rw.buffer)
+		}
+		if err == nil {
+			err = next.Commit(l.aof)
+		} else {
+			next.Abort()
+		}
+	}
+	l.end(rw, err)
+	return err
+}
+
+// copyShards writes a set record for every live key to next, one shard at a time, and
+// appends the records buffered so far after each shard.
+func (ss *ShardedStore) copyShards(rw *aofRewrite, next *persistence.AOFRewrite) error {
+	l := ss.aof
+	for i := range ss.shards {
+		shard := &ss.shards[i]
+
+		shard.mutex.RLock()
+		records, err := shard.setRecords(time.Now().UnixMilli())
+		if err != nil {
+			shard.mutex.RUnlock()
+			return err
+		}
+		l.mutex.Lock()
+		rw.captured[i] = true
+		l.mutex.Unlock()
+		shard.mutex.RUnlock()
+
+		if err := appendAll(next, records); err != nil {
+			return err
+		}
+
+		l.mutex.Lock()
+		buffered := rw.buffer
+		rw.buffer = nil
+		l.mutex.Unlock()
+		if err := appendAll(next, buffered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRecords encodes a set record for every live key of the shard. Caller must hold the
+// shard lock.
+func (s *Store) setRecords(now int64) ([][]byte, error) {
+	records := make([][]byte, 0, len(s.data))
+	for key, entry := range s.data {
+		if entry.isExpired(now) {
+			continue
+		}
+		rec := setRecord(key, entry)
+		rec.Time = now
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, data)
+	}
+	return records, nil
+}
+
+func appendAll(next *persistence.AOFRewrite, records [][]byte) error {
+	for _, data := range records {
+		if err := next.Append(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// end records the outcome of a rewrite. A failed rewrite also resets the growth
+// baseline, so the next automatic attempt waits for the log to grow again instead of
+// retrying on every append. Caller must hold the log mutex.
+func (l *commandLog) end(rw *aofRewrite, err error) {
+	l.rewriting = nil
+	l.baseSize = l.aof.Size()
+	l.lastRewrite = time.Now()
+	l.lastRewriteDuration = l.lastRewrite.Sub(rw.started)
+	l.lastRewriteErr = err
+	if err != nil {
+		log.Println("Error rewriting AOF:", err)
+		return
+	}
+	l.rewriteCount++
+}
+
+// close waits for a running rewrite and closes the file.
+func (l *commandLog) close() error {
+	l.mutex.Lock()
+	l.closed = true
+	l.mutex.Unlock()
+	l.rewrites.Wait()
+	return l.aof.Close()
+}
+
+func (l *commandLog) stats() *AOFStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stats := &AOFStats{
+		Size:                l.aof.Size(),
+		RewriteInProgress:   l.rewriting != nil,
+		Rewrites:            l.rewriteCount,
+		LastRewrite:         l.lastRewrite,
+		LastRewriteDuration: l.lastRewriteDuration.Milliseconds(),
+	}
+	if l.lastRewriteErr != nil {
+		stats.LastRewriteError = l.lastRewriteErr.Error()
+	}
+	return stats
+}
@@ -0,0 +1,406 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+// reopen closes store and replays its append-only file into a new store.
+func reopen(t *testing.T, store *ShardedStore, filename string) *ShardedStore {
+	t.Helper()
+	store.Close()
+	restored := NewShardedStore()
+	if err := restored.OpenAOF(filename, persistence.FsyncAlways); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	t.Cleanup(restored.Close)
+	return restored
+}
+
+func TestAOFReplaysMutations(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+
+	store.Set("greeting", "hello", 0)
+	store.Set("gone", "x", 0)
+	store.Delete("gone")
+	store.IncrBy("counter", 41)
+	store.IncrBy("counter", 1)
+	store.Set("session", "token", 60)
+	store.Set("kept", "v", 60)
+	store.Persist("kept")
+
+	store.RPush("list", "a", "b", "c", "d")
+	store.LPop("list")
+	store.LSet("list", 0, "B")
+	store.LInsert("list", false, "c", "c2")
+	store.LTrim("list", 0, 2)
+
+	store.HSet("hash", map[string]interface{}{"name": "ada", "lang": "go"})
+	store.HDel("hash", "lang")
+	store.HIncrBy("hash", "visits", 3)
+
+	store.SAdd("set", "a", "b", "c")
+	popped, _ := store.SPop("set", 1)
+
+	store.ZAdd("board", []ZMember{{"alice", 1}, {"bob", 2}}, ZAddOptions{})
+	store.ZIncrBy("board", "alice", 5)
+	store.ZPopMin("board", 1)
+
+	store.XAdd("jobs", map[string]interface{}{"task": "a"}, XAddOptions{})
+	store.XAdd("jobs", map[string]interface{}{"task": "b"}, XAddOptions{})
+	store.XGroupCreate("jobs", "workers", "0", false)
+	read, _ := store.XReadGroup(context.Background(), "workers", "alice", []string{"jobs"}, []string{">"}, XReadOptions{})
+	store.XAck("jobs", "workers", read["jobs"][0].ID.String())
+	store.XClaim("jobs", "workers", "bob", 0, read["jobs"][1].ID.String())
+
+	restored := reopen(t, store, filename)
+
+	if value, _ := restored.Get("greeting"); value != "hello" {
+		t.Errorf("Expected hello, got %v", value)
+	}
+	if _, found := restored.Get("gone"); found {
+		t.Error("Expected the deleted key to stay deleted")
+	}
+	if value, _ := restored.Get("counter"); value != int64(42) {
+		t.Errorf("Expected 42, got %v", value)
+	}
+	if ttl, _ := restored.TTL("session"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected the TTL to be restored, got %v", ttl)
+	}
+	if ttl, _ := restored.TTL("kept"); ttl != NoExpiration {
+		t.Errorf("Expected the persisted key not to expire, got %v", ttl)
+	}
+	if values, _ := restored.LRange("list", 0, -1); !reflect.DeepEqual(values, []interface{}{"B", "c", "c2"}) {
+		t.Errorf("Expected [B c c2], got %v", values)
+	}
+	if fields, _ := restored.HGetAll("hash"); !reflect.DeepEqual(fields, map[string]interface{}{"name": "ada", "visits": int64(3)}) {
+		t.Errorf("Expected name and visits, got %v", fields)
+	}
+	if members, _ := restored.SMembers("set"); len(members) != 2 || restored.shards[shardIndex("set")].data["set"].Value.(*Set).Contains(popped[0]) {
+		t.Errorf("Expected the popped member %v to stay removed, got %v", popped, members)
+	}
+	if board, _ := restored.ZRange("board", 0, -1, false); !reflect.DeepEqual(board, []ZMember{{"alice", 6}}) {
+		t.Errorf("Expected only alice with 6, got %v", board)
+	}
+	entries, _ := restored.XRange("jobs", "-", "+", 0)
+	if len(entries) != 2 || entries[0].ID != read["jobs"][0].ID {
+		t.Errorf("Expected the entries with their original IDs, got %v", entries)
+	}
+	pending, _ := restored.XPending("jobs", "workers", "-", "+", 0, "")
+	if len(pending) != 1 || pending[0].Consumer != "bob" || pending[0].Deliveries != 2 {
+		t.Errorf("Expected bob's claimed entry pending, got %+v", pending)
+	}
+}
+
+func TestAOFReplaysValuesWithTheirTypes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+
+	store.Set("big", int64(1)<<60, 0)
+	store.Set("blob", []byte{0, 1, 0xff}, 0)
+	store.Set("nested", map[string]interface{}{"n": 7, "items": []interface{}{int64(1), "two", nil}}, 0)
+	store.RPush("list", 1, []byte("raw"), 2.5)
+	store.LInsert("list", true, 1, int64(9))
+	store.HSet("hash", map[string]interface{}{"visits": int64(3)})
+	store.XAdd("stream", map[string]interface{}{"n": int64(1) << 60}, XAddOptions{})
+
+	restored := reopen(t, store, filename)
+	for _, key := range []string{"big", "blob", "nested"} {
+		want, _ := store.Get(key)
+		if got, _ := restored.Get(key); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", key, want, got)
+		}
+	}
+	if list, _ := restored.LRange("list", 0, -1); !reflect.DeepEqual(list, []interface{}{int64(9), 1, []byte("raw"), 2.5}) {
+		t.Errorf("Expected the list with its types, got %#v", list)
+	}
+	if visits, _, _ := restored.HGet("hash", "visits"); visits != int64(3) {
+		t.Errorf("Expected int64 3, got %#v", visits)
+	}
+	if entries, _ := restored.XRange("stream", "-", "+", 0); len(entries) != 1 || entries[0].Fields["n"] != int64(1)<<60 {
+		t.Errorf("Expected the stream field as int64, got %#v", entries)
+	}
+}
+
+func TestAOFSeedsNewFileFromContents(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	store.RPush("list", "a", "b")
+	store.Set("key", "value", 60)
+	if err := store.OpenAOF(filename, persistence.FsyncEverySec); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	store.RPush("list", "c")
+
+	restored := reopen(t, store, filename)
+	if values, _ := restored.LRange("list", 0, -1); !reflect.DeepEqual(values, []interface{}{"a", "b", "c"}) {
+		t.Errorf("Expected [a b c], got %v", values)
+	}
+	if ttl, _ := restored.TTL("key"); ttl <= 0 {
+		t.Errorf("Expected the seeded TTL, got %v", ttl)
+	}
+}
+
+func TestAOFFailedSeedLeavesNoFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	defer store.Close()
+	store.Set("key", "value", 0)
+	store.Set("unencodable", make(chan int), 0)
+
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err == nil {
+		t.Fatal("Expected seeding a value that cannot be encoded to fail")
+	}
+	for _, name := range []string{filename, filename + ".rewrite"} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected no %s after the failed seed, got %v", filepath.Base(name), err)
+		}
+	}
+}
+
+func TestAOFReplayDropsTTLOfEmptiedCollections(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	// Each key is emptied while it has a TTL and then recreated without one.
+	store.RPush("list", "a")
+	store.Expire("list", time.Hour)
+	store.LPop("list")
+	store.RPush("list", "b")
+	store.HSet("hash", map[string]interface{}{"f": "a"})
+	store.Expire("hash", time.Hour)
+	store.HDel("hash", "f")
+	store.HSet("hash", map[string]interface{}{"f": "b"})
+	store.SAdd("set", "a")
+	store.Expire("set", time.Hour)
+	store.SRem("set", "a")
+	store.SAdd("set", "b")
+	store.ZAdd("board", []ZMember{{"a", 1}}, ZAddOptions{})
+	store.Expire("board", time.Hour)
+	store.ZRem("board", "a")
+	store.ZAdd("board", []ZMember{{"b", 1}}, ZAddOptions{})
+
+	restored := reopen(t, store, filename)
+	for _, key := range []string{"list", "hash", "set", "board"} {
+		if ttl, _ := restored.TTL(key); ttl != NoExpiration {
+			t.Errorf("%s: expected the recreated key not to expire, got %v", key, ttl)
+		}
+	}
+}
+
+func TestAOFReplayDropsTTLOfEmptiedKeyWithoutDel(t *testing.T) {
+	// Logs written before emptied collections logged a del
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, _ := persistence.OpenAOF(filename, persistence.FsyncNo, nil)
+	now := time.Now().UnixMilli()
+	for _, rec := range []aofRecord{
+		{Time: now, Op: "rpush", Key: "list", Values: []interface{}{"a"}},
+		{Time: now, Op: "pexpireat", Key: "list", At: now + time.Hour.Milliseconds()},
+		{Time: now, Op: "lpop", Key: "list", Count: 1},
+		{Time: now, Op: "rpush", Key: "list", Values: []interface{}{"b"}},
+	} {
+		data, _ := json.Marshal(rec)
+		aof.Append(data)
+	}
+	aof.Close()
+
+	store := NewShardedStore()
+	defer store.Close()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	if ttl, _ := store.TTL("list"); ttl != NoExpiration {
+		t.Errorf("Expected the recreated list not to expire, got %v", ttl)
+	}
+}
+
+func TestAOFReplayIgnoresMemoryBudget(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		store.RPush(key, "x")
+	}
+	store.Close()
+
+	// Replayed with a smaller budget, every record applies and eviction runs afterwards.
+	// The noeviction case runs first, since eviction logs its deletions.
+	for _, tc := range []struct {
+		policy EvictionPolicy
+		keys   int64
+	}{{NoEviction, 3}, {AllKeysLRU, 2}} {
+		policy, keys := tc.policy, tc.keys
+		restored := NewShardedStoreWithConfig(Config{MaxKeys: 2, EvictionPolicy: policy})
+		rec := &recorder{}
+		restored.OnKeyEvent(EventFilter{Types: []EventType{EventRPush}}, rec.record)
+		if err := restored.OpenAOF(filename, persistence.FsyncNo); err != nil {
+			t.Fatalf("%s: OpenAOF failed: %v", policy, err)
+		}
+		if got := restored.Stats().Keys; got != keys {
+			t.Errorf("%s: expected %d keys, got %d", policy, keys, got)
+		}
+		restored.Close()
+		if len(rec.events) != 0 {
+			t.Errorf("%s: expected replayed records to emit no events, got %v", policy, rec.events)
+		}
+	}
+}
+
+func TestAOFReplaysAsOfRecordTime(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, _ := persistence.OpenAOF(filename, persistence.FsyncNo, nil)
+	for _, rec := range []aofRecord{
+		// The first list expired before the push, which therefore created a new list.
+		{Time: 1000, Op: "rpush", Key: "expired", Values: []interface{}{"old"}},
+		{Time: 1000, Op: "pexpireat", Key: "expired", At: 1500},
+		{Time: 2000, Op: "rpush", Key: "expired", Values: []interface{}{"new"}},
+		// This list was live when pushed to, even though its TTL has elapsed since.
+		{Time: 1000, Op: "rpush", Key: "live", Values: []interface{}{"a"}},
+		{Time: 1000, Op: "pexpireat", Key: "live", At: 5000},
+		{Time: 2000, Op: "rpush", Key: "live", Values: []interface{}{"b"}},
+	} {
+		data, _ := json.Marshal(rec)
+		aof.Append(data)
+	}
+	aof.Close()
+
+	store := NewShardedStore()
+	defer store.Close()
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	if values, _ := store.LRange("expired", 0, -1); !reflect.DeepEqual(values, []interface{}{"new"}) {
+		t.Errorf("Expected only the item pushed after expiry, got %v", values)
+	}
+	if ttl, _ := store.TTL("expired"); ttl != NoExpiration {
+		t.Errorf("Expected the recreated list not to expire, got %v", ttl)
+	}
+	if store.Type("live") != TypeNone {
+		t.Error("Expected the list whose TTL elapsed to be gone after replay")
+	}
+}
+
+func TestAOFRewriteCompactsWhileWritesContinue(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStoreWithConfig(Config{AOFRewritePercentage: -1})
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		store.IncrBy("counter", 1)
+		store.RPush("list", i)
+	}
+	store.LTrim("list", -10, -1)
+	before := store.Stats().AOF.Size
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			store.Set(fmt.Sprintf("during:%d", i), i, 0)
+			store.IncrBy("counter", 1)
+		}
+	}()
+	if err := store.RewriteAOF(); err != nil {
+		t.Fatalf("RewriteAOF failed: %v", err)
+	}
+	<-done
+
+	stats := store.Stats().AOF
+	if stats.Rewrites != 1 || stats.RewriteInProgress || stats.LastRewriteError != "" {
+		t.Errorf("unexpected stats after rewrite: %+v", stats)
+	}
+	if stats.Size >= before {
+		t.Errorf("expected the rewritten log to be smaller than %d bytes, got %d", before, stats.Size)
+	}
+	if err := store.BackgroundRewriteAOF(); err != nil {
+		t.Fatalf("BackgroundRewriteAOF failed: %v", err)
+	}
+
+	restored := reopen(t, store, filename)
+	if value, _ := restored.Get("counter"); fmt.Sprint(value) != "700" {
+		t.Errorf("expected counter 700, got %v", value)
+	}
+	if list, _ := restored.LRange("list", 0, -1); len(list) != 10 || list[0] != 490 {
+		t.Errorf("unexpected list after rewrite: %v", list)
+	}
+	for i := 0; i < 200; i++ {
+		if _, found := restored.Get(fmt.Sprintf("during:%d", i)); !found {
+			t.Fatalf("key written during the rewrite is missing: during:%d", i)
+		}
+	}
+	if _, err := os.Stat(filename + ".rewrite"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the temporary rewrite file to be gone, got %v", err)
+	}
+}
+
+func TestAOFRewriteTriggersOnGrowth(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStoreWithConfig(Config{AOFRewritePercentage: 100, AOFRewriteMinSize: 4096})
+	if err := store.OpenAOF(filename, persistence.FsyncNo); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 200; i++ {
+		store.Set("key", i, 0)
+	}
+	deadline := time.Now().Add(time.Second)
+	for store.Stats().AOF.Rewrites == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the log to be rewritten once it passed the minimum size")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAOFRewriteRequiresAOF(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	if err := store.RewriteAOF(); !errors.Is(err, ErrAOFDisabled) {
+		t.Errorf("expected ErrAOFDisabled, got %v", err)
+	}
+}
+
+func TestAOFDropsWritesAfterClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	store := NewShardedStore()
+	if err := store.OpenAOF(filename, persistence.FsyncAlways); err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	store.Set("before", "1", 0)
+	store.Close()
+	info, _ := os.Stat(filename)
+
+	store.Set("after", "2", 0)
+	if after, _ := os.Stat(filename); after.Size() != info.Size() {
+		t.Errorf("expected the closed log to stay at %d bytes, got %d", info.Size(), after.Size())
+	}
+	restored := reopen(t, NewShardedStore(), filename)
+	if _, found := restored.Get("after"); found {
+		t.Error("expected the write after Close not to be logged")
+	}
+	if _, found := restored.Get("before"); !found {
+		t.Error("expected the write before Close to be replayed")
+	}
+}
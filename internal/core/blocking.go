@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// popWaiter is a client blocked on one or more list keys
+type popWaiter struct {
+	keys   []string
+	left   bool
+	result chan popResult
+}
+
+type popResult struct {
+	key   string
+	value interface{}
+}
+
+// blockedPops tracks waiters per key in arrival order so they are served FIFO
+type blockedPops struct {
+	mutex   sync.Mutex
+	waiters map[string][]*popWaiter
+}
+
+// register queues w on every key it waits for. Caller must hold the mutex.
+func (b *blockedPops) register(w *popWaiter) {
+	if b.waiters == nil {
+		b.waiters = make(map[string][]*popWaiter)
+	}
+	for _, key := range w.keys {
+		b.waiters[key] = append(b.waiters[key], w)
+	}
+}
+
+// unregister removes w from every key and reports whether it was still waiting.
+// Caller must hold the mutex.
+func (b *blockedPops) unregister(w *popWaiter) bool {
+	found := false
+	for _, key := range w.keys {
+		queue := b.waiters[key]
+		for i, waiter := range queue {
+			if waiter == w {
+				queue = append(queue[:i], queue[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(b.waiters, key)
+		} else {
+			b.waiters[key] = queue
+		}
+	}
+	return found
+}
+
+// serveBlocked pops items from the list at key for the clients blocked on it, oldest waiter first.
+func (ss *ShardedStore) serveBlocked(key string) {
+	ss.blocked.mutex.Lock()
+	defer ss.blocked.mutex.Unlock()
+
+	for len(ss.blocked.waiters[key]) > 0 {
+		w := ss.blocked.waiters[key][0]
+		value, found, err := ss.pop(key, w.left)
+		if !found || err != nil {
+			return
+		}
+		ss.blocked.unregister(w)
+		w.result <- popResult{key: key, value: value}
+	}
+}
+
+// BLPop pops the head of the first non-empty list among keys, waiting until an
+// item is pushed or ctx is done. Waiters are served in the order they blocked.
+// It returns ErrWrongType as soon as one of the keys holds another kind of value.
+func (ss *ShardedStore) BLPop(ctx context.Context, keys ...string) (string, interface{}, error) {
+	return ss.blockingPop(ctx, keys, true)
+}
+
+// BRPop pops the tail of the first non-empty list among keys, waiting until an
+// item is pushed or ctx is done. Waiters are served in the order they blocked.
+func (ss *ShardedStore) BRPop(ctx context.Context, keys ...string) (string, interface{}, error) {
+	return ss.blockingPop(ctx, keys, false)
+}
+
+func (ss *ShardedStore) blockingPop(ctx context.Context, keys []string, left bool) (string, interface{}, error) {
+	// Holding the waiter lock while trying the keys guarantees that a push racing with
+	// this call either is seen here or finds the waiter registered.
+	ss.blocked.mutex.Lock()
+	for _, key := range keys {
+		value, found, err := ss.pop(key, left)
+		if err != nil || found {
+			ss.blocked.mutex.Unlock()
+			return key, value, err
+		}
+	}
+	w := &popWaiter{keys: keys, left: left, result: make(chan popResult, 1)}
+	ss.blocked.register(w)
+	ss.blocked.mutex.Unlock()
+
+	select {
+	case res := <-w.result:
+		return res.key, res.value, nil
+	case <-ctx.Done():
+		ss.blocked.mutex.Lock()
+		waiting := ss.blocked.unregister(w)
+		ss.blocked.mutex.Unlock()
+		if !waiting {
+			// An item was handed over while the context expired; don't lose it.
+			res := <-w.result
+			return res.key, res.value, nil
+		}
+		return "", nil, ctx.Err()
+	}
+}
+
+// streamWaiters tracks clients blocked reading streams. Unlike list pops nothing is
+// handed over: every waiter on a key is woken when an entry is added and reads again.
+type streamWaiters struct {
+	mutex   sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+// register subscribes wake to additions on keys. Caller must hold the mutex.
+func (b *streamWaiters) register(keys []string, wake chan struct{}) {
+	if b.waiters == nil {
+		b.waiters = make(map[string]map[chan struct{}]struct{})
+	}
+	for _, key := range keys {
+		if b.waiters[key] == nil {
+			b.waiters[key] = make(map[chan struct{}]struct{})
+		}
+		b.waiters[key][wake] = struct{}{}
+	}
+}
+
+// unregister removes wake from keys. Caller must hold the mutex.
+func (b *streamWaiters) unregister(keys []string, wake chan struct{}) {
+	for _, key := range keys {
+		delete(b.waiters[key], wake)
+		if len(b.waiters[key]) == 0 {
+			delete(b.waiters, key)
+		}
+	}
+}
+
+// wakeStreamReaders wakes the clients blocked reading the stream at key.
+func (ss *ShardedStore) wakeStreamReaders(key string) {
+	ss.streamReaders.mutex.Lock()
+	defer ss.streamReaders.mutex.Unlock()
+
+	for wake := range ss.streamReaders.waiters[key] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// blockingRead calls read until it returns entries or an error, waiting for additions to
+// keys in between when block is set. read returns nil when there is nothing to deliver.
+func (ss *ShardedStore) blockingRead(ctx context.Context, keys []string, block bool, read func() (map[string][]StreamEntry, error)) (map[string][]StreamEntry, error) {
+	wake := make(chan struct{}, 1)
+	for {
+		// As with blocking pops, reading under the waiter lock guarantees that an
+		// addition racing with the read either is seen or finds the waiter registered.
+		ss.streamReaders.mutex.Lock()
+		result, err := read()
+		if err != nil || result != nil || !block {
+			ss.streamReaders.mutex.Unlock()
+			return result, err
+		}
+		ss.streamReaders.register(keys, wake)
+		ss.streamReaders.mutex.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+		}
+		ss.streamReaders.mutex.Lock()
+		ss.streamReaders.unregister(keys, wake)
+		ss.streamReaders.mutex.Unlock()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
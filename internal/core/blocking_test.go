@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBLPopReturnsAvailableItem(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.RPush("jobs", "job1")
+
+	key, value, err := store.BLPop(context.Background(), "empty", "jobs")
+	if err != nil || key != "jobs" || value != "job1" {
+		t.Errorf("Expected jobs/job1, got %s/%v (%v)", key, value, err)
+	}
+}
+
+func TestBLPopWaitsForPush(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.RPush("queue:b", "job")
+	}()
+
+	key, value, err := store.BLPop(context.Background(), "queue:a", "queue:b")
+	if err != nil || key != "queue:b" || value != "job" {
+		t.Errorf("Expected queue:b/job, got %s/%v (%v)", key, value, err)
+	}
+	if store.Type("queue:b") != TypeNone {
+		t.Error("Expected the pushed item to be handed to the waiter and the empty list removed")
+	}
+}
+
+func TestBRPopTimeout(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err := store.BRPop(ctx, "nothing")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if len(store.blocked.waiters) != 0 {
+		t.Error("Expected the timed out waiter to be unregistered")
+	}
+}
+
+func TestBlockingPopServesWaitersFIFO(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	results := make(chan string, 3)
+	for i, name := range []string{"first", "second", "third"} {
+		go func(name string) {
+			_, value, _ := store.BLPop(context.Background(), "fifo")
+			results <- name + "=" + value.(string)
+		}(name)
+		// Make sure waiters block in a known order.
+		for waiting := 0; waiting != i+1; time.Sleep(time.Millisecond) {
+			store.blocked.mutex.Lock()
+			waiting = len(store.blocked.waiters["fifo"])
+			store.blocked.mutex.Unlock()
+		}
+	}
+
+	store.RPush("fifo", "a", "b", "c")
+
+	expected := []string{"first=a", "second=b", "third=c"}
+	got := map[string]bool{}
+	for range expected {
+		got[<-results] = true
+	}
+	for _, e := range expected {
+		if !got[e] {
+			t.Errorf("Expected %s, got %v", e, got)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+// EvictionPolicy selects which keys are removed when the store exceeds its budget
+type EvictionPolicy string
+
+const (
+	NoEviction  EvictionPolicy = "noeviction"   // reject writes once the budget is reached
+	AllKeysLRU  EvictionPolicy = "allkeys-lru"  // evict the least recently used key
+	AllKeysLFU  EvictionPolicy = "allkeys-lfu"  // evict the least frequently used key
+	VolatileLRU EvictionPolicy = "volatile-lru" // evict the least recently used key that has a TTL
+	VolatileTTL EvictionPolicy = "volatile-ttl" // evict the key with a TTL that expires soonest
+)
+
+// ErrOutOfMemory is returned by writes when the budget is exhausted and nothing can be evicted
+var ErrOutOfMemory = errors.New("OOM command not allowed when used memory > maxmemory")
+
+const (
+	// evictionSamples is the number of candidates inspected per shard when choosing a victim
+	evictionSamples = 5
+
+	// maxEvictionAttempts bounds how many victims a single write may try to evict
+	maxEvictionAttempts = 1024
+
+	// entryOverhead approximates the map bucket, Entry header and index bookkeeping per key
+	entryOverhead = 64
+
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// Config bounds the size of a ShardedStore. A zero limit disables that bound.
+type Config struct {
+	MaxKeys        int64          // maximum number of keys
+	MaxMemory      int64          // maximum estimated memory in bytes
+	EvictionPolicy EvictionPolicy // defaults to NoEviction
+	ExpireInterval time.Duration  // defaults to DefaultExpireInterval
+
+	SubscriberBuffer int // messages queued per Pub/Sub subscriber, defaults to DefaultSubscriberBuffer
+	EventQueue       int // keyspace events queued for slow listeners, defaults to DefaultEventQueue
+
+	// AOFRewritePercentage is how much the append-only file must grow over its size after
+	// the last rewrite before it is rewritten automatically. It defaults to
+	// DefaultAOFRewritePercentage; a negative value disables automatic rewrites.
+	AOFRewritePercentage int
+	AOFRewriteMinSize    int64 // smallest file rewritten automatically, defaults to DefaultAOFRewriteMinSize
+
+	// Encryption encrypts snapshots and the append-only file with its current key, and
+	// decrypts them with the key they were written with. Nil leaves them unencrypted.
+	Encryption *persistence.Keyring
+}
+
+// ParseEvictionPolicy validates a policy name such as "allkeys-lru".
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(name); policy {
+	case NoEviction, AllKeysLRU, AllKeysLFU, VolatileLRU, VolatileTTL:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q", name)
+}
+
+// Stats is a point-in-time view of the store size and housekeeping counters
+type Stats struct {
+	Keys           int64          `json:"keys"`
+	UsedMemory     int64          `json:"used_memory"`
+	MaxKeys        int64          `json:"max_keys"`
+	MaxMemory      int64          `json:"max_memory"`
+	EvictionPolicy EvictionPolicy `json:"eviction_policy"`
+	EvictedKeys    int64          `json:"evicted_keys"`
+	ExpiredKeys    int64          `json:"expired_keys"`
+	Snapshot       SnapshotStats  `json:"snapshot"`
+	AOF            *AOFStats      `json:"aof,omitempty"` // nil when the append-only file is disabled
+}
+
+// Stats returns the current key count, memory estimate, eviction counters and the state
+// of snapshots and the append-only file.
+func (ss *ShardedStore) Stats() Stats {
+	stats := Stats{
+		Keys:           ss.keyCount(),
+		UsedMemory:     ss.usedMemory(),
+		MaxKeys:        ss.config.MaxKeys,
+		MaxMemory:      ss.config.MaxMemory,
+		EvictionPolicy: ss.config.EvictionPolicy,
+		EvictedKeys:    ss.evictions.Load(),
+		ExpiredKeys:    ss.expiredKeys.Load(),
+		Snapshot:       ss.SnapshotStats(),
+	}
+	if ss.aof != nil {
+		stats.AOF = ss.aof.stats()
+	}
+	return stats
+}
+
+// keyCount returns the number of keys across all shards, including not yet reclaimed expired keys.
+func (ss *ShardedStore) keyCount() int64 {
+	var total int64
+	for i := 0; i < ShardCount; i++ {
+		shard := &ss.shards[i]
+		shard.mutex.RLock()
+		total += int64(len(shard.data))
+		shard.mutex.RUnlock()
+	}
+	return total
+}
+
+// usedMemory returns the estimated memory held by all shards.
+func (ss *ShardedStore) usedMemory() int64 {
+	var total int64
+	for i := 0; i < ShardCount; i++ {
+		total += ss.shards[i].used.Load()
+	}
+	return total
+}
+
+// overBudget reports whether writing keys would exceed the configured limits.
+func (ss *ShardedStore) overBudget(keys []string) bool {
+	if ss.config.MaxMemory > 0 && ss.usedMemory() >= ss.config.MaxMemory {
+		return true
+	}
+	if ss.config.MaxKeys > 0 {
+		missing := int64(0)
+		for _, key := range keys {
+			shard := ss.getShard(key)
+			shard.mutex.RLock()
+			if _, exists := shard.data[key]; !exists {
+				missing++
+			}
+			shard.mutex.RUnlock()
+		}
+		return missing > 0 && ss.keyCount()+missing > ss.config.MaxKeys
+	}
+	return false
+}
+
+// ensureCapacity evicts keys according to the policy until keys can be written.
+// It must be called before the shard locks for keys are taken. Records replayed from the
+// AOF are always written; see evictToBudget.
+func (ss *ShardedStore) ensureCapacity(keys ...string) error {
+	if ss.replaying.Load() || (ss.config.MaxKeys <= 0 && ss.config.MaxMemory <= 0) {
+		return nil
+	}
+
+	for attempt := 0; ss.overBudget(keys); attempt++ {
+		if ss.config.EvictionPolicy == NoEviction || attempt == maxEvictionAttempts {
+			return ErrOutOfMemory
+		}
+		if !ss.evictOne() {
+			return ErrOutOfMemory
+		}
+	}
+	return nil
+}
+
+// evictToBudget evicts keys according to the policy until the store fits its limits
+// again, after a replay that ignored them. Under noeviction, or when no key is eligible,
+// the store stays over budget and writes fail with ErrOutOfMemory until keys are deleted.
+func (ss *ShardedStore) evictToBudget() {
+	if ss.config.EvictionPolicy == NoEviction {
+		return
+	}
+	for ss.exceedsBudget() && ss.evictOne() {
+	}
+}
+
+// exceedsBudget reports whether the store holds more than the configured limits
+func (ss *ShardedStore) exceedsBudget() bool {
+	return (ss.config.MaxMemory > 0 && ss.usedMemory() > ss.config.MaxMemory) ||
+		(ss.config.MaxKeys > 0 && ss.keyCount() > ss.config.MaxKeys)
+}
+
+// evictionCandidate is the best victim found while sampling the shards
+type evictionCandidate struct {
+	shard      *Store
+	key        string
+	score      float64
+	lastAccess int64
+}
+
+// evictOne samples every shard and removes the best victim for the policy.
+// It returns false when no key is eligible, e.g. a volatile policy with no TTL keys.
+func (ss *ShardedStore) evictOne() bool {
+	now := time.Now()
+	volatile := ss.config.EvictionPolicy == VolatileLRU || ss.config.EvictionPolicy == VolatileTTL
+
+	var best *evictionCandidate
+	start := rand.Intn(ShardCount)
+	for i := 0; i < ShardCount; i++ {
+		shard := &ss.shards[(start+i)%ShardCount]
+		shard.mutex.RLock()
+		sampled := 0
+		consider := func(key string, entry Entry) {
+			score := ss.evictionScore(entry, now)
+			if best == nil || score < best.score {
+				best = &evictionCandidate{shard: shard, key: key, score: score, lastAccess: entry.access.last.Load()}
+			}
+		}
+		if volatile {
+			for key := range shard.expires {
+				if sampled == evictionSamples {
+					break
+				}
+				consider(key, shard.data[key])
+				sampled++
+			}
+		} else {
+			for key, entry := range shard.data {
+				if sampled == evictionSamples {
+					break
+				}
+				consider(key, entry)
+				sampled++
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+
+	if best == nil {
+		return false
+	}
+
+	best.shard.mutex.Lock()
+	defer best.shard.mutex.Unlock()
+	// The victim may have been touched or removed since it was sampled; the caller retries.
+	if entry, found := best.shard.data[best.key]; found && entry.access.last.Load() == best.lastAccess {
+		best.shard.remove(best.key)
+		best.shard.events.emit(best.key, EventEvicted)
+		best.shard.aof.appendDel(best.key)
+		ss.evictions.Add(1)
+	}
+	return true
+}
+
+// evictionScore ranks an entry for the configured policy; the lowest score is evicted first.
+func (ss *ShardedStore) evictionScore(entry Entry, now time.Time) float64 {
+	switch ss.config.EvictionPolicy {
+	case AllKeysLFU:
+		// Break frequency ties by recency so that cold keys go first.
+		return float64(entry.access.decayed(now))*1e19 + float64(entry.access.last.Load())
+	case VolatileTTL:
+		return float64(entry.Expiration)
+	default:
+		return float64(entry.access.last.Load())
+	}
+}
+
+// accessStats is the eviction metadata of an entry. Its fields are atomic so that reads
+// can record themselves under the shard read lock.
+type accessStats struct {
+	last      atomic.Int64 // Unix nanoseconds of the last read or write, used by LRU eviction
+	frequency atomic.Int32 // logarithmic access counter, used by LFU eviction
+}
+
+func newAccessStats() *accessStats {
+	a := &accessStats{}
+	a.last.Store(time.Now().UnixNano())
+	a.frequency.Store(lfuInitVal)
+	return a
+}
+
+// touch records a read or write access on an entry. Caller must hold the read or write
+// lock. Concurrent reads of the same key may lose an increment of the counter, which is
+// probabilistic anyway.
+func (s *Store) touch(key string, entry Entry) {
+	now := time.Now()
+	entry.access.frequency.Store(int32(lfuIncr(entry.access.decayed(now))))
+	entry.access.last.Store(now.UnixNano())
+}
+
+// lfuIncr increments a logarithmic access counter: the higher the counter,
+// the less likely an access is to bump it, so 255 represents millions of hits.
+func lfuIncr(counter uint8) uint8 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+	base := math.Max(float64(counter)-lfuInitVal, 0)
+	if rand.Float64() < 1.0/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// decayed returns the access counter lowered by one for every lfuDecayTime period since
+// the last access.
+func (a *accessStats) decayed(now time.Time) uint8 {
+	frequency := uint8(a.frequency.Load())
+	periods := now.Sub(time.Unix(0, a.last.Load())) / lfuDecayTime
+	if periods >= time.Duration(frequency) {
+		return 0
+	}
+	return frequency - uint8(periods)
+}
+
+// adjust records an in-place change of the value at key: it bumps the version
+// and changes the memory charged by delta. Caller must hold the write lock.
+func (s *Store) adjust(key string, delta int64) {
+	entry := s.data[key]
+	entry.Version = s.versions.Add(1)
+	s.changes.Add(1)
+	entry.size += delta
+	s.used.Add(delta)
+	s.data[key] = entry
+}
+
+// resize records a bulk in-place change of the value at key and recomputes the memory
+// charged for it. Caller must hold the write lock.
+func (s *Store) resize(key string) {
+	entry := s.data[key]
+	s.adjust(key, entrySize(key, entry.Value)-entry.size)
+}
+
+// itemsSize estimates the memory held by collection items.
+func itemsSize(items ...interface{}) int64 {
+	var size int64
+	for _, item := range items {
+		size += valueSize(item) + 16
+	}
+	return size
+}
+
+// fieldSize estimates the memory held by one field of a hash.
+func fieldSize(field string, value interface{}) int64 {
+	return int64(len(field)) + 16 + valueSize(value) + 16
+}
+
+// membersSize estimates the memory held by set members.
+func membersSize(members []string) int64 {
+	var size int64
+	for _, member := range members {
+		size += int64(len(member)) + 32
+	}
+	return size
+}
+
+// zmemberSize estimates the memory held by a sorted set member, its score and its skip list node.
+func zmemberSize(member string) int64 {
+	return int64(len(member)) + 80
+}
+
+// streamEntrySize estimates the memory held by a stream entry, its ID and its fields.
+func streamEntrySize(fields map[string]interface{}) int64 {
+	return 16 + valueSize(fields)
+}
+
+// entrySize estimates the memory held by a key and its value.
+func entrySize(key string, value interface{}) int64 {
+	return int64(len(key)) + entryOverhead + valueSize(value)
+}
+
+// valueSize estimates the memory held by a value decoded from JSON or stored by the core types.
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string:
+		return int64(len(v)) + 16
+	case []byte:
+		return int64(len(v)) + 24
+	case []interface{}:
+		size := int64(24)
+		for _, item := range v {
+			size += valueSize(item) + 16
+		}
+		return size
+	case map[string]interface{}:
+		size := int64(48)
+		for field, item := range v {
+			size += fieldSize(field, item)
+		}
+		return size
+	case *List:
+		// Pushes and pops adjust the charge incrementally, see Store.adjust.
+		return valueSize(v.GetAll())
+	case *Hash:
+		return valueSize(v.GetAll())
+	case *Set:
+		return 48 + membersSize(v.Members())
+	case *ZSet:
+		size := int64(64)
+		for _, m := range v.Members() {
+			size += zmemberSize(m.Member)
+		}
+		return size
+	case *Stream:
+		size := int64(96)
+		for _, entry := range v.Range(StreamID{}, MaxStreamID, 0) {
+			size += streamEntrySize(entry.Fields)
+		}
+		return size
+	default:
+		return 8
+	}
+}
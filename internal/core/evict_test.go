@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNoEvictionRejectsWrites(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 2, EvictionPolicy: NoEviction})
+	defer store.Close()
+
+	store.Set("a", "1", 0)
+	store.Set("b", "2", 0)
+
+	if err := store.Set("c", "3", 0); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("Expected ErrOutOfMemory, got %v", err)
+	}
+	if err := store.Set("a", "updated", 0); err != nil {
+		t.Errorf("Expected overwrite of an existing key to succeed, got %v", err)
+	}
+}
+
+func TestAllKeysLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 3, EvictionPolicy: AllKeysLRU})
+	defer store.Close()
+
+	store.Set("old", "1", 0)
+	time.Sleep(time.Millisecond)
+	store.Set("b", "2", 0)
+	store.Set("c", "3", 0)
+	store.Get("b")
+	store.Get("c")
+
+	if err := store.Set("d", "4", 0); err != nil {
+		t.Fatalf("Expected eviction to make room, got %v", err)
+	}
+	if _, found := store.Get("old"); found {
+		t.Error("Expected 'old' to be evicted")
+	}
+	if stats := store.Stats(); stats.EvictedKeys != 1 || stats.Keys != 3 {
+		t.Errorf("Expected 1 eviction and 3 keys, got %+v", stats)
+	}
+}
+
+func TestVolatileTTLEvictsSoonestExpiring(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 3, EvictionPolicy: VolatileTTL})
+	defer store.Close()
+
+	store.Set("persistent", "1", 0)
+	store.Set("later", "2", 600)
+	store.Set("sooner", "3", 60)
+
+	store.Set("new", "4", 0)
+	if _, found := store.Get("sooner"); found {
+		t.Error("Expected 'sooner' to be evicted")
+	}
+	if _, found := store.Get("persistent"); !found {
+		t.Error("Expected keys without TTL to be kept")
+	}
+}
+
+func TestVolatilePolicyWithoutTTLKeys(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 1, EvictionPolicy: VolatileLRU})
+	defer store.Close()
+
+	store.Set("a", "1", 0)
+	if err := store.Set("b", "2", 0); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("Expected ErrOutOfMemory, got %v", err)
+	}
+}
+
+func TestMaxMemoryBudget(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxMemory: 4096, EvictionPolicy: AllKeysLFU})
+	defer store.Close()
+
+	for i := 0; i < 200; i++ {
+		if err := store.Set(fmt.Sprintf("key%d", i), "some value", 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	stats := store.Stats()
+	if stats.EvictedKeys == 0 {
+		t.Error("Expected keys to be evicted")
+	}
+	if stats.UsedMemory > 4096+entrySize("key199", "some value") {
+		t.Errorf("Expected memory to stay within budget, got %d", stats.UsedMemory)
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	if _, err := ParseEvictionPolicy("allkeys-lru"); err != nil {
+		t.Errorf("Expected valid policy, got %v", err)
+	}
+	if _, err := ParseEvictionPolicy("random"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestGetRecordsAccessUnderReadLock(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 10, EvictionPolicy: AllKeysLRU})
+	defer store.Close()
+	store.Set("key", "value", 0)
+	shard := store.getShard("key")
+	before := shard.data["key"].access.last.Load()
+
+	// Another reader holds the shard; Get must not wait for it
+	shard.mutex.RLock()
+	done := make(chan struct{})
+	go func() {
+		store.Get("key")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked behind a reader")
+	}
+	shard.mutex.RUnlock()
+
+	if after := shard.data["key"].access.last.Load(); after <= before {
+		t.Errorf("Expected the read to be recorded, last access %d -> %d", before, after)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"time"
+)
+
+const (
+	// DefaultExpireInterval is how often the background cycle looks for expired keys
+	DefaultExpireInterval = 100 * time.Millisecond
+
+	// expireSampleSize is the number of keys with a TTL inspected per shard and pass
+	expireSampleSize = 20
+
+	// expireMaxPasses bounds the work done on one shard in a single cycle
+	expireMaxPasses = 16
+)
+
+// startExpiration launches the goroutine that actively removes expired keys.
+func (ss *ShardedStore) startExpiration(interval time.Duration) {
+	ss.stopExpire = make(chan struct{})
+	ss.expireDone = make(chan struct{})
+
+	go func() {
+		defer close(ss.expireDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ss.stopExpire:
+				return
+			case <-ticker.C:
+				ss.activeExpireCycle()
+			}
+		}
+	}()
+}
+
+// activeExpireCycle samples keys with a TTL on every shard and deletes the expired ones.
+// A shard is sampled again while more than a quarter of its sample turned out to be expired,
+// so bursts of expirations are reclaimed quickly without scanning the whole keyspace.
+func (ss *ShardedStore) activeExpireCycle() {
+	for i := 0; i < ShardCount; i++ {
+		shard := &ss.shards[i]
+		for pass := 0; pass < expireMaxPasses; pass++ {
+			sampled, expired := shard.expireSample(time.Now().UnixMilli())
+			ss.expiredKeys.Add(int64(expired))
+			if sampled == 0 || expired*4 <= sampled {
+				break
+			}
+		}
+	}
+}
+
+// expireSample inspects up to expireSampleSize keys from the expiration index and
+// removes those that are past their deadline. Map iteration order is randomized,
+// which gives a different sample on every pass.
+func (s *Store) expireSample(now int64) (sampled, expired int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, expiration := range s.expires {
+		if sampled == expireSampleSize {
+			break
+		}
+		sampled++
+		if now > expiration {
+			s.remove(key)
+			s.events.emit(key, EventExpired)
+			s.aof.appendDel(key)
+			expired++
+		}
+	}
+	return sampled, expired
+}
+
+// ExpiredKeys returns the number of keys removed by the background expiration cycle.
+func (ss *ShardedStore) ExpiredKeys() int64 {
+	return ss.expiredKeys.Load()
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveExpirationRemovesKeys(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(key, "value", 1)
+	}
+	store.Set("persistent", "value", 0)
+	time.Sleep(2500 * time.Millisecond)
+
+	for _, key := range []string{"a", "b", "c"} {
+		shard := store.getShard(key)
+		shard.mutex.RLock()
+		_, inData := shard.data[key]
+		_, inIndex := shard.expires[key]
+		shard.mutex.RUnlock()
+		if inData || inIndex {
+			t.Errorf("Expected '%s' to be removed by the expiration cycle", key)
+		}
+	}
+
+	if _, found := store.Get("persistent"); !found {
+		t.Error("Expected 'persistent' to survive the expiration cycle")
+	}
+	if store.ExpiredKeys() != 3 {
+		t.Errorf("Expected 3 expired keys, got %d", store.ExpiredKeys())
+	}
+}
+
+func TestOverwriteClearsExpiration(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("key", "value", 1)
+	store.Set("key", "value", 0)
+
+	shard := store.getShard("key")
+	shard.mutex.RLock()
+	_, indexed := shard.expires["key"]
+	shard.mutex.RUnlock()
+	if indexed {
+		t.Error("Expected overwrite without TTL to clear the expiration index")
+	}
+}
+
+func TestCloseIsIdempotent(t *testing.T) {
+	store := NewShardedStore()
+	store.Close()
+	store.Close()
+}
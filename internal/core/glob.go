@@ -0,0 +1,91 @@
+package core
+
+// globMatch reports whether s matches a Redis-style glob pattern:
+// '*' matches any sequence, '?' any single character, '[abc]', '[a-z]' and
+// '[^a]' match character classes and '\' escapes the next character.
+// Unlike path.Match, '/' is not special.
+func globMatch(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	// Positions to resume from after the last '*', for backtracking.
+	starP, starS := -1, 0
+	i, j := 0, 0
+
+	for j < len(str) {
+		if i < len(p) {
+			switch p[i] {
+			case '*':
+				starP, starS = i, j
+				i++
+				continue
+			case '?':
+				i++
+				j++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(p, i, str[j]); ok && matched {
+					i = next
+					j++
+					continue
+				}
+			case '\\':
+				if i+1 < len(p) && p[i+1] == str[j] {
+					i += 2
+					j++
+					continue
+				}
+			default:
+				if p[i] == str[j] {
+					i++
+					j++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the last '*' absorb one more character and retry.
+		starS++
+		i, j = starP+1, starS
+	}
+
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// matchClass matches c against the character class starting at p[start] == '['.
+// It returns whether c matched, the index after the class and whether the class is well formed.
+func matchClass(p []rune, start int, c rune) (bool, int, bool) {
+	i := start + 1
+	negate := i < len(p) && p[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for first := true; i < len(p) && (first || p[i] != ']'); first = false {
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			i += 2
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+		i++
+	}
+	if i >= len(p) {
+		return false, 0, false
+	}
+	return matched != negate, i + 1, true
+}
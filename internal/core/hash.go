@@ -0,0 +1,107 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Hash is a concurrency-safe map of fields to values stored under a single key.
+type Hash struct {
+	mutex  sync.RWMutex
+	fields map[string]interface{}
+	order  *skipList // every field in lexical order, for Scan
+}
+
+func NewHash() *Hash {
+	return &Hash{fields: make(map[string]interface{}), order: newSkipList()}
+}
+
+// Set writes value to field and returns the previous value, if any
+func (h *Hash) Set(field string, value interface{}) (interface{}, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	old, found := h.fields[field]
+	h.fields[field] = value
+	if !found {
+		h.order.insert(0, field)
+	}
+	return old, found
+}
+
+// Get returns the value of field
+func (h *Hash) Get(field string) (interface{}, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	value, found := h.fields[field]
+	return value, found
+}
+
+// Del removes field and returns its value, if it existed
+func (h *Hash) Del(field string) (interface{}, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	value, found := h.fields[field]
+	if found {
+		delete(h.fields, field)
+		h.order.delete(0, field)
+	}
+	return value, found
+}
+
+// Len returns the number of fields
+func (h *Hash) Len() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.fields)
+}
+
+// GetAll returns a copy of all fields and their values
+func (h *Hash) GetAll() map[string]interface{} {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	fields := make(map[string]interface{}, len(h.fields))
+	for field, value := range h.fields {
+		fields[field] = value
+	}
+	return fields
+}
+
+// Keys returns the field names in lexical order
+func (h *Hash) Keys() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	keys := make([]string, 0, len(h.fields))
+	for x := h.order.header.level[0].forward; x != nil; x = x.level[0].forward {
+		keys = append(keys, x.member)
+	}
+	return keys
+}
+
+// Scan examines up to count fields in lexical order, starting after the given field,
+// and returns those matching the glob pattern match, the last examined field and
+// whether fields remain.
+func (h *Hash) Scan(after string, hasAfter bool, count int, match string) (map[string]interface{}, string, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	fields := make(map[string]interface{})
+	last := after
+	x := h.order.firstInLexRange(LexRange{Min: after, MinExclusive: hasAfter, MinUnbounded: !hasAfter, MaxUnbounded: true})
+	for examined := 0; x != nil && examined < count; x, examined = x.level[0].forward, examined+1 {
+		if match == "" || globMatch(match, x.member) {
+			fields[x.member] = h.fields[x.member]
+		}
+		last = x.member
+	}
+	return fields, last, x != nil
+}
+
+// MarshalJSON encodes the hash as a JSON object
+func (h *Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.GetAll())
+}
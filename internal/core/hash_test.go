@@ -0,0 +1,36 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashSetGetDel(t *testing.T) {
+	hash := NewHash()
+	if _, found := hash.Set("name", "alice"); found {
+		t.Error("Expected 'name' to be a new field")
+	}
+	if old, found := hash.Set("name", "bob"); !found || old != "alice" {
+		t.Errorf("Expected previous value 'alice', got %v", old)
+	}
+	hash.Set("age", 30)
+
+	if value, _ := hash.Get("name"); value != "bob" {
+		t.Errorf("Expected 'bob', got %v", value)
+	}
+	if !reflect.DeepEqual(hash.Keys(), []string{"age", "name"}) {
+		t.Errorf("Expected sorted keys, got %v", hash.Keys())
+	}
+	if _, found := hash.Del("age"); !found || hash.Len() != 1 {
+		t.Errorf("Expected one field left, got %d", hash.Len())
+	}
+}
+
+func TestHashMarshalJSON(t *testing.T) {
+	hash := NewHash()
+	hash.Set("a", 1)
+	data, err := hash.MarshalJSON()
+	if err != nil || string(data) != `{"a":1}` {
+		t.Errorf("Expected {\"a\":1}, got %s (%v)", data, err)
+	}
+}
@@ -1,26 +1,296 @@
 package core
 
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrIndexOutOfRange is returned when a list index does not address an existing element
+var ErrIndexOutOfRange = errors.New("index out of range")
+
+// List is a concurrency-safe double-ended queue backed by a ring buffer,
+// so pushes and pops at either end run in constant time.
 type List struct {
+	mutex  sync.RWMutex
 	values []interface{}
+	head   int
+	length int
 }
 
 func NewList() *List {
 	return &List{values: make([]interface{}, 0)}
 }
 
+// Push appends a value to the tail of the list
 func (l *List) Push(value interface{}) {
-	l.values = append(l.values, value)
+	l.RPush(value)
 }
 
+// Pop removes and returns the value at the tail of the list
 func (l *List) Pop() (interface{}, bool) {
-	if len(l.values) == 0 {
+	return l.RPop()
+}
+
+// GetAll returns a copy of all values from head to tail
+func (l *List) GetAll() []interface{} {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.slice(0, l.length)
+}
+
+// LPush inserts values at the head one after another, so the last value ends up first.
+// It returns the new length.
+func (l *List) LPush(values ...interface{}) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, value := range values {
+		l.grow()
+		l.head = (l.head - 1 + len(l.values)) % len(l.values)
+		l.values[l.head] = value
+		l.length++
+	}
+	return l.length
+}
+
+// RPush appends values at the tail and returns the new length
+func (l *List) RPush(values ...interface{}) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, value := range values {
+		l.grow()
+		l.values[l.pos(l.length)] = value
+		l.length++
+	}
+	return l.length
+}
+
+// LPop removes and returns the value at the head
+func (l *List) LPop() (interface{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.length == 0 {
 		return nil, false
 	}
-	val := l.values[len(l.values)-1]
-	l.values = l.values[:len(l.values)-1]
-	return val, true
+	value := l.values[l.head]
+	l.values[l.head] = nil
+	l.head = l.pos(1)
+	l.length--
+	return value, true
 }
 
-func (l *List) GetAll() []interface{} {
-	return l.values
+// RPop removes and returns the value at the tail
+func (l *List) RPop() (interface{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.length == 0 {
+		return nil, false
+	}
+	tail := l.pos(l.length - 1)
+	value := l.values[tail]
+	l.values[tail] = nil
+	l.length--
+	return value, true
+}
+
+// Len returns the number of values in the list
+func (l *List) Len() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.length
+}
+
+// Range returns the values between start and stop inclusive.
+// Negative indexes count from the tail, so Range(0, -1) returns the whole list.
+func (l *List) Range(start, stop int) []interface{} {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	start, stop, ok := l.clamp(start, stop)
+	if !ok {
+		return []interface{}{}
+	}
+	return l.slice(start, stop+1)
+}
+
+// Index returns the value at index; negative indexes count from the tail
+func (l *List) Index(index int) (interface{}, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	index, ok := l.normalize(index)
+	if !ok {
+		return nil, false
+	}
+	return l.values[l.pos(index)], true
+}
+
+// Set replaces the value at index; negative indexes count from the tail
+func (l *List) Set(index int, value interface{}) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	index, ok := l.normalize(index)
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	l.values[l.pos(index)] = value
+	return nil
+}
+
+// Insert places value before or after the first occurrence of pivot.
+// It returns the new length, or -1 when pivot is not in the list.
+func (l *List) Insert(before bool, pivot, value interface{}) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i := 0; i < l.length; i++ {
+		if !reflect.DeepEqual(l.values[l.pos(i)], pivot) {
+			continue
+		}
+		if !before {
+			i++
+		}
+		values := l.slice(0, l.length)
+		values = append(values[:i], append([]interface{}{value}, values[i:]...)...)
+		l.reset(values)
+		return l.length
+	}
+	return -1
+}
+
+// Rem removes up to count occurrences of value: from the head when count is positive,
+// from the tail when it is negative and all of them when it is zero.
+// It returns the number of removed values.
+func (l *List) Rem(count int, value interface{}) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	values := l.slice(0, l.length)
+	removed := 0
+	matches := func(i int) bool {
+		return (count == 0 || removed < abs(count)) && reflect.DeepEqual(values[i], value)
+	}
+
+	kept := make([]interface{}, 0, len(values))
+	if count >= 0 {
+		for i := range values {
+			if matches(i) {
+				removed++
+				continue
+			}
+			kept = append(kept, values[i])
+		}
+	} else {
+		for i := len(values) - 1; i >= 0; i-- {
+			if matches(i) {
+				removed++
+				continue
+			}
+			kept = append(kept, values[i])
+		}
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+	}
+
+	if removed > 0 {
+		l.reset(kept)
+	}
+	return removed
+}
+
+// Trim keeps only the values between start and stop inclusive; negative indexes count from the tail
+func (l *List) Trim(start, stop int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	start, stop, ok := l.clamp(start, stop)
+	if !ok {
+		l.reset(nil)
+		return
+	}
+	l.reset(l.slice(start, stop+1))
+}
+
+// MarshalJSON encodes the list as a JSON array from head to tail
+func (l *List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.GetAll())
+}
+
+// pos maps a logical index to a position in the ring buffer. Caller must hold the lock.
+func (l *List) pos(index int) int {
+	return (l.head + index) % len(l.values)
+}
+
+// grow doubles the ring buffer when it is full. Caller must hold the write lock.
+func (l *List) grow() {
+	if l.length < len(l.values) {
+		return
+	}
+	size := len(l.values) * 2
+	if size == 0 {
+		size = 4
+	}
+	values := make([]interface{}, size)
+	copy(values, l.slice(0, l.length))
+	l.values = values
+	l.head = 0
+}
+
+// slice copies the logical range [from, to) out of the ring buffer. Caller must hold the lock.
+func (l *List) slice(from, to int) []interface{} {
+	values := make([]interface{}, 0, to-from)
+	for i := from; i < to; i++ {
+		values = append(values, l.values[l.pos(i)])
+	}
+	return values
+}
+
+// reset replaces the contents with values. Caller must hold the write lock.
+func (l *List) reset(values []interface{}) {
+	if values == nil {
+		values = make([]interface{}, 0)
+	}
+	l.values = values
+	l.head = 0
+	l.length = len(values)
+}
+
+// normalize resolves a possibly negative index. Caller must hold the lock.
+func (l *List) normalize(index int) (int, bool) {
+	if index < 0 {
+		index += l.length
+	}
+	return index, index >= 0 && index < l.length
+}
+
+// clamp resolves a possibly negative inclusive range and reports whether it is non-empty.
+// Caller must hold the lock.
+func (l *List) clamp(start, stop int) (int, int, bool) {
+	if start < 0 {
+		start += l.length
+	}
+	if stop < 0 {
+		stop += l.length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= l.length {
+		stop = l.length - 1
+	}
+	return start, stop, start <= stop && start < l.length
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -66,3 +68,127 @@ func TestListEmpty(t *testing.T) {
 		t.Errorf("Expected empty list, got %v", items)
 	}
 }
+
+func TestListDequeOperations(t *testing.T) {
+	list := NewList()
+
+	list.RPush("b", "c")
+	if n := list.LPush("a", "z"); n != 4 {
+		t.Errorf("Expected length 4, got %d", n)
+	}
+
+	if items := list.Range(0, -1); !reflect.DeepEqual(items, []interface{}{"z", "a", "b", "c"}) {
+		t.Errorf("Expected [z a b c], got %v", items)
+	}
+
+	item, found := list.LPop()
+	if !found || item != "z" {
+		t.Errorf("Expected 'z', got %v", item)
+	}
+	item, found = list.RPop()
+	if !found || item != "c" {
+		t.Errorf("Expected 'c', got %v", item)
+	}
+
+	if list.Len() != 2 {
+		t.Errorf("Expected length 2, got %d", list.Len())
+	}
+}
+
+func TestListRangeIndexSet(t *testing.T) {
+	list := NewList()
+	list.RPush("a", "b", "c", "d", "e")
+
+	if items := list.Range(-3, -2); !reflect.DeepEqual(items, []interface{}{"c", "d"}) {
+		t.Errorf("Expected [c d], got %v", items)
+	}
+	if items := list.Range(3, 100); !reflect.DeepEqual(items, []interface{}{"d", "e"}) {
+		t.Errorf("Expected [d e], got %v", items)
+	}
+	if items := list.Range(4, 2); len(items) != 0 {
+		t.Errorf("Expected empty range, got %v", items)
+	}
+
+	if item, found := list.Index(-1); !found || item != "e" {
+		t.Errorf("Expected 'e', got %v", item)
+	}
+	if _, found := list.Index(5); found {
+		t.Error("Expected index 5 to be out of range")
+	}
+
+	if err := list.Set(1, "B"); err != nil {
+		t.Errorf("Expected set to succeed, got %v", err)
+	}
+	if err := list.Set(10, "x"); err != ErrIndexOutOfRange {
+		t.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if item, _ := list.Index(1); item != "B" {
+		t.Errorf("Expected 'B', got %v", item)
+	}
+}
+
+func TestListInsertRemTrim(t *testing.T) {
+	list := NewList()
+	list.RPush("a", "x", "b", "x", "c", "x")
+
+	if n := list.Insert(true, "b", "before-b"); n != 7 {
+		t.Errorf("Expected length 7, got %d", n)
+	}
+	if n := list.Insert(false, "c", "after-c"); n != 8 {
+		t.Errorf("Expected length 8, got %d", n)
+	}
+	if n := list.Insert(true, "missing", "v"); n != -1 {
+		t.Errorf("Expected -1 for missing pivot, got %d", n)
+	}
+
+	if removed := list.Rem(-1, "x"); removed != 1 {
+		t.Errorf("Expected 1 removal, got %d", removed)
+	}
+	if removed := list.Rem(0, "x"); removed != 2 {
+		t.Errorf("Expected 2 removals, got %d", removed)
+	}
+	expected := []interface{}{"a", "before-b", "b", "c", "after-c"}
+	if items := list.GetAll(); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %v, got %v", expected, items)
+	}
+
+	list.Trim(1, -2)
+	if items := list.GetAll(); !reflect.DeepEqual(items, []interface{}{"before-b", "b", "c"}) {
+		t.Errorf("Expected [before-b b c], got %v", items)
+	}
+	list.Trim(5, 10)
+	if list.Len() != 0 {
+		t.Errorf("Expected empty list after trim, got %v", list.GetAll())
+	}
+}
+
+func TestListGetAllReturnsCopy(t *testing.T) {
+	list := NewList()
+	list.Push("item1")
+
+	items := list.GetAll()
+	items[0] = "changed"
+
+	if item, _ := list.Index(0); item != "item1" {
+		t.Errorf("Expected list to be unaffected by changes to GetAll result, got %v", item)
+	}
+}
+
+func TestListConcurrentPush(t *testing.T) {
+	list := NewList()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			list.LPush(i)
+			list.RPush(i)
+		}(i)
+	}
+	wg.Wait()
+
+	if list.Len() != 100 {
+		t.Errorf("Expected 100 items, got %d", list.Len())
+	}
+}
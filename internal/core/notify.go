@@ -0,0 +1,206 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEventQueue is the number of keyspace events queued for listeners when no size is configured
+const DefaultEventQueue = 4096
+
+// EventType names the change a keyspace event reports. Command events are named after
+// the command that caused them.
+type EventType string
+
+const (
+	EventSet         EventType = "set"
+	EventDel         EventType = "del"
+	EventExpire      EventType = "expire"  // a TTL was set or changed
+	EventPersist     EventType = "persist" // a TTL was removed
+	EventExpired     EventType = "expired" // the key was removed because its TTL elapsed
+	EventEvicted     EventType = "evicted" // the key was removed to free memory
+	EventIncrBy      EventType = "incrby"
+	EventIncrByFloat EventType = "incrbyfloat"
+
+	EventLPush   EventType = "lpush"
+	EventRPush   EventType = "rpush"
+	EventLPop    EventType = "lpop"
+	EventRPop    EventType = "rpop"
+	EventLSet    EventType = "lset"
+	EventLInsert EventType = "linsert"
+	EventLRem    EventType = "lrem"
+	EventLTrim   EventType = "ltrim"
+
+	EventHSet    EventType = "hset"
+	EventHDel    EventType = "hdel"
+	EventHIncrBy EventType = "hincrby"
+
+	EventSAdd  EventType = "sadd"
+	EventSRem  EventType = "srem"
+	EventSPop  EventType = "spop"
+	EventStore EventType = "store" // the key was overwritten by a set operation's STORE variant
+
+	EventZAdd      EventType = "zadd"
+	EventZIncrBy   EventType = "zincrby"
+	EventZRem      EventType = "zrem"
+	EventZPopMin   EventType = "zpopmin"
+	EventZPopMax   EventType = "zpopmax"
+	EventZRemRange EventType = "zremrangebyscore"
+
+	EventXAdd         EventType = "xadd"
+	EventXTrim        EventType = "xtrim"
+	EventXGroupCreate EventType = "xgroup-create"
+)
+
+// KeyEvent reports a change to a key. A collection emptied by a command reports the
+// command event followed by EventDel. Dropped counts the events lost to a full queue
+// since the previous event that was delivered.
+type KeyEvent struct {
+	Key     string    `json:"key"`
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Dropped int64     `json:"dropped,omitempty"`
+}
+
+// EventFilter selects keyspace events. The zero value selects every event.
+type EventFilter struct {
+	Pattern string      // glob pattern keys must match; empty matches every key
+	Types   []EventType // event types to receive; empty receives all types
+}
+
+func (f EventFilter) matches(event KeyEvent) bool {
+	if f.Pattern != "" && !globMatch(f.Pattern, event.Key) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, typ := range f.Types {
+		if typ == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+type keyListener struct {
+	filter EventFilter
+	fn     func(KeyEvent)
+}
+
+// keyEvents queues events emitted under the shard locks and hands them to listeners on
+// a single dispatcher goroutine, in the order they were emitted. Writers never wait for
+// listeners: while a listener is slow, up to size events are queued and the following
+// ones are dropped and counted, like the messages of a full Pub/Sub subscriber.
+type keyEvents struct {
+	mutex     sync.Mutex
+	wake      *sync.Cond
+	queue     []KeyEvent
+	size      int
+	dropped   int64 // events dropped since the last queued one
+	listeners map[int]keyListener
+	nextID    int
+	active    atomic.Int32 // number of listeners, checked without the mutex by emit
+	muted     atomic.Bool  // set while the AOF is replayed, whose changes are not news
+	closed    bool
+	done      chan struct{}
+}
+
+func newKeyEvents(size int) *keyEvents {
+	if size <= 0 {
+		size = DefaultEventQueue
+	}
+	e := &keyEvents{size: size, listeners: make(map[int]keyListener), done: make(chan struct{})}
+	e.wake = sync.NewCond(&e.mutex)
+	go e.run()
+	return e
+}
+
+// emit queues an event, or drops it when the queue is full. It is a no-op while nobody
+// listens.
+func (e *keyEvents) emit(key string, typ EventType) {
+	if e.active.Load() == 0 || e.muted.Load() {
+		return
+	}
+	e.mutex.Lock()
+	if len(e.queue) >= e.size {
+		e.dropped++
+		e.mutex.Unlock()
+		return
+	}
+	e.queue = append(e.queue, KeyEvent{Key: key, Type: typ, Time: time.Now(), Dropped: e.dropped})
+	e.dropped = 0
+	e.mutex.Unlock()
+	e.wake.Signal()
+}
+
+func (e *keyEvents) run() {
+	defer close(e.done)
+	for {
+		e.mutex.Lock()
+		for len(e.queue) == 0 && !e.closed {
+			e.wake.Wait()
+		}
+		if len(e.queue) == 0 {
+			e.mutex.Unlock()
+			return
+		}
+		batch := e.queue
+		e.queue = nil
+		listeners := make([]keyListener, 0, len(e.listeners))
+		for _, l := range e.listeners {
+			listeners = append(listeners, l)
+		}
+		e.mutex.Unlock()
+
+		for _, event := range batch {
+			for _, l := range listeners {
+				if l.filter.matches(event) {
+					l.fn(event)
+				}
+			}
+		}
+	}
+}
+
+func (e *keyEvents) subscribe(filter EventFilter, fn func(KeyEvent)) func() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	id := e.nextID
+	e.nextID++
+	e.listeners[id] = keyListener{filter: filter, fn: fn}
+	e.active.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.mutex.Lock()
+			defer e.mutex.Unlock()
+			delete(e.listeners, id)
+			e.active.Add(-1)
+		})
+	}
+}
+
+// close delivers the queued events and stops the dispatcher.
+func (e *keyEvents) close() {
+	e.mutex.Lock()
+	e.closed = true
+	e.mutex.Unlock()
+	e.wake.Broadcast()
+	<-e.done
+}
+
+// OnKeyEvent calls fn for every keyspace event matching filter until the returned cancel
+// function is called; events already dispatched may still arrive after cancel returns.
+// Callbacks run one at a time on a dispatcher goroutine, in the order the changes were
+// applied, and must not block: events queue up behind a slow callback, and once
+// Config.EventQueue events are waiting new ones are dropped and counted in the Dropped
+// field of the next event. Expired events are emitted when the background cycle
+// reclaims the key, shortly after its TTL elapses. No events follow Close; see Done to
+// stop waiting for them.
+func (ss *ShardedStore) OnKeyEvent(filter EventFilter, fn func(KeyEvent)) (cancel func()) {
+	return ss.events.subscribe(filter, fn)
+}
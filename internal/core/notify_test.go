@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects keyspace events delivered to a callback
+type recorder struct {
+	mutex  sync.Mutex
+	events []KeyEvent
+}
+
+func (r *recorder) record(event KeyEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) types() []EventType {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	types := make([]EventType, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestKeyEventsInOrder(t *testing.T) {
+	store := NewShardedStore()
+	rec := &recorder{}
+	store.OnKeyEvent(EventFilter{}, rec.record)
+
+	store.Set("k", "v", 0)
+	store.Expire("k", time.Minute)
+	store.Delete("k")
+	store.Delete("k")
+	store.RPush("list", "a")
+	store.LPop("list")
+	store.Close()
+
+	want := []EventType{EventSet, EventExpire, EventDel, EventRPush, EventLPop, EventDel}
+	if got := rec.types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestKeyEventFilter(t *testing.T) {
+	store := NewShardedStore()
+	rec := &recorder{}
+	store.OnKeyEvent(EventFilter{Pattern: "user:*", Types: []EventType{EventDel}}, rec.record)
+
+	store.Set("user:1", "a", 0)
+	store.Set("order:1", "b", 0)
+	store.Delete("order:1")
+	store.Delete("user:1")
+	store.Close()
+
+	if len(rec.events) != 1 || rec.events[0].Key != "user:1" || rec.events[0].Type != EventDel {
+		t.Errorf("Expected only the deletion of user:1, got %v", rec.events)
+	}
+}
+
+func TestExpiredKeyEvent(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{ExpireInterval: 10 * time.Millisecond})
+	rec := &recorder{}
+	store.OnKeyEvent(EventFilter{Types: []EventType{EventExpired}}, rec.record)
+
+	store.Set("temp", "v", 0)
+	store.Expire("temp", 20*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	store.Close()
+
+	if len(rec.events) != 1 || rec.events[0].Key != "temp" {
+		t.Errorf("Expected an expired event for temp, got %v", rec.events)
+	}
+}
+
+func TestKeyEventCancel(t *testing.T) {
+	store := NewShardedStore()
+	rec := &recorder{}
+	cancel := store.OnKeyEvent(EventFilter{}, rec.record)
+	cancel()
+	cancel()
+
+	store.Set("k", "v", 0)
+	store.Close()
+	if len(rec.events) != 0 {
+		t.Errorf("Expected no events after cancel, got %v", rec.events)
+	}
+}
+
+func TestKeyEventQueueDropsBehindSlowListener(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{EventQueue: 4})
+	blocked, release := make(chan struct{}), make(chan struct{})
+	rec := &recorder{}
+	store.OnKeyEvent(EventFilter{}, func(event KeyEvent) {
+		if event.Key == "k0" {
+			close(blocked)
+			<-release
+		}
+		rec.record(event)
+	})
+
+	store.Set("k0", "v", 0)
+	<-blocked
+	for i := 1; i <= 10; i++ {
+		store.Set(fmt.Sprintf("k%d", i), "v", 0)
+	}
+	close(release)
+	for deadline := time.Now().Add(time.Second); len(rec.types()) < 5 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	store.Set("k11", "v", 0)
+	store.Close()
+
+	var keys []string
+	for _, event := range rec.events {
+		keys = append(keys, event.Key)
+	}
+	if want := []string{"k0", "k1", "k2", "k3", "k4", "k11"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Expected %v, got %v", want, keys)
+	}
+	if dropped := rec.events[5].Dropped; dropped != 6 {
+		t.Errorf("Expected the next event to count 6 dropped events, got %d", dropped)
+	}
+}
+
+func TestDoneClosesWithStore(t *testing.T) {
+	store := NewShardedStore()
+	select {
+	case <-store.Done():
+		t.Fatal("expected Done to stay open until Close")
+	default:
+	}
+	store.Close()
+	select {
+	case <-store.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected Done to be closed by Close")
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"sync"
+)
+
+// DefaultSubscriberBuffer is the number of messages queued per subscriber when no size is configured
+const DefaultSubscriberBuffer = 256
+
+// Message is a payload published on a channel. Pattern is set when the message was
+// received through a pattern subscription. Dropped counts the messages this subscriber
+// lost to a full buffer since the previous message it received.
+type Message struct {
+	Channel string      `json:"channel"`
+	Pattern string      `json:"pattern,omitempty"`
+	Payload interface{} `json:"payload"`
+	Dropped int64       `json:"dropped,omitempty"`
+}
+
+// PubSub delivers published messages to the subscribers of a channel or of a glob
+// pattern matching it. Publishing never blocks: every subscriber has a bounded buffer
+// and a message that does not fit is dropped for that subscriber only. The next message
+// it receives reports how many were lost, so it can resynchronize.
+type PubSub struct {
+	mutex    sync.RWMutex
+	channels map[string]map[*Subscription]struct{}
+	patterns map[string]map[*Subscription]struct{}
+	buffer   int
+	closed   bool
+}
+
+// Subscription receives the messages of the channels and patterns it was created with.
+type Subscription struct {
+	pubsub   *PubSub
+	channels []string
+	patterns []string
+	messages chan Message
+
+	mutex   sync.Mutex // serializes deliveries so drop counts are reported in order
+	dropped int64
+	once    sync.Once
+}
+
+// NewPubSub creates a broker whose subscribers buffer up to buffer messages.
+func NewPubSub(buffer int) *PubSub {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	return &PubSub{
+		channels: make(map[string]map[*Subscription]struct{}),
+		patterns: make(map[string]map[*Subscription]struct{}),
+		buffer:   buffer,
+	}
+}
+
+// Subscribe registers a subscriber for channels and glob patterns. Call Close on the
+// subscription when done.
+func (ps *PubSub) Subscribe(channels, patterns []string) *Subscription {
+	sub := &Subscription{
+		pubsub:   ps,
+		channels: channels,
+		patterns: patterns,
+		messages: make(chan Message, ps.buffer),
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if ps.closed {
+		close(sub.messages)
+		return sub
+	}
+	for _, channel := range channels {
+		addSubscriber(ps.channels, channel, sub)
+	}
+	for _, pattern := range patterns {
+		addSubscriber(ps.patterns, pattern, sub)
+	}
+	return sub
+}
+
+// Publish sends payload to the subscribers of channel and of every pattern matching it,
+// and returns the number of subscribers that received it.
+func (ps *PubSub) Publish(channel string, payload interface{}) int {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	received := 0
+	for sub := range ps.channels[channel] {
+		if sub.deliver(Message{Channel: channel, Payload: payload}) {
+			received++
+		}
+	}
+	for pattern, subs := range ps.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if sub.deliver(Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				received++
+			}
+		}
+	}
+	return received
+}
+
+// NumSubscribers returns the number of subscriptions to channel, not counting patterns.
+func (ps *PubSub) NumSubscribers(channel string) int {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+	return len(ps.channels[channel])
+}
+
+// Close ends every subscription; their message channels are closed.
+func (ps *PubSub) Close() {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ps.closed = true
+	for _, index := range []map[string]map[*Subscription]struct{}{ps.channels, ps.patterns} {
+		for name, subs := range index {
+			for sub := range subs {
+				sub.once.Do(func() { close(sub.messages) })
+			}
+			delete(index, name)
+		}
+	}
+}
+
+// Messages returns the channel messages are delivered on. It is closed when the
+// subscription or the broker is closed.
+func (sub *Subscription) Messages() <-chan Message {
+	return sub.messages
+}
+
+// Close unsubscribes and closes the message channel. It is safe to call more than once.
+func (sub *Subscription) Close() {
+	ps := sub.pubsub
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	for _, channel := range sub.channels {
+		removeSubscriber(ps.channels, channel, sub)
+	}
+	for _, pattern := range sub.patterns {
+		removeSubscriber(ps.patterns, pattern, sub)
+	}
+	sub.once.Do(func() { close(sub.messages) })
+}
+
+// deliver queues msg without blocking and reports whether it fit in the buffer.
+// Caller must hold the broker's read lock, which keeps the channel open.
+func (sub *Subscription) deliver(msg Message) bool {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	msg.Dropped = sub.dropped
+	select {
+	case sub.messages <- msg:
+		sub.dropped = 0
+		return true
+	default:
+		sub.dropped++
+		return false
+	}
+}
+
+func addSubscriber(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	if index[name] == nil {
+		index[name] = make(map[*Subscription]struct{})
+	}
+	index[name][sub] = struct{}{}
+}
+
+func removeSubscriber(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	delete(index[name], sub)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+// Publish sends payload to the subscribers of channel, see PubSub.Publish.
+func (ss *ShardedStore) Publish(channel string, payload interface{}) int {
+	return ss.pubsub.Publish(channel, payload)
+}
+
+// Subscribe registers a subscriber for channels and glob patterns, see PubSub.Subscribe.
+func (ss *ShardedStore) Subscribe(channels, patterns []string) *Subscription {
+	return ss.pubsub.Subscribe(channels, patterns)
+}
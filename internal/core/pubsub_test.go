@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestPublishToChannelAndPattern(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	direct := store.Subscribe([]string{"cache.users"}, nil)
+	defer direct.Close()
+	pattern := store.Subscribe(nil, []string{"cache.*"})
+	defer pattern.Close()
+
+	if n := store.Publish("cache.users", "user:1"); n != 2 {
+		t.Errorf("Expected 2 receivers, got %d", n)
+	}
+	if n := store.Publish("other", "x"); n != 0 {
+		t.Errorf("Expected no receivers, got %d", n)
+	}
+
+	msg := <-direct.Messages()
+	if msg.Channel != "cache.users" || msg.Payload != "user:1" || msg.Pattern != "" {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+	msg = <-pattern.Messages()
+	if msg.Pattern != "cache.*" || msg.Payload != "user:1" {
+		t.Errorf("Unexpected pattern message %+v", msg)
+	}
+}
+
+func TestSlowSubscriberDropsMessages(t *testing.T) {
+	ps := NewPubSub(2)
+	defer ps.Close()
+
+	sub := ps.Subscribe([]string{"c"}, nil)
+	for i := 0; i < 5; i++ {
+		ps.Publish("c", i)
+	}
+
+	first, second := <-sub.Messages(), <-sub.Messages()
+	if first.Payload != 0 || second.Payload != 1 || first.Dropped != 0 {
+		t.Errorf("Expected the oldest messages to be kept, got %+v %+v", first, second)
+	}
+	ps.Publish("c", 5)
+	if msg := <-sub.Messages(); msg.Payload != 5 || msg.Dropped != 3 {
+		t.Errorf("Expected the next message to report 3 drops, got %+v", msg)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	ps := NewPubSub(0)
+	sub := ps.Subscribe([]string{"c"}, []string{"c*"})
+	sub.Close()
+	sub.Close()
+
+	if _, open := <-sub.Messages(); open {
+		t.Error("Expected the message channel to be closed")
+	}
+	if n := ps.Publish("c", "x"); n != 0 || ps.NumSubscribers("c") != 0 {
+		t.Errorf("Expected no subscribers left, got %d", n)
+	}
+
+	other := ps.Subscribe([]string{"c"}, nil)
+	ps.Close()
+	if _, open := <-other.Messages(); open {
+		t.Error("Expected closing the broker to end subscriptions")
+	}
+	other.Close()
+}
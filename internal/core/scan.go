@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultScanCount is the number of keys examined per Scan call when no count is given
+	DefaultScanCount = 10
+
+	// ScanStart is the cursor that starts a new iteration and that Scan returns once it is complete
+	ScanStart = "0"
+)
+
+// ErrInvalidCursor is returned when a Scan cursor was not produced by Scan
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ScanOptions filters and sizes a Scan call
+type ScanOptions struct {
+	Match string    // glob pattern keys must match; empty matches everything
+	Type  ValueType // only return keys holding this type; empty returns all types
+	Count int       // hint for the number of keys examined per call
+}
+
+// Scan iterates over the keyspace without keeping server-side state. Start with
+// ScanStart and pass the returned cursor to the next call until ScanStart comes
+// back. Every key that exists during the whole iteration is returned exactly once;
+// keys written or deleted meanwhile may or may not be returned. A call can return
+// fewer keys than Count, even none, while the iteration is not complete.
+func (ss *ShardedStore) Scan(cursor string, opts ScanOptions) (string, []string, error) {
+	index, after, hasAfter, err := decodeCursor(cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	now := time.Now().UnixMilli()
+	keys := make([]string, 0)
+	for examined := 0; index < ShardCount && examined < count; {
+		batch, last, n, more := ss.shards[index].scan(after, hasAfter, count-examined, now, opts)
+		keys = append(keys, batch...)
+		examined += n
+		if more {
+			after, hasAfter = last, true
+			continue
+		}
+		index++
+		after, hasAfter = "", false
+	}
+
+	if index == ShardCount {
+		return ScanStart, keys, nil
+	}
+	return encodeCursor(index, after, hasAfter), keys, nil
+}
+
+// Keys returns all keys matching a glob pattern, sorted. It examines the whole
+// keyspace; prefer Scan on large stores.
+func (ss *ShardedStore) Keys(pattern string) []string {
+	now := time.Now().UnixMilli()
+	keys := make([]string, 0)
+	for i := 0; i < ShardCount; i++ {
+		shard := &ss.shards[i]
+		shard.mutex.RLock()
+		for key, entry := range shard.data {
+			if !entry.isExpired(now) && (pattern == "" || globMatch(pattern, key)) {
+				keys = append(keys, key)
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scan examines up to limit keys of the shard in lexical order, starting after the
+// given key, and returns those passing the filters, the last examined key, the
+// number of examined keys and whether keys remain in the shard. The shard keeps its
+// keys ordered, so a call costs O(log N + limit).
+func (s *Store) scan(after string, hasAfter bool, limit int, now int64, opts ScanOptions) ([]string, string, int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	node := s.order.firstInLexRange(LexRange{Min: after, MinExclusive: hasAfter, MinUnbounded: !hasAfter, MaxUnbounded: true})
+	candidates := make([]string, 0, min(limit, s.order.length))
+	for ; node != nil && len(candidates) < limit; node = node.level[0].forward {
+		candidates = append(candidates, node.member)
+	}
+	more := node != nil
+
+	keys := make([]string, 0, len(candidates))
+	for _, key := range candidates {
+		entry := s.data[key]
+		if entry.isExpired(now) {
+			continue
+		}
+		if opts.Type != "" && entry.Type != opts.Type {
+			continue
+		}
+		if opts.Match != "" && !globMatch(opts.Match, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	last := after
+	if len(candidates) > 0 {
+		last = candidates[len(candidates)-1]
+	}
+	return keys, last, len(candidates), more
+}
+
+// encodeCursor packs the shard index and the last returned key into an opaque string.
+func encodeCursor(index int, after string, hasAfter bool) string {
+	raw := strconv.Itoa(index)
+	if hasAfter {
+		raw += ":" + after
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (int, string, bool, error) {
+	if cursor == "" || cursor == ScanStart {
+		return 0, "", false, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", false, ErrInvalidCursor
+	}
+	indexPart, after, hasAfter := strings.Cut(string(raw), ":")
+	index, err := strconv.Atoi(indexPart)
+	if err != nil || index < 0 || index >= ShardCount {
+		return 0, "", false, ErrInvalidCursor
+	}
+	return index, after, hasAfter, nil
+}
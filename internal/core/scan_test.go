@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// scanAll runs a complete iteration and returns the keys in the order they were returned
+func scanAll(t *testing.T, store *ShardedStore, opts ScanOptions) []string {
+	t.Helper()
+	var keys []string
+	cursor := ScanStart
+	for {
+		next, batch, err := store.Scan(cursor, opts)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		keys = append(keys, batch...)
+		if next == ScanStart {
+			return keys
+		}
+		cursor = next
+	}
+}
+
+func TestScanReturnsEveryKeyOnce(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	expected := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key:%03d", i)
+		store.Set(key, i, 0)
+		expected = append(expected, key)
+	}
+
+	keys := scanAll(t, store, ScanOptions{Count: 7})
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected every key exactly once, got %d keys", len(keys))
+	}
+
+	// Deleted keys leave the shard index and are not examined again
+	for _, key := range expected[:50] {
+		store.Delete(key)
+	}
+	keys = scanAll(t, store, ScanOptions{Count: 7})
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, expected[50:]) {
+		t.Errorf("Expected the remaining keys, got %d keys", len(keys))
+	}
+	for i := range store.shards {
+		if shard := &store.shards[i]; shard.order.length != len(shard.data) {
+			t.Errorf("Shard %d indexes %d keys, holds %d", i, shard.order.length, len(shard.data))
+		}
+	}
+}
+
+func TestScanMatchAndType(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("user:1", "alice", 0)
+	store.Set("user:2", "bob", 0)
+	store.RPush("user:queue", "job")
+	store.Set("session:1", "x", 0)
+
+	keys := scanAll(t, store, ScanOptions{Match: "user:*", Type: TypeString})
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"user:1", "user:2"}) {
+		t.Errorf("Expected [user:1 user:2], got %v", keys)
+	}
+
+	if keys := store.Keys("user:*"); !reflect.DeepEqual(keys, []string{"user:1", "user:2", "user:queue"}) {
+		t.Errorf("Expected all user keys, got %v", keys)
+	}
+}
+
+func TestScanDuringConcurrentWrites(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	for i := 0; i < 50; i++ {
+		store.Set(fmt.Sprintf("stable:%d", i), i, 0)
+	}
+
+	seen := map[string]int{}
+	cursor := ScanStart
+	for i := 0; ; i++ {
+		store.Set(fmt.Sprintf("churn:%d", i), i, 0)
+		store.Delete(fmt.Sprintf("churn:%d", i-1))
+
+		next, batch, err := store.Scan(cursor, ScanOptions{Count: 5})
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		for _, key := range batch {
+			seen[key]++
+		}
+		if next == ScanStart {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 50; i++ {
+		if n := seen[fmt.Sprintf("stable:%d", i)]; n != 1 {
+			t.Errorf("Expected stable:%d to be returned once, got %d", i, n)
+		}
+	}
+}
+
+func TestScanInvalidCursor(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if _, _, err := store.Scan("not a cursor!", ScanOptions{}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Set is a concurrency-safe collection of unique string members stored under a single key.
+// The members are kept in a slice, indexed by a map, so that random members are picked
+// in constant time.
+type Set struct {
+	mutex   sync.RWMutex
+	members map[string]int // position of each member in items
+	items   []string
+}
+
+func NewSet(members ...string) *Set {
+	s := &Set{members: make(map[string]int, len(members))}
+	for _, member := range members {
+		s.insert(member)
+	}
+	return s
+}
+
+// Add inserts members and returns the ones that were not present yet
+func (s *Set) Add(members ...string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	added := make([]string, 0, len(members))
+	for _, member := range members {
+		if s.insert(member) {
+			added = append(added, member)
+		}
+	}
+	return added
+}
+
+// Rem removes members and returns the ones that were present
+func (s *Set) Rem(members ...string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := make([]string, 0, len(members))
+	for _, member := range members {
+		if s.delete(member) {
+			removed = append(removed, member)
+		}
+	}
+	return removed
+}
+
+// Contains reports whether member is in the set
+func (s *Set) Contains(member string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, found := s.members[member]
+	return found
+}
+
+// Members returns all members in lexical order
+func (s *Set) Members() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sorted()
+}
+
+// Len returns the number of members
+func (s *Set) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.items)
+}
+
+// Pop removes and returns up to count random members in O(count)
+func (s *Set) Pop(count int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	popped := make([]string, 0, min(max(count, 0), len(s.items)))
+	for len(popped) < cap(popped) {
+		member := s.items[rand.Intn(len(s.items))]
+		s.delete(member)
+		popped = append(popped, member)
+	}
+	return popped
+}
+
+// Random returns random members without removing them. A positive count returns up to
+// count distinct members; a negative count returns exactly -count members, possibly
+// repeated. Either way it costs O(count), not O(size of the set).
+func (s *Set) Random(count int) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := len(s.items)
+	if count < 0 {
+		picked := make([]string, 0, -count)
+		for n > 0 && len(picked) < -count {
+			picked = append(picked, s.items[rand.Intn(n)])
+		}
+		return picked
+	}
+	if count >= n {
+		picked := append([]string{}, s.items...)
+		rand.Shuffle(n, func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+		return picked
+	}
+
+	// Floyd's algorithm samples count distinct positions without touching the others
+	chosen := make(map[int]struct{}, count)
+	picked := make([]string, 0, count)
+	for j := n - count; j < n; j++ {
+		i := rand.Intn(j + 1)
+		if _, dup := chosen[i]; dup {
+			i = j
+		}
+		chosen[i] = struct{}{}
+		picked = append(picked, s.items[i])
+	}
+	rand.Shuffle(count, func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+	return picked
+}
+
+// MarshalJSON encodes the set as a sorted JSON array
+func (s *Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Members())
+}
+
+// insert adds member and reports whether it was new. Caller must hold the write lock.
+func (s *Set) insert(member string) bool {
+	if _, found := s.members[member]; found {
+		return false
+	}
+	s.members[member] = len(s.items)
+	s.items = append(s.items, member)
+	return true
+}
+
+// delete removes member by moving the last item into its place and reports whether it
+// was present. Caller must hold the write lock.
+func (s *Set) delete(member string) bool {
+	i, found := s.members[member]
+	if !found {
+		return false
+	}
+	last := len(s.items) - 1
+	s.items[i] = s.items[last]
+	s.members[s.items[i]] = i
+	s.items[last] = ""
+	s.items = s.items[:last]
+	delete(s.members, member)
+	return true
+}
+
+// sorted copies the members in lexical order. Caller must hold the lock.
+func (s *Set) sorted() []string {
+	members := append([]string{}, s.items...)
+	sort.Strings(members)
+	return members
+}
+
+// unionSets returns a new set holding the members of any of sets; nil sets count as empty.
+func unionSets(sets []*Set) *Set {
+	result := NewSet()
+	for _, set := range sets {
+		if set != nil {
+			result.Add(set.Members()...)
+		}
+	}
+	return result
+}
+
+// interSets returns a new set holding the members present in all sets.
+func interSets(sets []*Set) *Set {
+	result := NewSet()
+	if len(sets) == 0 || sets[0] == nil {
+		return result
+	}
+	for _, member := range sets[0].Members() {
+		inAll := true
+		for _, set := range sets[1:] {
+			if set == nil || !set.Contains(member) {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			result.Add(member)
+		}
+	}
+	return result
+}
+
+// diffSets returns a new set holding the members of the first set missing from all others.
+func diffSets(sets []*Set) *Set {
+	result := NewSet()
+	if len(sets) == 0 || sets[0] == nil {
+		return result
+	}
+	for _, member := range sets[0].Members() {
+		found := false
+		for _, set := range sets[1:] {
+			if set != nil && set.Contains(member) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Add(member)
+		}
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSetAddRem(t *testing.T) {
+	set := NewSet("a")
+	if added := set.Add("a", "b", "c"); !reflect.DeepEqual(added, []string{"b", "c"}) {
+		t.Errorf("Expected [b c] to be added, got %v", added)
+	}
+	if removed := set.Rem("c", "x"); !reflect.DeepEqual(removed, []string{"c"}) {
+		t.Errorf("Expected [c] to be removed, got %v", removed)
+	}
+	if !set.Contains("a") || set.Contains("c") {
+		t.Error("Unexpected membership after Add and Rem")
+	}
+	if !reflect.DeepEqual(set.Members(), []string{"a", "b"}) {
+		t.Errorf("Expected sorted members [a b], got %v", set.Members())
+	}
+}
+
+func TestSetPopAndRandom(t *testing.T) {
+	set := NewSet("a", "b", "c")
+	if members := set.Random(10); len(members) != 3 {
+		t.Errorf("Expected 3 distinct members, got %v", members)
+	}
+	if members := set.Random(-5); len(members) != 5 {
+		t.Errorf("Expected 5 members with repetition, got %v", members)
+	}
+	popped := set.Pop(2)
+	if len(popped) != 2 || set.Len() != 1 {
+		t.Errorf("Expected 2 popped and 1 left, got %v and %d", popped, set.Len())
+	}
+	for _, member := range popped {
+		if set.Contains(member) {
+			t.Errorf("Popped member %s is still in the set", member)
+		}
+	}
+}
+
+func TestSetSamplesDistinctMembers(t *testing.T) {
+	set := NewSet()
+	for i := 0; i < 1000; i++ {
+		set.Add(fmt.Sprint(i))
+	}
+	seen := map[string]bool{}
+	for _, member := range set.Random(100) {
+		if seen[member] || !set.Contains(member) {
+			t.Fatalf("Expected distinct members of the set, got %s twice or unknown", member)
+		}
+		seen[member] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 members, got %d", len(seen))
+	}
+
+	popped := set.Pop(600)
+	if len(popped) != 600 || set.Len() != 400 || len(set.Members()) != 400 {
+		t.Errorf("Expected 600 popped and 400 left, got %d and %d", len(popped), set.Len())
+	}
+	for _, member := range popped {
+		if set.Contains(member) {
+			t.Fatalf("Popped member %s is still in the set", member)
+		}
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := NewSet("1", "2", "3")
+	b := NewSet("2", "3", "4")
+	tests := []struct {
+		name    string
+		combine func([]*Set) *Set
+		want    []string
+	}{
+		{"union", unionSets, []string{"1", "2", "3", "4"}},
+		{"inter", interSets, []string{"2", "3"}},
+		{"diff", diffSets, []string{"1"}},
+	}
+	for _, tt := range tests {
+		if got := tt.combine([]*Set{a, b}).Members(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	if interSets([]*Set{a, nil}).Len() != 0 {
+		t.Error("Expected a missing set to empty the intersection")
+	}
+}
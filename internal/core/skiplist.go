@@ -0,0 +1,211 @@
+package core
+
+import (
+	"math/rand"
+)
+
+const (
+	// skipListMaxLevel bounds the height of a node; enough for 2^64 elements with p = 1/4
+	skipListMaxLevel = 32
+
+	// skipListP is the probability that a node is promoted to the next level
+	skipListP = 0.25
+)
+
+// skipNode is an element of a skipList. Every level records the number of elements it
+// skips over (its span), which makes rank lookups logarithmic.
+type skipNode struct {
+	member   string
+	score    float64
+	backward *skipNode
+	level    []skipLevel
+}
+
+type skipLevel struct {
+	forward *skipNode
+	span    int
+}
+
+// skipList orders members by score, then lexically by member. It is not safe for
+// concurrent use; ZSet guards it.
+type skipList struct {
+	header *skipNode
+	tail   *skipNode
+	length int
+	level  int
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		header: &skipNode{level: make([]skipLevel, skipListMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// before reports whether node sorts before (score, member)
+func (n *skipNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds a member that is not in the list yet
+func (sl *skipList) insert(score float64, member string) *skipNode {
+	var update [skipListMaxLevel]*skipNode
+	var rank [skipListMaxLevel]int
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = &skipNode{member: member, score: score, level: make([]skipLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+	return x
+}
+
+// delete removes the element with the given score and member and reports whether it existed
+func (sl *skipList) delete(score float64, member string) bool {
+	var update [skipListMaxLevel]*skipNode
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	sl.deleteNode(x, update[:])
+	return true
+}
+
+// deleteNode unlinks x given the rightmost node before it on every level
+func (sl *skipList) deleteNode(x *skipNode, update []*skipNode) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// rank returns the 1-based position of the element, or 0 when it is not in the list
+func (sl *skipList) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !(score < x.level[i].forward.score ||
+			(score == x.level[i].forward.score && member < x.level[i].forward.member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != sl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the element at a 1-based position, or nil when it is out of range
+func (sl *skipList) byRank(rank int) *skipNode {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != sl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstWhere returns the first element for which past is false, assuming past holds
+// for a prefix of the list.
+func (sl *skipList) firstWhere(past func(*skipNode) bool) *skipNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && past(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	return x.level[0].forward
+}
+
+// firstInScoreRange returns the first element within r, or nil
+func (sl *skipList) firstInScoreRange(r ScoreRange) *skipNode {
+	x := sl.firstWhere(func(n *skipNode) bool { return !r.aboveMin(n.score) })
+	if x == nil || !r.belowMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+// firstInLexRange returns the first element within r, or nil. Lexical ranges are only
+// meaningful when all members share the same score.
+func (sl *skipList) firstInLexRange(r LexRange) *skipNode {
+	x := sl.firstWhere(func(n *skipNode) bool { return !r.aboveMin(n.member) })
+	if x == nil || !r.belowMax(x.member) {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,348 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+const (
+	// DefaultSavePoints saves after an hour if anything changed, after 5 minutes if 100
+	// keys changed and after a minute if 10000 keys changed. See ParseSavePoints.
+	DefaultSavePoints = "3600 1 300 100 60 10000"
+
+	// saveRetryDelay is how long the schedule waits after a failed save before trying again
+	saveRetryDelay = 5 * time.Second
+)
+
+// SavePoint saves the store once Interval has passed since the last save, provided at
+// least Changes writes happened in the meantime.
+type SavePoint struct {
+	Interval time.Duration
+	Changes  int64
+}
+
+// ParseSavePoints reads save points written as pairs of seconds and changes, e.g.
+// "900 1 300 10" saves after 15 minutes if a key changed and after 5 minutes if 10 did.
+// An empty spec disables periodic saves.
+func ParseSavePoints(spec string) ([]SavePoint, error) {
+	fields := strings.Fields(spec)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("save points must be pairs of seconds and changes, got %q", spec)
+	}
+	var points []SavePoint
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.Atoi(fields[i])
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid save point interval %q", fields[i])
+		}
+		changes, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil || changes <= 0 {
+			return nil, fmt.Errorf("invalid save point change count %q", fields[i+1])
+		}
+		points = append(points, SavePoint{Interval: time.Duration(seconds) * time.Second, Changes: changes})
+	}
+	return points, nil
+}
+
+// SaveOptions selects the format of a snapshot
+type SaveOptions struct {
+	Format      persistence.SnapshotFormat // defaults to persistence.FormatJSON
+	Compression persistence.Compression    // binary snapshots only, defaults to persistence.CompressionNone
+}
+
+func (o *SaveOptions) validate() error {
+	if o.Format == "" {
+		o.Format = persistence.FormatJSON
+	}
+	if o.Compression == "" {
+		o.Compression = persistence.CompressionNone
+	}
+	if _, err := persistence.ParseSnapshotFormat(string(o.Format)); err != nil {
+		return err
+	}
+	if _, err := persistence.ParseCompression(string(o.Compression)); err != nil {
+		return err
+	}
+	if o.Format == persistence.FormatJSON && o.Compression != persistence.CompressionNone {
+		return fmt.Errorf("%s compression is only supported for binary snapshots", o.Compression)
+	}
+	return nil
+}
+
+// snapshotBatchSize is how many bytes of encoded entries a binary snapshot is written in
+const snapshotBatchSize = 1 << 20
+
+// writeBinarySnapshot streams the live keys into a binary snapshot, one shard at a time
+// and in key order. A batch of keys is encoded under the shard's read lock and written
+// once the lock is released, so compression, encryption and disk writes never hold up
+// writers; each key is saved as of the moment its batch was encoded.
+func (ss *ShardedStore) writeBinarySnapshot(w *persistence.SnapshotWriter) error {
+	var batch persistence.SnapshotBatch
+	for i := 0; i < ShardCount; i++ {
+		after, hasAfter := "", false
+		for {
+			last, more, err := ss.shards[i].encodeBatch(&batch, after, hasAfter, snapshotBatchSize)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteBatch(&batch); err != nil {
+				return err
+			}
+			if !more {
+				break
+			}
+			after, hasAfter = last, true
+		}
+	}
+	return nil
+}
+
+// encodeBatch adds the live entries of the shard after the given key to batch, in key
+// order, until the batch holds size bytes. It returns the last key it examined and
+// whether keys remain after it.
+func (s *Store) encodeBatch(batch *persistence.SnapshotBatch, after string, hasAfter bool, size int) (string, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now().UnixMilli()
+	node := s.order.firstInLexRange(LexRange{Min: after, MinExclusive: hasAfter, MinUnbounded: !hasAfter, MaxUnbounded: true})
+	for ; node != nil && batch.Size() < size; node = node.level[0].forward {
+		key := node.member
+		after = key
+		entry := s.data[key]
+		if entry.isExpired(now) {
+			continue
+		}
+		if err := batch.Add(key, entry.Value, entry.Expiration); err != nil {
+			return "", false, err
+		}
+	}
+	return after, node != nil, nil
+}
+
+// loadBinarySnapshot streams the entries of a binary snapshot straight into the shards,
+// dropping those that expired while on disk. Damage is only found when the reader gets
+// to it, so the keys loaded before a failure are removed again.
+func (ss *ShardedStore) loadBinarySnapshot(filename string) error {
+	var loaded []string
+	now := time.Now().UnixMilli()
+	err := persistence.LoadBinarySnapshot(filename, ss.config.Encryption, func(key string, value interface{}, expiration int64) error {
+		entry := Entry{Value: value, Expiration: expiration}
+		if entry.isExpired(now) {
+			return nil
+		}
+		shard := ss.getShard(key)
+		shard.mutex.Lock()
+		shard.put(key, entry)
+		shard.mutex.Unlock()
+		loaded = append(loaded, key)
+		return nil
+	})
+	if err != nil {
+		for _, key := range loaded {
+			shard := ss.getShard(key)
+			shard.mutex.Lock()
+			shard.remove(key)
+			shard.mutex.Unlock()
+		}
+	}
+	return err
+}
+
+// snapshotEntries encodes the live keys of every shard for a snapshot. Each shard is
+// encoded under its lock, so its keys are saved as of the same moment.
+func (ss *ShardedStore) snapshotEntries() (map[string]persistence.SnapshotEntry, error) {
+	entries := make(map[string]persistence.SnapshotEntry)
+	for i := 0; i < ShardCount; i++ {
+		shard := &ss.shards[i]
+		shard.mutex.RLock()
+		now := time.Now().UnixMilli()
+		for key, entry := range shard.data {
+			if entry.isExpired(now) {
+				continue
+			}
+			value, err := persistence.EncodeValue(entry.Value)
+			if err != nil {
+				shard.mutex.RUnlock()
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			entries[key] = persistence.SnapshotEntry{Value: value, Expiration: entry.Expiration}
+		}
+		shard.mutex.RUnlock()
+	}
+	return entries, nil
+}
+
+// decodeSnapshot decodes the keys of a snapshot saved in the given format version.
+// Before version 2 values were saved as plain JSON and collections are rebuilt from
+// their type tag by restoreLegacyValue; numbers come back as float64 in that case.
+func decodeSnapshot(version int, raw map[string]json.RawMessage) (map[string]Entry, error) {
+	entries := make(map[string]Entry, len(raw))
+	for key, data := range raw {
+		if version < 2 {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			restored, err := restoreLegacyValue(entry)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			entries[key] = restored
+			continue
+		}
+
+		var saved persistence.SnapshotEntry
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		value, err := persistence.DecodeValue(saved.Value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		entries[key] = Entry{Value: value, Expiration: saved.Expiration}
+	}
+	return entries, nil
+}
+
+// SnapshotStats describes the last snapshot saved to a file
+type SnapshotStats struct {
+	ChangesSinceSave int64     `json:"changes_since_save"`
+	SaveInProgress   bool      `json:"save_in_progress"`
+	Saves            int64     `json:"saves"`
+	LastSave         time.Time `json:"last_save"` // last successful save
+	LastSaveDuration int64     `json:"last_save_ms"`
+	LastSaveStatus   string    `json:"last_save_status,omitempty"` // "ok" or "err", empty before the first save
+	LastSaveError    string    `json:"last_save_error,omitempty"`
+}
+
+// snapshots serializes the saves of a store and keeps their outcome.
+type snapshots struct {
+	mutex  sync.Mutex // held for the duration of a save
+	saving atomic.Bool
+
+	status       sync.Mutex
+	saves        int64
+	lastSave     time.Time
+	lastAttempt  time.Time
+	lastDuration time.Duration
+	lastErr      error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// record stores the outcome of a save that started at started.
+func (s *snapshots) record(started time.Time, err error) {
+	s.status.Lock()
+	defer s.status.Unlock()
+	s.lastAttempt = started
+	s.lastDuration = time.Since(started)
+	s.lastErr = err
+	if err == nil {
+		s.saves++
+		s.lastSave = started
+	}
+}
+
+// ScheduleSnapshots saves the store to filename in the format of options in the
+// background whenever one of points is reached. A save point that comes due while another save is running is skipped; the
+// running save already covers it. After a failed save the schedule waits saveRetryDelay
+// before trying again. Close stops the schedule; call it at most once.
+func (ss *ShardedStore) ScheduleSnapshots(filename string, points []SavePoint, options SaveOptions) error {
+	if len(points) == 0 {
+		return nil
+	}
+	if err := options.validate(); err != nil {
+		return err
+	}
+	check := time.Second
+	for _, point := range points {
+		check = min(check, point.Interval)
+	}
+
+	s := &ss.snapshots
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	since := time.Now()
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				if !ss.saveDue(points, since, now) || !s.mutex.TryLock() {
+					continue
+				}
+				ss.saveStoreToFile(filename, options)
+				s.mutex.Unlock()
+			}
+		}
+	}()
+	return nil
+}
+
+// saveDue reports whether a save point is reached at now. Time is counted from the last
+// successful save, or from since when the store has not been saved yet.
+func (ss *ShardedStore) saveDue(points []SavePoint, since, now time.Time) bool {
+	s := &ss.snapshots
+	s.status.Lock()
+	if s.lastSave.After(since) {
+		since = s.lastSave
+	}
+	retrying := s.lastErr != nil && now.Sub(s.lastAttempt) < saveRetryDelay
+	s.status.Unlock()
+	if retrying {
+		return false
+	}
+
+	changes := ss.changes.Load()
+	for _, point := range points {
+		if changes >= point.Changes && now.Sub(since) >= point.Interval {
+			return true
+		}
+	}
+	return false
+}
+
+// stopSchedule stops the background saves and waits for a running one.
+func (s *snapshots) stopSchedule() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
+
+// SnapshotStats returns the number of changes since the last save and the outcome of the
+// last save.
+func (ss *ShardedStore) SnapshotStats() SnapshotStats {
+	s := &ss.snapshots
+	s.status.Lock()
+	defer s.status.Unlock()
+	stats := SnapshotStats{
+		ChangesSinceSave: ss.changes.Load(),
+		SaveInProgress:   s.saving.Load(),
+		Saves:            s.saves,
+		LastSave:         s.lastSave,
+		LastSaveDuration: s.lastDuration.Milliseconds(),
+	}
+	if !s.lastAttempt.IsZero() {
+		stats.LastSaveStatus = "ok"
+	}
+	if s.lastErr != nil {
+		stats.LastSaveStatus = "err"
+		stats.LastSaveError = s.lastErr.Error()
+	}
+	return stats
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+func TestParseSavePoints(t *testing.T) {
+	points, err := ParseSavePoints("900 1 60 10000")
+	want := []SavePoint{{15 * time.Minute, 1}, {time.Minute, 10000}}
+	if err != nil || !reflect.DeepEqual(points, want) {
+		t.Errorf("expected %v, got %v (%v)", want, points, err)
+	}
+	for _, spec := range []string{"60", "0 1", "60 0", "sixty 1"} {
+		if _, err := ParseSavePoints(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+	if points, err := ParseSavePoints(""); err != nil || len(points) != 0 {
+		t.Errorf("expected no save points, got %v (%v)", points, err)
+	}
+}
+
+func TestTTLAndGroupChangesCountAsWrites(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	store.Set("key", "v", 0)
+	id, _ := store.XAdd("events", map[string]interface{}{"n": 1}, XAddOptions{})
+
+	for _, step := range []struct {
+		name, key string
+		write     func()
+	}{
+		{"expire", "key", func() { store.Expire("key", time.Hour) }},
+		{"persist", "key", func() { store.Persist("key") }},
+		{"xgroupcreate", "events", func() { store.XGroupCreate("events", "g", "0", false) }},
+		{"xreadgroup", "events", func() {
+			store.XReadGroup(context.Background(), "g", "c", []string{"events"}, []string{">"}, XReadOptions{})
+		}},
+		{"xclaim", "events", func() { store.XClaim("events", "g", "d", 0, id.String()) }},
+		{"xack", "events", func() { store.XAck("events", "g", id.String()) }},
+	} {
+		name, key, write := step.name, step.key, step.write
+		version, changes := store.Version(key), store.SnapshotStats().ChangesSinceSave
+		write()
+		if store.Version(key) == version {
+			t.Errorf("%s: expected the version of %s to change", name, key)
+		}
+		if store.SnapshotStats().ChangesSinceSave != changes+1 {
+			t.Errorf("%s: expected the change to count towards the save points", name)
+		}
+	}
+}
+
+func TestScheduledSnapshotSavesAfterEnoughChanges(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.json")
+	store.ScheduleSnapshots(filename, []SavePoint{{Interval: 20 * time.Millisecond, Changes: 3}}, SaveOptions{})
+
+	store.Set("a", "1", 0)
+	store.Set("b", "2", 0)
+	time.Sleep(100 * time.Millisecond)
+	if stats := store.SnapshotStats(); stats.Saves != 0 || stats.ChangesSinceSave != 2 {
+		t.Fatalf("expected no save below the change threshold, got %+v", stats)
+	}
+
+	store.Delete("a")
+	deadline := time.Now().Add(time.Second)
+	for store.SnapshotStats().Saves == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a save once the save point was reached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := store.SnapshotStats()
+	if stats.LastSaveStatus != "ok" || stats.ChangesSinceSave != 0 || stats.LastSave.IsZero() {
+		t.Errorf("unexpected stats after the save: %+v", stats)
+	}
+	restored := NewShardedStore()
+	defer restored.Close()
+	if err := restored.LoadStoreFromFile(filename); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, found := restored.Get("b"); !found {
+		t.Error("expected b in the scheduled snapshot")
+	}
+}
+
+func TestSnapshotReportsFailedSave(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	store.Set("a", "1", 0)
+
+	filename := filepath.Join(t.TempDir(), "missing", "data.json")
+	if err := store.SaveStoreToFile(filename); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	if stats := store.SnapshotStats(); stats.LastSaveStatus != "err" || stats.LastSaveError == "" || stats.ChangesSinceSave != 1 {
+		t.Errorf("unexpected stats after a failed save: %+v", stats)
+	}
+}
+
+func TestSnapshotPreservesValueTypes(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.IncrBy("counter", 1<<60)
+	store.Set("blob", []byte{0, 1, 0xff}, 0)
+	store.Set("nested", map[string]interface{}{"n": 7, "items": []interface{}{int64(1), "two", nil}}, 0)
+	store.RPush("list", 1, "a", 2.5)
+	store.HSet("hash", map[string]interface{}{"visits": int64(3)})
+	store.SAdd("set", "x", "y")
+	store.ZAdd("board", []ZMember{{"alice", 1.1}}, ZAddOptions{})
+	store.XAdd("events", map[string]interface{}{"n": 1}, XAddOptions{})
+	store.XGroupCreate("events", "g", "0", false)
+	store.XReadGroup(context.Background(), "g", "c", []string{"events"}, []string{">"}, XReadOptions{})
+
+	for name, options := range map[string]SaveOptions{
+		"json":        {},
+		"binary":      {Format: persistence.FormatBinary},
+		"binary-gzip": {Format: persistence.FormatBinary, Compression: persistence.CompressionGzip},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "snapshot")
+			if err := store.SaveStoreToFileWithOptions(filename, options); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			restored := NewShardedStore()
+			defer restored.Close()
+			if err := restored.LoadStoreFromFile(filename); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			for _, key := range []string{"counter", "blob", "nested"} {
+				want, _ := store.Get(key)
+				if got, _ := restored.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: expected %#v, got %#v", key, want, got)
+				}
+			}
+			if list, _ := restored.LRange("list", 0, -1); !reflect.DeepEqual(list, []interface{}{1, "a", 2.5}) {
+				t.Errorf("expected [1 a 2.5] with their types, got %#v", list)
+			}
+			if visits, _, _ := restored.HGet("hash", "visits"); visits != int64(3) {
+				t.Errorf("expected int64 3, got %#v", visits)
+			}
+			if members, _ := restored.SMembers("set"); len(members) != 2 {
+				t.Errorf("expected 2 members, got %v", members)
+			}
+			if score, _, _ := restored.ZScore("board", "alice"); score != 1.1 {
+				t.Errorf("expected score 1.1, got %v", score)
+			}
+			if entries, _ := restored.XRange("events", "-", "+", 0); len(entries) != 1 || entries[0].Fields["n"] != 1 {
+				t.Errorf("expected the stream entry with an int field, got %#v", entries)
+			}
+			if pending, _ := restored.XPending("events", "g", "-", "+", 0, ""); len(pending) != 1 {
+				t.Errorf("expected the pending entry of the group, got %v", pending)
+			}
+		})
+	}
+}
+
+func TestBinarySnapshotWritesShardsInBatches(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	// About 1.2 MiB per shard, so every shard takes more than one batch.
+	value := strings.Repeat("v", 64<<10)
+	for i := 0; i < 300; i++ {
+		store.Set(fmt.Sprintf("key:%d", i), value, 0)
+	}
+	filename := filepath.Join(t.TempDir(), "dump.bin")
+	if err := store.SaveStoreToFileWithOptions(filename, SaveOptions{Format: persistence.FormatBinary}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewShardedStore()
+	defer restored.Close()
+	if err := restored.LoadStoreFromFile(filename); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if keys := restored.Stats().Keys; keys != 300 {
+		t.Errorf("expected 300 keys, got %d", keys)
+	}
+	if got, _ := restored.Get("key:299"); got != value {
+		t.Error("expected the last key to be restored")
+	}
+}
+
+func TestSaveOptionsRejectCompressedJSON(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	filename := filepath.Join(t.TempDir(), "data.json")
+	if err := store.SaveStoreToFileWithOptions(filename, SaveOptions{Compression: persistence.CompressionGzip}); err == nil {
+		t.Error("expected gzip to be refused for JSON snapshots")
+	}
+}
+
+func TestSnapshotLoadsLegacyCollections(t *testing.T) {
+	source := NewShardedStore()
+	defer source.Close()
+	source.XAdd("events", map[string]interface{}{"n": 1}, XAddOptions{})
+	source.XGroupCreate("events", "g", "0", false)
+	stream, _ := json.Marshal(source.getShard("events").data["events"].Value)
+
+	// Before version 2, collections were saved as plain JSON with their type
+	filename := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(filename, []byte(`{
+		"greeting": {"Value": "hello", "Type": "string"},
+		"list": {"Value": ["a", 2], "Type": "list"},
+		"set": {"Value": ["x", "y"], "Type": "set"},
+		"board": {"Value": [{"member": "alice", "score": 1.5}], "Type": "zset"},
+		"events": {"Value": `+string(stream)+`, "Type": "stream"}
+	}`), 0o644)
+
+	store := NewShardedStore()
+	defer store.Close()
+	if err := store.LoadStoreFromFile(filename); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if list, _ := store.LRange("list", 0, -1); !reflect.DeepEqual(list, []interface{}{"a", 2.0}) {
+		t.Errorf("expected [a 2], got %#v", list)
+	}
+	if found, _ := store.SIsMember("set", "y"); !found {
+		t.Error("expected the set to be restored")
+	}
+	if score, _, _ := store.ZScore("board", "alice"); score != 1.5 {
+		t.Errorf("expected the score 1.5, got %v", score)
+	}
+	entries, err := store.XReadGroup(context.Background(), "g", "c", []string{"events"}, []string{">"}, XReadOptions{})
+	if err != nil || len(entries["events"]) != 1 {
+		t.Errorf("expected the stream and its group to be restored, got %v (%v)", entries, err)
+	}
+
+	os.WriteFile(filename, []byte(`{"bad": {"Value": 1, "Type": "hash"}}`), 0o644)
+	if err := store.LoadStoreFromFile(filename); err == nil {
+		t.Error("expected a collection of the wrong shape to be refused")
+	}
+}
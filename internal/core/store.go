@@ -3,9 +3,12 @@ package core
 import (
 	"golang-memory-store/internal/persistence"
 
+	"encoding/json"
+	"fmt"
 	"hash/fnv"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,86 +16,260 @@ const ShardCount = 16
 
 type Entry struct {
 	Value      interface{}
-	Expiration int64
+	Type       ValueType
+	Expiration int64  // Unix milliseconds; 0 means the key does not expire
+	Version    uint64 // changes on every write, used for compare-and-swap
+
+	size   int64        // estimated memory footprint charged to the shard
+	access *accessStats // eviction metadata, shared by every copy of the entry
+}
+
+// isExpired reports whether the entry has a TTL that elapsed before now (Unix milliseconds)
+func (e Entry) isExpired(now int64) bool {
+	return e.Expiration > 0 && now > e.Expiration
 }
 
 type ShardedStore struct {
-	shards []Store
+	shards  []Store
+	config  Config
+	blocked blockedPops
+	pubsub  *PubSub
+	events  *keyEvents
+	aof     *commandLog
+
+	streamReaders streamWaiters
+
+	changes   *atomic.Int64 // writes since the last snapshot, shared by all shards
+	snapshots snapshots
+
+	replaying   atomic.Bool // the AOF is being replayed, see replayAOF
+	evictions   atomic.Int64
+	expiredKeys atomic.Int64
+	stopExpire  chan struct{}
+	expireDone  chan struct{}
+	closed      chan struct{} // closed by Close, see Done
+	closeOnce   sync.Once
 }
 
 type Store struct {
-	data  map[string]Entry
-	mutex sync.RWMutex
+	data    map[string]Entry
+	expires map[string]int64 // keys with a TTL, indexed for the expiration cycle
+	order   *skipList        // every key in lexical order, for Scan
+	mutex   sync.RWMutex
+
+	used     atomic.Int64   // estimated bytes held by this shard
+	versions *atomic.Uint64 // version counter shared by all shards
+	changes  *atomic.Int64  // write counter shared by all shards
+	events   *keyEvents     // keyspace event dispatcher shared by all shards
+	aof      *commandLog    // append-only file shared by all shards, nil when disabled
 }
 
 // NewShardedStore initializes a new sharded store with independent locks
+// and starts the background expiration cycle. Call Close to stop it.
 func NewShardedStore() *ShardedStore {
+	return NewShardedStoreWithConfig(Config{})
+}
+
+// NewShardedStoreWithConfig initializes a sharded store with memory limits and an eviction policy.
+func NewShardedStoreWithConfig(config Config) *ShardedStore {
+	if config.EvictionPolicy == "" {
+		config.EvictionPolicy = NoEviction
+	}
+	if config.ExpireInterval <= 0 {
+		config.ExpireInterval = DefaultExpireInterval
+	}
+	if config.AOFRewritePercentage == 0 {
+		config.AOFRewritePercentage = DefaultAOFRewritePercentage
+	}
+	if config.AOFRewriteMinSize <= 0 {
+		config.AOFRewriteMinSize = DefaultAOFRewriteMinSize
+	}
+
+	versions := new(atomic.Uint64)
+	changes := new(atomic.Int64)
+	events := newKeyEvents(config.EventQueue)
 	shards := make([]Store, ShardCount)
 	for i := 0; i < ShardCount; i++ {
-		shards[i] = Store{data: make(map[string]Entry)}
+		shards[i] = Store{
+			data:     make(map[string]Entry),
+			expires:  make(map[string]int64),
+			order:    newSkipList(),
+			versions: versions,
+			changes:  changes,
+			events:   events,
+		}
 	}
-	return &ShardedStore{shards: shards}
+	ss := &ShardedStore{shards: shards, config: config, pubsub: NewPubSub(config.SubscriberBuffer), events: events, changes: changes, closed: make(chan struct{})}
+	ss.startExpiration(config.ExpireInterval)
+	return ss
+}
+
+// Close stops the background expiration cycle and scheduled snapshots, ends every Pub/Sub subscription,
+// delivers pending keyspace events and closes the append-only file once a running rewrite
+// has finished, then closes the Done channel. It is safe to call more than once.
+func (ss *ShardedStore) Close() {
+	ss.closeOnce.Do(func() {
+		close(ss.stopExpire)
+		<-ss.expireDone
+		ss.snapshots.stopSchedule()
+		ss.pubsub.Close()
+		ss.events.close()
+		if ss.aof != nil {
+			if err := ss.aof.close(); err != nil {
+				log.Println("Error closing AOF:", err)
+			}
+		}
+		close(ss.closed)
+	})
+}
+
+// Done returns a channel that is closed once the store is closed, so that long-running
+// readers such as event streams can end with it.
+func (ss *ShardedStore) Done() <-chan struct{} {
+	return ss.closed
+}
+
+// put stores an entry under a new version and keeps the expiration index and
+// memory accounting in sync. Caller must hold the write lock.
+func (s *Store) put(key string, entry Entry) {
+	if old, found := s.data[key]; found {
+		s.used.Add(-old.size)
+	} else {
+		s.order.insert(0, key)
+	}
+	if entry.access == nil {
+		entry.access = newAccessStats()
+	}
+	entry.Type = typeOf(entry.Value)
+	entry.Version = s.versions.Add(1)
+	s.changes.Add(1)
+	entry.size = entrySize(key, entry.Value)
+	s.used.Add(entry.size)
+
+	s.data[key] = entry
+	if entry.Expiration > 0 {
+		s.expires[key] = entry.Expiration
+	} else {
+		delete(s.expires, key)
+	}
+}
+
+// remove deletes a key and its expiration index entry. Caller must hold the write lock.
+func (s *Store) remove(key string) {
+	if old, found := s.data[key]; found {
+		s.used.Add(-old.size)
+		s.changes.Add(1)
+		s.order.delete(0, key)
+	}
+	delete(s.data, key)
+	delete(s.expires, key)
 }
 
 // getShard selects the shard for a given key using a hash function
 func (ss *ShardedStore) getShard(key string) *Store {
+	return &ss.shards[shardIndex(key)]
+}
+
+// shardIndex hashes a key to the index of its shard
+func shardIndex(key string) int {
 	hash := fnv.New32a()
 	hash.Write([]byte(key))
-	return &ss.shards[int(hash.Sum32())%ShardCount]
+	return int(hash.Sum32() % ShardCount)
 }
 
-// Set adds or updates a key-value pair with optional TTL (in seconds)
-func (ss *ShardedStore) Set(key string, value interface{}, ttl int) {
-	shard := ss.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
-
-	expiration := int64(0)
-	if ttl > 0 {
-		expiration = time.Now().Add(time.Duration(ttl) * time.Second).Unix()
-	}
-	shard.data[key] = Entry{Value: value, Expiration: expiration}
+// Set adds or updates a key-value pair with optional TTL (in seconds).
+// It returns ErrOutOfMemory when the store is full and the policy forbids eviction.
+func (ss *ShardedStore) Set(key string, value interface{}, ttl int) error {
+	_, err := ss.SetWithOptions(key, value, SetOptions{TTL: ttl})
+	return err
 }
 
-// Get retrieves the value associated with a key
+// Get retrieves the value associated with a key and records the access for eviction
 func (ss *ShardedStore) Get(key string) (interface{}, bool) {
 	shard := ss.getShard(key)
 	shard.mutex.RLock()
 	defer shard.mutex.RUnlock()
 
 	entry, found := shard.data[key]
-	if !found || (entry.Expiration > 0 && time.Now().Unix() > entry.Expiration) {
+	if !found || entry.isExpired(time.Now().UnixMilli()) {
 		return nil, false
 	}
+	shard.touch(key, entry)
 	return entry.Value, true
 }
 
+// GetString retrieves a plain value written with Set. Unlike Get it returns
+// ErrWrongType when the key holds a collection such as a list.
+func (ss *ShardedStore) GetString(key string) (interface{}, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	entry, found := shard.lookup(key, time.Now().UnixMilli())
+	if !found {
+		return nil, false, nil
+	}
+	if entry.Type != TypeString {
+		return nil, false, ErrWrongType
+	}
+	shard.touch(key, entry)
+	return entry.Value, true, nil
+}
+
 // Delete removes a key-value pair from the store
 func (ss *ShardedStore) Delete(key string) {
 	shard := ss.getShard(key)
 	shard.mutex.Lock()
 	defer shard.mutex.Unlock()
-	delete(shard.data, key)
+	if _, found := shard.lookup(key, time.Now().UnixMilli()); found {
+		shard.events.emit(key, EventDel)
+		shard.aof.appendDel(key)
+	}
+	shard.remove(key)
 }
 
-// LoadStoreFromFile loads data from a file into the sharded store.
+// LoadStoreFromFile loads data from a file into the sharded store, in either snapshot
+// format. A snapshot that fails its checksum is refused with persistence.ErrCorruptSnapshot
+// and nothing is loaded.
 func (ss *ShardedStore) LoadStoreFromFile(filename string) error {
+	format, err := persistence.SnapshotFormatOf(filename)
+	if err == nil && format == persistence.FormatBinary {
+		err = ss.loadBinarySnapshot(filename)
+		if err != nil {
+			log.Println("Error loading store from file:", err)
+			return err
+		}
+		ss.changes.Store(0)
+		return nil
+	}
+
 	// Read data from file
-	fullData := make(map[string]Entry)
-	err := persistence.LoadFromFile(filename, &fullData)
+	raw := make(map[string]json.RawMessage)
+	version, err := persistence.LoadFromFile(filename, &raw, ss.config.Encryption)
+	var fullData map[string]Entry
+	if err == nil {
+		fullData, err = decodeSnapshot(version, raw)
+	}
 	if err != nil {
 		log.Println("Error loading store from file:", err)
 		return err
 	}
 
-	// Distribute data across shards
+	// Distribute data across shards, dropping entries that expired while on disk
+	now := time.Now().UnixMilli()
 	for key, entry := range fullData {
+		entry.Expiration = normalizeExpiration(entry.Expiration)
+		if entry.isExpired(now) {
+			continue
+		}
 		shard := ss.getShard(key)
 		shard.mutex.Lock()
-		shard.data[key] = entry
+		shard.put(key, entry)
 		shard.mutex.Unlock()
 	}
 
+	// The loaded data is already on disk.
+	ss.changes.Store(0)
 	return nil
 }
 
@@ -103,39 +280,49 @@ func (ss *ShardedStore) SaveStoreToFileAsync(filename string) {
 	}()
 }
 
-// SaveStoreToFile saves the entire store to a file (Blocking Operation)
-func (ss *ShardedStore) SaveStoreToFile(filename string) {
-	fullData := make(map[string]Entry)
-	for i := 0; i < ShardCount; i++ {
-		shard := &ss.shards[i]
-		shard.mutex.RLock()
-		for key, entry := range shard.data {
-			fullData[key] = entry
-		}
-		shard.mutex.RUnlock()
-	}
-	err := persistence.SaveToFile(filename, fullData)
-	if err != nil {
-		log.Println("Error saving store to file:", err)
+// SaveStoreToFile saves the entire store to a JSON file (Blocking Operation). The previous
+// snapshot is replaced atomically, so it stays intact if the save fails. A save already
+// in progress is waited for rather than overlapped.
+func (ss *ShardedStore) SaveStoreToFile(filename string) error {
+	return ss.SaveStoreToFileWithOptions(filename, SaveOptions{})
+}
+
+// SaveStoreToFileWithOptions is like SaveStoreToFile but picks the snapshot format.
+func (ss *ShardedStore) SaveStoreToFileWithOptions(filename string, options SaveOptions) error {
+	if err := options.validate(); err != nil {
+		return err
 	}
+	ss.snapshots.mutex.Lock()
+	defer ss.snapshots.mutex.Unlock()
+	return ss.saveStoreToFile(filename, options)
 }
 
-// GetList retrieves a list from the store, creating one if it doesn't exist.
-func (ss *ShardedStore) GetList(key string) *List {
-	shard := ss.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
+// saveStoreToFile saves the store and records the outcome. Writes made while the shards
+// are copied stay counted as changes, since the copy may have missed them. Caller must
+// hold the snapshots mutex.
+func (ss *ShardedStore) saveStoreToFile(filename string, options SaveOptions) error {
+	ss.snapshots.saving.Store(true)
+	defer ss.snapshots.saving.Store(false)
+	started := time.Now()
+	changes := ss.changes.Load()
 
-	entry, found := shard.data[key]
-	if found {
-		if list, ok := entry.Value.(*List); ok {
-			return list
+	var err error
+	if options.Format == persistence.FormatBinary {
+		err = persistence.SaveBinarySnapshot(filename, options.Compression, ss.config.Encryption, ss.writeBinarySnapshot)
+	} else {
+		var fullData map[string]persistence.SnapshotEntry
+		fullData, err = ss.snapshotEntries()
+		if err == nil {
+			err = persistence.SaveToFile(filename, fullData, len(fullData), ss.config.Encryption)
 		}
 	}
-
-	list := NewList()
-	shard.data[key] = Entry{Value: list}
-	return list
+	ss.snapshots.record(started, err)
+	if err != nil {
+		log.Println("Error saving store to file:", err)
+		return err
+	}
+	ss.changes.Add(-changes)
+	return nil
 }
 
 // SaveStoreToDBAsync saves the current state of the in-memory store to the database asynchronously.
@@ -147,39 +334,48 @@ func (ss *ShardedStore) SaveStoreToDBAsync() error {
 }
 
 // SaveStoreToDB saves the current state of the in-memory store to the database (Blocking Operation).
+// Values are saved with their types and keys with their expiration, and the previous
+// contents of the database are replaced.
 func (ss *ShardedStore) SaveStoreToDB() error {
-	convertedData := make(map[string]interface{})
-
-	for i := 0; i < ShardCount; i++ {
-		shard := &ss.shards[i]
-		shard.mutex.RLock()
-		for key, entry := range shard.data {
-			convertedData[key] = entry.Value
-		}
-		shard.mutex.RUnlock()
+	entries, err := ss.snapshotEntries()
+	if err == nil {
+		err = persistence.SaveToDB(entries)
 	}
-
-	err := persistence.SaveToDB(convertedData)
 	if err != nil {
 		log.Println("Error saving store to DB:", err)
 	}
 	return err
 }
 
-// LoadStoreFromDB loads data from the database into the in-memory store.
+// LoadStoreFromDB loads data from the database into the in-memory store, with the types
+// and expirations it was saved with. Keys that expired in the meantime are dropped. A row
+// that cannot be decoded fails the load before anything is loaded.
 func (ss *ShardedStore) LoadStoreFromDB() error {
-	data, err := persistence.LoadFromDB()
+	saved, err := persistence.LoadFromDB()
 	if err != nil {
 		log.Println("Error loading store from DB:", err)
 		return err
 	}
+	entries := make(map[string]Entry, len(saved))
+	for key, entry := range saved {
+		value, err := persistence.DecodeValue(entry.Value)
+		if err != nil {
+			err = fmt.Errorf("key %q: %w", key, err)
+			log.Println("Error loading store from DB:", err)
+			return err
+		}
+		entries[key] = Entry{Value: value, Expiration: normalizeExpiration(entry.Expiration)}
+	}
 
-	for key, value := range data {
+	now := time.Now().UnixMilli()
+	for key, entry := range entries {
+		if entry.isExpired(now) {
+			continue
+		}
 		shard := ss.getShard(key)
 		shard.mutex.Lock()
-		shard.data[key] = Entry{Value: value}
+		shard.put(key, entry)
 		shard.mutex.Unlock()
 	}
-
 	return nil
 }
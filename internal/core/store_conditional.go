@@ -0,0 +1,136 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidOptions is returned when mutually exclusive write conditions are combined
+var ErrInvalidOptions = errors.New("NX, XX and version conditions are mutually exclusive")
+
+// SetOptions controls a conditional write. The zero value is a plain Set without TTL.
+type SetOptions struct {
+	TTL       int     // seconds; 0 means no expiration
+	NX        bool    // only write when the key does not exist
+	XX        bool    // only write when the key already exists
+	Get       bool    // return the previous value, which must be a plain value
+	IfVersion *uint64 // only write when the current version matches; 0 means the key must not exist
+}
+
+// SetResult reports the outcome of a conditional write
+type SetResult struct {
+	Written bool        // false when the condition was not met
+	Version uint64      // version of the key after the call, 0 when it does not exist
+	Old     interface{} // previous value, only filled in with SetOptions.Get
+	HadOld  bool        // whether the key existed before the call
+}
+
+// SetWithOptions writes value when the conditions in opts hold. Not meeting a
+// condition is not an error: the result reports Written=false instead.
+func (ss *ShardedStore) SetWithOptions(key string, value interface{}, opts SetOptions) (SetResult, error) {
+	conditions := 0
+	for _, set := range []bool{opts.NX, opts.XX, opts.IfVersion != nil} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		return SetResult{}, ErrInvalidOptions
+	}
+
+	if err := ss.ensureCapacity(key); err != nil {
+		return SetResult{}, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	now := time.Now()
+	old, found := shard.lookup(key, now.UnixMilli())
+	result := SetResult{HadOld: found, Version: old.Version}
+	if opts.Get && found {
+		if old.Type != TypeString {
+			return SetResult{}, ErrWrongType
+		}
+		result.Old = old.Value
+	}
+
+	switch {
+	case opts.NX && found,
+		opts.XX && !found,
+		opts.IfVersion != nil && *opts.IfVersion != old.Version:
+		return result, nil
+	}
+
+	shard.put(key, Entry{Value: value, Expiration: expirationAt(now, opts.TTL)})
+	shard.events.emit(key, EventSet)
+	shard.aof.appendSet(key, shard.data[key])
+
+	result.Written = true
+	result.Version = shard.data[key].Version
+	return result, nil
+}
+
+// SetNX writes value only when key does not exist and reports whether it was written.
+func (ss *ShardedStore) SetNX(key string, value interface{}, ttl int) (bool, error) {
+	result, err := ss.SetWithOptions(key, value, SetOptions{TTL: ttl, NX: true})
+	return result.Written, err
+}
+
+// SetXX writes value only when key already exists and reports whether it was written.
+func (ss *ShardedStore) SetXX(key string, value interface{}, ttl int) (bool, error) {
+	result, err := ss.SetWithOptions(key, value, SetOptions{TTL: ttl, XX: true})
+	return result.Written, err
+}
+
+// GetSet writes value and returns the previous plain value, if any.
+func (ss *ShardedStore) GetSet(key string, value interface{}, ttl int) (interface{}, bool, error) {
+	result, err := ss.SetWithOptions(key, value, SetOptions{TTL: ttl, Get: true})
+	return result.Old, result.HadOld, err
+}
+
+// CompareAndSwap writes value only when the key is still at version, as returned by
+// GetWithVersion or Version. A version of 0 expects the key not to exist.
+func (ss *ShardedStore) CompareAndSwap(key string, version uint64, value interface{}, ttl int) (bool, error) {
+	result, err := ss.SetWithOptions(key, value, SetOptions{TTL: ttl, IfVersion: &version})
+	return result.Written, err
+}
+
+// GetWithVersion is like GetString but also returns the version for CompareAndSwap.
+func (ss *ShardedStore) GetWithVersion(key string) (interface{}, uint64, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	entry, found := shard.lookup(key, time.Now().UnixMilli())
+	if !found {
+		return nil, 0, false, nil
+	}
+	if entry.Type != TypeString {
+		return nil, 0, false, ErrWrongType
+	}
+	shard.touch(key, entry)
+	return entry.Value, entry.Version, true, nil
+}
+
+// Version returns the current version of key, whatever its type, or 0 when it does not exist.
+func (ss *ShardedStore) Version(key string) uint64 {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	entry, found := shard.lookup(key, time.Now().UnixMilli())
+	if !found {
+		return 0
+	}
+	return entry.Version
+}
+
+// expirationAt converts a TTL in seconds to an absolute expiration in Unix milliseconds; 0 means none.
+func expirationAt(now time.Time, ttl int) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return now.Add(time.Duration(ttl) * time.Second).UnixMilli()
+}
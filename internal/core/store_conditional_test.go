@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetNXAndXX(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if ok, err := store.SetXX("user", "a", 0); ok || err != nil {
+		t.Errorf("Expected XX on a missing key to be skipped, got %v (%v)", ok, err)
+	}
+	if ok, _ := store.SetNX("user", "a", 0); !ok {
+		t.Error("Expected NX on a missing key to write")
+	}
+	if ok, _ := store.SetNX("user", "b", 0); ok {
+		t.Error("Expected NX on an existing key to be skipped")
+	}
+	if ok, _ := store.SetXX("user", "c", 0); !ok {
+		t.Error("Expected XX on an existing key to write")
+	}
+	if val, _ := store.Get("user"); val != "c" {
+		t.Errorf("Expected 'c', got %v", val)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if old, found, _ := store.GetSet("key", "first", 0); found || old != nil {
+		t.Errorf("Expected no previous value, got %v", old)
+	}
+	if old, found, _ := store.GetSet("key", "second", 0); !found || old != "first" {
+		t.Errorf("Expected 'first', got %v", old)
+	}
+
+	store.RPush("mylist", "a")
+	if _, _, err := store.GetSet("mylist", "x", 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if ok, _ := store.CompareAndSwap("key", 0, "created", 0); !ok {
+		t.Error("Expected CAS with version 0 to create a missing key")
+	}
+
+	_, version, _, _ := store.GetWithVersion("key")
+	if ok, _ := store.CompareAndSwap("key", version, "updated", 0); !ok {
+		t.Error("Expected CAS with the current version to write")
+	}
+	if ok, _ := store.CompareAndSwap("key", version, "stale", 0); ok {
+		t.Error("Expected CAS with a stale version to be rejected")
+	}
+	if val, _ := store.Get("key"); val != "updated" {
+		t.Errorf("Expected 'updated', got %v", val)
+	}
+}
+
+func TestVersionChangesOnEveryWrite(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if store.Version("mylist") != 0 {
+		t.Error("Expected version 0 for a missing key")
+	}
+	store.RPush("mylist", "a")
+	v1 := store.Version("mylist")
+	store.LRange("mylist", 0, -1)
+	if store.Version("mylist") != v1 {
+		t.Error("Expected reads to keep the version")
+	}
+	store.RPush("mylist", "b")
+	if store.Version("mylist") == v1 {
+		t.Error("Expected a push to change the version")
+	}
+
+	store.Delete("mylist")
+	store.RPush("mylist", "a")
+	if store.Version("mylist") == v1 {
+		t.Error("Expected a recreated key to get a new version")
+	}
+}
+
+func TestSetRejectsConflictingOptions(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if _, err := store.SetWithOptions("key", "v", SetOptions{NX: true, XX: true}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotInteger is returned when a counter holds a value that is not a 64-bit integer
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+
+	// ErrNotFloat is returned when a counter holds a value that is not a number, or the result would be NaN or Infinity
+	ErrNotFloat = errors.New("value is not a valid float")
+
+	// ErrOverflow is returned when an increment or decrement would overflow a 64-bit integer
+	ErrOverflow = errors.New("increment or decrement would overflow")
+)
+
+// Incr atomically adds one to the integer stored at key and returns the new value.
+func (ss *ShardedStore) Incr(key string) (int64, error) {
+	return ss.IncrBy(key, 1)
+}
+
+// Decr atomically subtracts one from the integer stored at key and returns the new value.
+func (ss *ShardedStore) Decr(key string) (int64, error) {
+	return ss.IncrBy(key, -1)
+}
+
+// DecrBy atomically subtracts delta from the integer stored at key and returns the new value.
+func (ss *ShardedStore) DecrBy(key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return ss.IncrBy(key, -delta)
+}
+
+// IncrBy atomically adds delta to the integer stored at key and returns the new value.
+// A missing key counts as zero; an existing TTL is preserved.
+func (ss *ShardedStore) IncrBy(key string, delta int64) (int64, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.incrBy(key, delta, time.Now().UnixMilli())
+}
+
+// incrBy implements IncrBy. Caller must hold the write lock.
+func (s *Store) incrBy(key string, delta int64, now int64) (int64, error) {
+	entry, found := s.lookup(key, now)
+	var current int64
+	if found {
+		if entry.Type != TypeString {
+			return 0, ErrWrongType
+		}
+		var ok bool
+		if current, ok = toInteger(entry.Value); !ok {
+			return 0, ErrNotInteger
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	entry.Value = current + delta
+	s.put(key, entry)
+	s.touch(key, s.data[key])
+	s.events.emit(key, EventIncrBy)
+	s.aof.appendSet(key, s.data[key])
+	return current + delta, nil
+}
+
+// IncrByFloat atomically adds delta to the number stored at key and returns the new value.
+// A missing key counts as zero; an existing TTL is preserved.
+func (ss *ShardedStore) IncrByFloat(key string, delta float64) (float64, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.incrByFloat(key, delta, time.Now().UnixMilli())
+}
+
+// incrByFloat implements IncrByFloat. Caller must hold the write lock.
+func (s *Store) incrByFloat(key string, delta float64, now int64) (float64, error) {
+	entry, found := s.lookup(key, now)
+	var current float64
+	if found {
+		if entry.Type != TypeString {
+			return 0, ErrWrongType
+		}
+		var ok bool
+		if current, ok = toFloat(entry.Value); !ok {
+			return 0, ErrNotFloat
+		}
+	}
+
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, ErrNotFloat
+	}
+
+	entry.Value = result
+	s.put(key, entry)
+	s.touch(key, s.data[key])
+	s.events.emit(key, EventIncrByFloat)
+	s.aof.appendSet(key, s.data[key])
+	return result, nil
+}
+
+// toInteger converts a stored value to an int64. Numbers decoded from JSON arrive as
+// float64 and are accepted when they are integral and in range.
+func toInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toFloat converts a stored value to a float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrDecr(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if n, err := store.Incr("counter"); err != nil || n != 1 {
+		t.Errorf("Expected 1, got %d (%v)", n, err)
+	}
+	if n, err := store.IncrBy("counter", 41); err != nil || n != 42 {
+		t.Errorf("Expected 42, got %d (%v)", n, err)
+	}
+	if n, err := store.DecrBy("counter", 2); err != nil || n != 40 {
+		t.Errorf("Expected 40, got %d (%v)", n, err)
+	}
+	if n, err := store.Decr("counter"); err != nil || n != 39 {
+		t.Errorf("Expected 39, got %d (%v)", n, err)
+	}
+}
+
+func TestIncrParsesExistingValues(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("fromString", "10", 0)
+	store.Set("fromJSON", float64(7), 0)
+	store.Set("fraction", 1.5, 0)
+	store.Set("text", "abc", 0)
+
+	if n, err := store.Incr("fromString"); err != nil || n != 11 {
+		t.Errorf("Expected 11, got %d (%v)", n, err)
+	}
+	if n, err := store.Incr("fromJSON"); err != nil || n != 8 {
+		t.Errorf("Expected 8, got %d (%v)", n, err)
+	}
+	if _, err := store.Incr("fraction"); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+	if _, err := store.IncrByFloat("text", 1); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat, got %v", err)
+	}
+}
+
+func TestIncrOverflowAndWrongType(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("max", int64(math.MaxInt64), 0)
+	if _, err := store.Incr("max"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if _, err := store.DecrBy("other", math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+
+	store.RPush("mylist", "a")
+	if _, err := store.Incr("mylist"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("price", "10.5", 0)
+	if f, err := store.IncrByFloat("price", 0.25); err != nil || f != 10.75 {
+		t.Errorf("Expected 10.75, got %v (%v)", f, err)
+	}
+	if _, err := store.IncrByFloat("price", math.Inf(1)); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat, got %v", err)
+	}
+}
+
+func TestIncrPreservesTTL(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("counter", 1, 1)
+	store.Incr("counter")
+	time.Sleep(2 * time.Second)
+
+	if _, found := store.Get("counter"); found {
+		t.Error("Expected the counter to keep its TTL and expire")
+	}
+}
+
+func TestIncrIsAtomic(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Incr("counter")
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := store.Get("counter"); val != int64(100) {
+		t.Errorf("Expected 100, got %v", val)
+	}
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"math"
+	"time"
+)
+
+// hashAt returns the live hash stored at key and records the access, or nil when the key
+// does not exist. Caller must hold the shard lock.
+func (s *Store) hashAt(key string, now int64) (*Hash, error) {
+	entry, found := s.lookup(key, now)
+	if !found {
+		return nil, nil
+	}
+	hash, ok := entry.Value.(*Hash)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	s.touch(key, entry)
+	return hash, nil
+}
+
+// HSet writes fields into the hash at key, creating it if needed, and returns the number
+// of fields that were added rather than updated.
+func (ss *ShardedStore) HSet(key string, fields map[string]interface{}) (int, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	if hash == nil {
+		hash = NewHash()
+		shard.put(key, Entry{Value: hash})
+	}
+
+	added := 0
+	var delta int64
+	for field, value := range fields {
+		old, found := hash.Set(field, value)
+		if found {
+			delta += valueSize(value) - valueSize(old)
+		} else {
+			delta += fieldSize(field, value)
+			added++
+		}
+	}
+	shard.adjust(key, delta)
+	shard.events.emit(key, EventHSet)
+	shard.aof.append(aofRecord{Op: "hset", Key: key, Fields: fields})
+	return added, nil
+}
+
+// HGet returns the value of field in the hash at key.
+func (ss *ShardedStore) HGet(key, field string) (interface{}, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return nil, false, err
+	}
+	value, found := hash.Get(field)
+	return value, found, nil
+}
+
+// HMGet returns the values of fields in order, with nil for missing fields.
+func (ss *ShardedStore) HMGet(key string, fields ...string) ([]interface{}, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	values := make([]interface{}, len(fields))
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return values, err
+	}
+	for i, field := range fields {
+		values[i], _ = hash.Get(field)
+	}
+	return values, nil
+}
+
+// HDel removes fields from the hash and returns how many existed. Removing the last field removes the key.
+func (ss *ShardedStore) HDel(key string, fields ...string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var delta int64
+	for _, field := range fields {
+		if old, found := hash.Del(field); found {
+			delta -= fieldSize(field, old)
+			removed++
+		}
+	}
+	if removed > 0 {
+		shard.adjust(key, delta)
+		shard.events.emit(key, EventHDel)
+		shard.aof.append(aofRecord{Op: "hdel", Key: key, Names: fields})
+	}
+	shard.removeIfEmpty(key, hash)
+	return removed, nil
+}
+
+// HIncrBy atomically adds delta to the integer stored in field and returns the new value.
+// A missing key or field counts as zero.
+func (ss *ShardedStore) HIncrBy(key, field string, delta int64) (int64, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if hash != nil {
+		if value, found := hash.Get(field); found {
+			var ok bool
+			if current, ok = toInteger(value); !ok {
+				return 0, ErrNotInteger
+			}
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	if hash == nil {
+		hash = NewHash()
+		shard.put(key, Entry{Value: hash})
+	}
+	old, found := hash.Set(field, current+delta)
+	if found {
+		shard.adjust(key, valueSize(current+delta)-valueSize(old))
+	} else {
+		shard.adjust(key, fieldSize(field, current+delta))
+	}
+	shard.events.emit(key, EventHIncrBy)
+	shard.aof.append(aofRecord{Op: "hset", Key: key, Fields: map[string]interface{}{field: current + delta}})
+	return current + delta, nil
+}
+
+// HGetAll returns all fields and values of the hash. A missing key yields an empty map.
+func (ss *ShardedStore) HGetAll(key string) (map[string]interface{}, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return map[string]interface{}{}, err
+	}
+	return hash.GetAll(), nil
+}
+
+// HKeys returns the field names of the hash in lexical order.
+func (ss *ShardedStore) HKeys(key string) ([]string, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return []string{}, err
+	}
+	return hash.Keys(), nil
+}
+
+// HLen returns the number of fields in the hash, or 0 when it does not exist.
+func (ss *ShardedStore) HLen(key string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return 0, err
+	}
+	return hash.Len(), nil
+}
+
+// HScan iterates over the fields of a hash in lexical order with a stateless cursor,
+// like Scan does over keys. Only the Match and Count options apply.
+func (ss *ShardedStore) HScan(key, cursor string, opts ScanOptions) (string, map[string]interface{}, error) {
+	_, after, hasAfter, err := decodeCursor(cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	fields := make(map[string]interface{})
+	hash, err := shard.hashAt(key, time.Now().UnixMilli())
+	if hash == nil || err != nil {
+		return ScanStart, fields, err
+	}
+
+	fields, last, more := hash.Scan(after, hasAfter, count, opts.Match)
+	if !more {
+		return ScanStart, fields, nil
+	}
+	return encodeCursor(0, last, true), fields, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHSetHGet(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	added, err := store.HSet("user:1", map[string]interface{}{"name": "alice", "email": "a@example.com"})
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 new fields, got %d (%v)", added, err)
+	}
+	added, _ = store.HSet("user:1", map[string]interface{}{"name": "bob", "city": "Paris"})
+	if added != 1 {
+		t.Errorf("Expected 1 new field, got %d", added)
+	}
+
+	if value, found, _ := store.HGet("user:1", "name"); !found || value != "bob" {
+		t.Errorf("Expected 'bob', got %v", value)
+	}
+	if _, found, _ := store.HGet("user:1", "missing"); found {
+		t.Error("Expected missing field not to be found")
+	}
+	values, _ := store.HMGet("user:1", "city", "missing", "email")
+	if !reflect.DeepEqual(values, []interface{}{"Paris", nil, "a@example.com"}) {
+		t.Errorf("Unexpected HMGet result %v", values)
+	}
+	if store.Type("user:1") != TypeHash {
+		t.Errorf("Expected type hash, got %s", store.Type("user:1"))
+	}
+}
+
+func TestHDelRemovesEmptyHash(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.HSet("h", map[string]interface{}{"a": 1, "b": 2})
+	removed, _ := store.HDel("h", "a", "missing")
+	if removed != 1 {
+		t.Errorf("Expected 1 removed field, got %d", removed)
+	}
+	store.HDel("h", "b")
+	if store.Type("h") != TypeNone {
+		t.Error("Expected the key to be removed with its last field")
+	}
+	if store.Stats().UsedMemory != 0 {
+		t.Errorf("Expected no memory in use, got %d", store.Stats().UsedMemory)
+	}
+}
+
+func TestHIncrBy(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if value, err := store.HIncrBy("h", "visits", 5); err != nil || value != 5 {
+		t.Errorf("Expected 5, got %d (%v)", value, err)
+	}
+	if value, _ := store.HIncrBy("h", "visits", -2); value != 3 {
+		t.Errorf("Expected 3, got %d", value)
+	}
+	store.HSet("h", map[string]interface{}{"name": "alice"})
+	if _, err := store.HIncrBy("h", "name", 1); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+}
+
+func TestHashWrongType(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("string", "value", 0)
+	if _, err := store.HSet("string", map[string]interface{}{"a": 1}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := store.HGet("string", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	store.HSet("hash", map[string]interface{}{"a": 1})
+	if _, err := store.LPush("hash", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType from a list command, got %v", err)
+	}
+}
+
+func TestHScan(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	fields := map[string]interface{}{}
+	for _, field := range []string{"a1", "a2", "a3", "b1", "b2"} {
+		fields[field] = field
+	}
+	store.HSet("h", fields)
+
+	seen := map[string]interface{}{}
+	cursor := ScanStart
+	for {
+		next, page, err := store.HScan("h", cursor, ScanOptions{Match: "a*", Count: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for field, value := range page {
+			if _, dup := seen[field]; dup {
+				t.Errorf("Field %s returned twice", field)
+			}
+			seen[field] = value
+		}
+		if next == ScanStart {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 3 || seen["a2"] != "a2" {
+		t.Errorf("Expected the three 'a' fields, got %v", seen)
+	}
+
+	store.HDel("h", "a1")
+	if _, page, _ := store.HScan("h", ScanStart, ScanOptions{Match: "a*", Count: 10}); len(page) != 2 || page["a1"] != nil {
+		t.Errorf("Expected the deleted field to be gone, got %v", page)
+	}
+}
+
+func TestHashSnapshotRoundTrip(t *testing.T) {
+	entry, err := restoreLegacyValue(Entry{Type: TypeHash, Value: map[string]interface{}{"a": "1"}})
+	if err != nil {
+		t.Fatalf("restoreLegacyValue failed: %v", err)
+	}
+	hash, ok := entry.Value.(*Hash)
+	if !ok || hash.Len() != 1 {
+		t.Fatalf("Expected a restored hash, got %T", entry.Value)
+	}
+}
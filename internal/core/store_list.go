@@ -0,0 +1,258 @@
+package core
+
+import (
+	"time"
+)
+
+// GetList retrieves a list from the store, creating one if it doesn't exist.
+// It returns ErrWrongType when the key holds another kind of value.
+// The list commands below should be preferred: they run under the shard lock,
+// wake blocked clients and remove lists once they become empty.
+func (ss *ShardedStore) GetList(key string) (*List, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return nil, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = NewList()
+		shard.put(key, Entry{Value: list})
+	}
+	return list, nil
+}
+
+// listAt returns the live list stored at key and records the access, or nil when the key
+// does not exist. Caller must hold the shard lock.
+func (s *Store) listAt(key string, now int64) (*List, error) {
+	entry, found := s.lookup(key, now)
+	if !found {
+		return nil, nil
+	}
+	list, ok := entry.Value.(*List)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	s.touch(key, entry)
+	return list, nil
+}
+
+// LPush inserts values at the head of the list, creating it if needed, and returns the new length.
+func (ss *ShardedStore) LPush(key string, values ...interface{}) (int, error) {
+	return ss.push(key, true, values)
+}
+
+// RPush appends values at the tail of the list, creating it if needed, and returns the new length.
+func (ss *ShardedStore) RPush(key string, values ...interface{}) (int, error) {
+	return ss.push(key, false, values)
+}
+
+// push adds values to the list at key and hands them to clients blocked on the key.
+// It returns the length after the push, before any item is handed over.
+func (ss *ShardedStore) push(key string, left bool, values []interface{}) (int, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	length, err := shard.push(key, left, values, time.Now().UnixMilli())
+	shard.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	ss.serveBlocked(key)
+	return length, nil
+}
+
+// push adds values to the list at key, creating it if needed. Caller must hold the
+// write lock and serve blocked clients once it is released.
+func (s *Store) push(key string, left bool, values []interface{}, now int64) (int, error) {
+	list, err := s.listAt(key, now)
+	if err != nil {
+		return 0, err
+	}
+	if list == nil {
+		list = NewList()
+		s.put(key, Entry{Value: list})
+	}
+
+	var length int
+	if left {
+		length = list.LPush(values...)
+		s.events.emit(key, EventLPush)
+		s.aof.append(aofRecord{Op: "lpush", Key: key, Values: values})
+	} else {
+		length = list.RPush(values...)
+		s.events.emit(key, EventRPush)
+		s.aof.append(aofRecord{Op: "rpush", Key: key, Values: values})
+	}
+	s.adjust(key, itemsSize(values...))
+	return length, nil
+}
+
+// LPop removes and returns the head of the list. Popping the last item removes the key.
+func (ss *ShardedStore) LPop(key string) (interface{}, bool, error) {
+	return ss.pop(key, true)
+}
+
+// RPop removes and returns the tail of the list. Popping the last item removes the key.
+func (ss *ShardedStore) RPop(key string) (interface{}, bool, error) {
+	return ss.pop(key, false)
+}
+
+func (ss *ShardedStore) pop(key string, left bool) (interface{}, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return nil, false, err
+	}
+
+	var value interface{}
+	var found bool
+	event := EventRPop
+	if left {
+		value, found = list.LPop()
+		event = EventLPop
+	} else {
+		value, found = list.RPop()
+	}
+	if found {
+		shard.adjust(key, -itemsSize(value))
+		shard.events.emit(key, event)
+		shard.aof.append(aofRecord{Op: string(event), Key: key, Count: 1})
+	}
+	shard.removeIfEmpty(key, list)
+	return value, found, nil
+}
+
+// LRange returns the values between start and stop inclusive; negative indexes count from the tail.
+// A missing key yields an empty slice.
+func (ss *ShardedStore) LRange(key string, start, stop int) ([]interface{}, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return []interface{}{}, err
+	}
+	return list.Range(start, stop), nil
+}
+
+// LIndex returns the value at index; negative indexes count from the tail.
+func (ss *ShardedStore) LIndex(key string, index int) (interface{}, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return nil, false, err
+	}
+	value, found := list.Index(index)
+	return value, found, nil
+}
+
+// LSet replaces the value at index. It returns ErrNoSuchKey when the list does not exist.
+func (ss *ShardedStore) LSet(key string, index int, value interface{}) error {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+	if err := list.Set(index, value); err != nil {
+		return err
+	}
+	shard.resize(key)
+	shard.events.emit(key, EventLSet)
+	shard.aof.append(aofRecord{Op: "lset", Key: key, Index: index, Value: value})
+	return nil
+}
+
+// LInsert places value before or after the first occurrence of pivot. It returns the new length,
+// -1 when pivot is not in the list and 0 when the list does not exist.
+func (ss *ShardedStore) LInsert(key string, before bool, pivot, value interface{}) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return 0, err
+	}
+	length := list.Insert(before, pivot, value)
+	if length > 0 {
+		shard.adjust(key, itemsSize(value))
+		shard.events.emit(key, EventLInsert)
+		shard.aof.append(aofRecord{Op: "linsert", Key: key, Before: before, Pivot: pivot, Value: value})
+	}
+	return length, nil
+}
+
+// LRem removes up to count occurrences of value, see List.Rem, and returns how many were removed.
+func (ss *ShardedStore) LRem(key string, count int, value interface{}) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return 0, err
+	}
+	removed := list.Rem(count, value)
+	if removed > 0 {
+		shard.resize(key)
+		shard.events.emit(key, EventLRem)
+		shard.aof.append(aofRecord{Op: "lrem", Key: key, Count: count, Value: value})
+	}
+	shard.removeIfEmpty(key, list)
+	return removed, nil
+}
+
+// LTrim keeps only the values between start and stop inclusive. Trimming every value removes the key.
+func (ss *ShardedStore) LTrim(key string, start, stop int) error {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return err
+	}
+	list.Trim(start, stop)
+	shard.resize(key)
+	shard.events.emit(key, EventLTrim)
+	shard.aof.append(aofRecord{Op: "ltrim", Key: key, Index: start, Stop: stop})
+	shard.removeIfEmpty(key, list)
+	return nil
+}
+
+// LLen returns the length of the list, or 0 when it does not exist.
+func (ss *ShardedStore) LLen(key string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	list, err := shard.listAt(key, time.Now().UnixMilli())
+	if list == nil || err != nil {
+		return 0, err
+	}
+	return list.Len(), nil
+}
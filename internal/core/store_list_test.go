@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetListRejectsOtherTypes(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("name", "value", 0)
+
+	if _, err := store.GetList("name"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := store.LPush("name", "item"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if val, _ := store.Get("name"); val != "value" {
+		t.Errorf("Expected 'name' to keep its value, got %v", val)
+	}
+}
+
+func TestGetStringRejectsLists(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.RPush("mylist", "item")
+
+	if _, _, err := store.GetString("mylist"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if store.Type("mylist") != TypeList {
+		t.Errorf("Expected type list, got %s", store.Type("mylist"))
+	}
+}
+
+func TestListReadsDoNotCreateKeys(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if _, found, err := store.RPop("missing"); found || err != nil {
+		t.Errorf("Expected nothing to pop, got found=%v err=%v", found, err)
+	}
+	store.LRange("missing", 0, -1)
+	store.LLen("missing")
+	store.LIndex("missing", 0)
+
+	if store.Type("missing") != TypeNone {
+		t.Error("Expected read operations not to create the key")
+	}
+	if err := store.LSet("missing", 0, "x"); !errors.Is(err, ErrNoSuchKey) {
+		t.Errorf("Expected ErrNoSuchKey, got %v", err)
+	}
+}
+
+func TestEmptyListIsRemoved(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.RPush("mylist", "a", "b")
+	store.LPop("mylist")
+	store.LPop("mylist")
+	if store.Type("mylist") != TypeNone {
+		t.Error("Expected the empty list to be removed")
+	}
+
+	store.RPush("mylist", "a", "b")
+	store.LTrim("mylist", 5, 10)
+	if store.Type("mylist") != TypeNone {
+		t.Error("Expected the trimmed list to be removed")
+	}
+}
+
+func TestListSurvivesSnapshot(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.RPush("mylist", "a", "b")
+	filename := filepath.Join(t.TempDir(), "data.json")
+	store.SaveStoreToFile(filename)
+
+	restored := NewShardedStore()
+	defer restored.Close()
+	if err := restored.LoadStoreFromFile(filename); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	values, err := restored.LRange("mylist", 0, -1)
+	if err != nil || !reflect.DeepEqual(values, []interface{}{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v (%v)", values, err)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// lockShards write-locks the distinct shards holding keys in ascending index order,
+// so multi-key operations never deadlock against each other, and returns the
+// function that releases them.
+func (ss *ShardedStore) lockShards(keys []string) func() {
+	seen := make(map[int]bool, len(keys))
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		index := shardIndex(key)
+		if !seen[index] {
+			seen[index] = true
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		ss.shards[index].mutex.Lock()
+	}
+	return func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			ss.shards[indexes[i]].mutex.Unlock()
+		}
+	}
+}
+
+// MGet returns the plain values stored at keys, in order, as one consistent view.
+// Missing keys and keys holding collections yield nil.
+func (ss *ShardedStore) MGet(keys ...string) []interface{} {
+	unlock := ss.lockShards(keys)
+	defer unlock()
+
+	now := time.Now().UnixMilli()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		shard := ss.getShard(key)
+		entry, found := shard.lookup(key, now)
+		if !found || entry.Type != TypeString {
+			continue
+		}
+		shard.touch(key, entry)
+		values[i] = entry.Value
+	}
+	return values
+}
+
+// MSet writes all entries with the same optional TTL (in seconds). Either every key
+// is written or, when the memory budget does not allow it, none is.
+func (ss *ShardedStore) MSet(entries map[string]interface{}, ttl int) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	if err := ss.ensureCapacity(keys...); err != nil {
+		return err
+	}
+
+	unlock := ss.lockShards(keys)
+	defer unlock()
+
+	expiration := expirationAt(time.Now(), ttl)
+	for key, value := range entries {
+		shard := ss.getShard(key)
+		shard.put(key, Entry{Value: value, Expiration: expiration})
+		shard.events.emit(key, EventSet)
+		shard.aof.appendSet(key, shard.data[key])
+	}
+	return nil
+}
+
+// MDel removes keys atomically and returns how many of them existed.
+func (ss *ShardedStore) MDel(keys ...string) int {
+	unlock := ss.lockShards(keys)
+	defer unlock()
+
+	now := time.Now().UnixMilli()
+	removed := 0
+	for _, key := range keys {
+		shard := ss.getShard(key)
+		if _, found := shard.lookup(key, now); found {
+			removed++
+			shard.events.emit(key, EventDel)
+			shard.aof.appendDel(key)
+		}
+		shard.remove(key)
+	}
+	return removed
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMSetMGet(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	err := store.MSet(map[string]interface{}{"a": "1", "b": "2", "c": "3"}, 0)
+	if err != nil {
+		t.Fatalf("MSet failed: %v", err)
+	}
+	store.RPush("mylist", "x")
+
+	values := store.MGet("a", "missing", "c", "mylist")
+	if !reflect.DeepEqual(values, []interface{}{"1", nil, "3", nil}) {
+		t.Errorf("Expected [1 <nil> 3 <nil>], got %v", values)
+	}
+}
+
+func TestMDel(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.MSet(map[string]interface{}{"a": "1", "b": "2"}, 0)
+	if removed := store.MDel("a", "b", "missing"); removed != 2 {
+		t.Errorf("Expected 2 removals, got %d", removed)
+	}
+	if _, found := store.Get("a"); found {
+		t.Error("Expected 'a' to be removed")
+	}
+}
+
+func TestMSetIsAllOrNothing(t *testing.T) {
+	store := NewShardedStoreWithConfig(Config{MaxKeys: 3})
+	defer store.Close()
+
+	store.Set("existing", "1", 0)
+	err := store.MSet(map[string]interface{}{"a": "1", "b": "2", "c": "3"}, 0)
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("Expected ErrOutOfMemory, got %v", err)
+	}
+	if values := store.MGet("a", "b", "c"); !reflect.DeepEqual(values, []interface{}{nil, nil, nil}) {
+		t.Errorf("Expected no key to be written, got %v", values)
+	}
+}
+
+func TestMSetIsAtomicAcrossShards(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	keys := make([]string, 32)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	var wg sync.WaitGroup
+	for round := 0; round < 20; round++ {
+		wg.Add(2)
+		go func(round int) {
+			defer wg.Done()
+			entries := make(map[string]interface{}, len(keys))
+			for _, key := range keys {
+				entries[key] = round
+			}
+			store.MSet(entries, 0)
+		}(round)
+		go func() {
+			defer wg.Done()
+			values := store.MGet(keys...)
+			for _, value := range values[1:] {
+				if value != values[0] {
+					t.Errorf("Expected a consistent view, got %v", values)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
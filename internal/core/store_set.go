@@ -0,0 +1,226 @@
+package core
+
+import (
+	"time"
+)
+
+// setAt returns the live set stored at key and records the access, or nil when the key
+// does not exist. Caller must hold the shard lock.
+func (s *Store) setAt(key string, now int64) (*Set, error) {
+	entry, found := s.lookup(key, now)
+	if !found {
+		return nil, nil
+	}
+	set, ok := entry.Value.(*Set)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	s.touch(key, entry)
+	return set, nil
+}
+
+// SAdd adds members to the set at key, creating it if needed, and returns how many were new.
+func (ss *ShardedStore) SAdd(key string, members ...string) (int, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if err != nil || len(members) == 0 {
+		return 0, err
+	}
+	if set == nil {
+		set = NewSet()
+		shard.put(key, Entry{Value: set})
+	}
+	added := set.Add(members...)
+	if len(added) > 0 {
+		shard.adjust(key, membersSize(added))
+		shard.events.emit(key, EventSAdd)
+		shard.aof.append(aofRecord{Op: "sadd", Key: key, Names: added})
+	}
+	return len(added), nil
+}
+
+// SRem removes members from the set and returns how many existed. Removing the last member removes the key.
+func (ss *ShardedStore) SRem(key string, members ...string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil {
+		return 0, err
+	}
+	removed := set.Rem(members...)
+	if len(removed) > 0 {
+		shard.adjust(key, -membersSize(removed))
+		shard.events.emit(key, EventSRem)
+		shard.aof.append(aofRecord{Op: "srem", Key: key, Names: removed})
+	}
+	shard.removeIfEmpty(key, set)
+	return len(removed), nil
+}
+
+// SIsMember reports whether member is in the set at key.
+func (ss *ShardedStore) SIsMember(key, member string) (bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil {
+		return false, err
+	}
+	return set.Contains(member), nil
+}
+
+// SMembers returns the members of the set in lexical order. A missing key yields an empty slice.
+func (ss *ShardedStore) SMembers(key string) ([]string, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil {
+		return []string{}, err
+	}
+	return set.Members(), nil
+}
+
+// SCard returns the number of members in the set, or 0 when it does not exist.
+func (ss *ShardedStore) SCard(key string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SPop removes and returns up to count random members. Popping the last member removes the key.
+func (ss *ShardedStore) SPop(key string, count int) ([]string, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil || count <= 0 {
+		return []string{}, err
+	}
+	popped := set.Pop(count)
+	if len(popped) > 0 {
+		shard.adjust(key, -membersSize(popped))
+		shard.events.emit(key, EventSPop)
+		shard.aof.append(aofRecord{Op: "srem", Key: key, Names: popped})
+	}
+	shard.removeIfEmpty(key, set)
+	return popped, nil
+}
+
+// SRandMember returns random members without removing them, see Set.Random.
+func (ss *ShardedStore) SRandMember(key string, count int) ([]string, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	set, err := shard.setAt(key, time.Now().UnixMilli())
+	if set == nil || err != nil {
+		return []string{}, err
+	}
+	return set.Random(count), nil
+}
+
+// SUnion returns the members of any of the sets at keys. Missing keys count as empty sets.
+func (ss *ShardedStore) SUnion(keys ...string) ([]string, error) {
+	return ss.combineSets(keys, unionSets)
+}
+
+// SInter returns the members present in all the sets at keys.
+func (ss *ShardedStore) SInter(keys ...string) ([]string, error) {
+	return ss.combineSets(keys, interSets)
+}
+
+// SDiff returns the members of the first set that are in none of the others.
+func (ss *ShardedStore) SDiff(keys ...string) ([]string, error) {
+	return ss.combineSets(keys, diffSets)
+}
+
+// SUnionStore stores the union of the sets at keys in dest and returns its size.
+func (ss *ShardedStore) SUnionStore(dest string, keys ...string) (int, error) {
+	return ss.combineSetsStore(dest, keys, unionSets)
+}
+
+// SInterStore stores the intersection of the sets at keys in dest and returns its size.
+func (ss *ShardedStore) SInterStore(dest string, keys ...string) (int, error) {
+	return ss.combineSetsStore(dest, keys, interSets)
+}
+
+// SDiffStore stores the difference of the sets at keys in dest and returns its size.
+func (ss *ShardedStore) SDiffStore(dest string, keys ...string) (int, error) {
+	return ss.combineSetsStore(dest, keys, diffSets)
+}
+
+func (ss *ShardedStore) combineSets(keys []string, combine func([]*Set) *Set) ([]string, error) {
+	unlock := ss.lockShards(keys)
+	defer unlock()
+
+	sets, err := ss.setsAt(keys, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return combine(sets).Members(), nil
+}
+
+// combineSetsStore computes the result and writes it to dest as one atomic step across
+// the shards involved. An empty result removes dest; any previous value is replaced.
+func (ss *ShardedStore) combineSetsStore(dest string, keys []string, combine func([]*Set) *Set) (int, error) {
+	if err := ss.ensureCapacity(dest); err != nil {
+		return 0, err
+	}
+
+	unlock := ss.lockShards(append([]string{dest}, keys...))
+	defer unlock()
+
+	now := time.Now().UnixMilli()
+	sets, err := ss.setsAt(keys, now)
+	if err != nil {
+		return 0, err
+	}
+	result := combine(sets)
+
+	shard := ss.getShard(dest)
+	if result.Len() == 0 {
+		if _, found := shard.lookup(dest, now); found {
+			shard.events.emit(dest, EventDel)
+			shard.aof.appendDel(dest)
+		}
+		shard.remove(dest)
+		return 0, nil
+	}
+	shard.put(dest, Entry{Value: result})
+	shard.events.emit(dest, EventStore)
+	shard.aof.appendSet(dest, shard.data[dest])
+	return result.Len(), nil
+}
+
+// setsAt returns the sets at keys in order, nil for missing keys. Caller must hold the
+// write locks of every shard involved.
+func (ss *ShardedStore) setsAt(keys []string, now int64) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := ss.getShard(key).setAt(key, now)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSAddSRem(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if added, err := store.SAdd("tags", "go", "redis", "go"); err != nil || added != 2 {
+		t.Fatalf("Expected 2 new members, got %d (%v)", added, err)
+	}
+	if ok, _ := store.SIsMember("tags", "go"); !ok {
+		t.Error("Expected 'go' to be a member")
+	}
+	if card, _ := store.SCard("tags"); card != 2 {
+		t.Errorf("Expected 2 members, got %d", card)
+	}
+	if removed, _ := store.SRem("tags", "go", "redis", "missing"); removed != 2 {
+		t.Errorf("Expected 2 removed members, got %d", removed)
+	}
+	if store.Type("tags") != TypeNone {
+		t.Error("Expected the key to be removed with its last member")
+	}
+	if store.Stats().UsedMemory != 0 {
+		t.Errorf("Expected no memory in use, got %d", store.Stats().UsedMemory)
+	}
+}
+
+func TestSPopRemovesEmptySet(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.SAdd("s", "a", "b")
+	popped, _ := store.SPop("s", 5)
+	if len(popped) != 2 || store.Type("s") != TypeNone {
+		t.Errorf("Expected both members popped and the key removed, got %v", popped)
+	}
+}
+
+func TestSetWrongType(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("string", "value", 0)
+	if _, err := store.SAdd("string", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	store.SAdd("set", "a")
+	if _, err := store.SUnion("set", "string"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType from SUnion, got %v", err)
+	}
+}
+
+func TestSetAlgebraAcrossShards(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.SAdd("a", "1", "2", "3")
+	store.SAdd("b", "2", "3", "4")
+	store.SAdd("c", "3", "5")
+
+	if got, _ := store.SUnion("a", "b", "c"); !reflect.DeepEqual(got, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("Unexpected union %v", got)
+	}
+	if got, _ := store.SInter("a", "b", "c"); !reflect.DeepEqual(got, []string{"3"}) {
+		t.Errorf("Unexpected intersection %v", got)
+	}
+	if got, _ := store.SDiff("a", "b", "missing"); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("Unexpected difference %v", got)
+	}
+
+	if n, _ := store.SInterStore("dest", "a", "b"); n != 2 {
+		t.Errorf("Expected 2 members stored, got %d", n)
+	}
+	if got, _ := store.SMembers("dest"); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Errorf("Unexpected stored members %v", got)
+	}
+
+	store.Set("plain", "value", 0)
+	if n, _ := store.SUnionStore("plain", "a"); n != 3 || store.Type("plain") != TypeSet {
+		t.Errorf("Expected the destination to be replaced by a set, got %d", n)
+	}
+	if n, _ := store.SDiffStore("dest", "a", "a"); n != 0 || store.Type("dest") != TypeNone {
+		t.Error("Expected an empty result to remove the destination")
+	}
+}
@@ -0,0 +1,463 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// XAddOptions controls XAdd. ID is an explicit entry ID; empty or "*" generates one.
+// A positive MaxLen trims the stream to that many entries after adding.
+type XAddOptions struct {
+	ID     string
+	MaxLen int
+}
+
+// XReadOptions controls XRead and XReadGroup. A positive Count limits the entries returned
+// per stream; Block waits for new entries until the context is done when none are
+// available. NoAck makes XReadGroup deliver entries without recording them as pending.
+type XReadOptions struct {
+	Count int
+	Block bool
+	NoAck bool
+}
+
+// streamAt returns the live stream stored at key and records the access, or nil when the
+// key does not exist. Caller must hold the shard lock.
+func (s *Store) streamAt(key string, now int64) (*Stream, error) {
+	entry, found := s.lookup(key, now)
+	if !found {
+		return nil, nil
+	}
+	stream, ok := entry.Value.(*Stream)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	s.touch(key, entry)
+	return stream, nil
+}
+
+// parseRangeBound parses an XRANGE bound: "-" and "+" are the smallest and greatest IDs, a
+// "(" prefix excludes the ID and an end without a sequence number covers the whole millisecond.
+func parseRangeBound(bound string, end bool) (StreamID, error) {
+	switch bound {
+	case "-":
+		return StreamID{}, nil
+	case "+":
+		return MaxStreamID, nil
+	}
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+	id, err := ParseStreamID(bound)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if end && !strings.Contains(bound, "-") {
+		id.Seq = MaxStreamID.Seq
+	}
+	if !exclusive {
+		return id, nil
+	}
+	switch {
+	case !end && id == MaxStreamID, end && id == StreamID{}:
+		return StreamID{}, ErrInvalidStreamID
+	case !end:
+		return id.next(), nil
+	case id.Seq == 0:
+		return StreamID{Ms: id.Ms - 1, Seq: MaxStreamID.Seq}, nil
+	default:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, nil
+	}
+}
+
+// XAdd appends an entry with fields to the stream at key, creating it if needed, and
+// returns its ID. Generated IDs are the current time in milliseconds with a sequence
+// number, and always greater than the previous ID even if the clock goes backwards.
+func (ss *ShardedStore) XAdd(key string, fields map[string]interface{}, opts XAddOptions) (StreamID, error) {
+	if len(fields) == 0 || opts.MaxLen < 0 {
+		return StreamID{}, ErrInvalidOptions
+	}
+	var explicit *StreamID
+	if opts.ID != "" && opts.ID != "*" {
+		id, err := ParseStreamID(opts.ID)
+		if err != nil {
+			return StreamID{}, err
+		}
+		if id == (StreamID{}) {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		explicit = &id
+	}
+	if err := ss.ensureCapacity(key); err != nil {
+		return StreamID{}, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	id, err := shard.xadd(key, explicit, fields, opts.MaxLen, time.Now())
+	shard.mutex.Unlock()
+	if err != nil {
+		return StreamID{}, err
+	}
+
+	ss.wakeStreamReaders(key)
+	return id, nil
+}
+
+// xadd appends an entry and trims the stream. Caller must hold the write lock.
+func (s *Store) xadd(key string, id *StreamID, fields map[string]interface{}, maxLen int, now time.Time) (StreamID, error) {
+	stream, err := s.streamAt(key, now.UnixMilli())
+	if err != nil {
+		return StreamID{}, err
+	}
+	if stream == nil {
+		stream = NewStream()
+		s.put(key, Entry{Value: stream})
+	}
+	added, err := stream.Add(id, fields, now)
+	if err != nil {
+		return StreamID{}, err
+	}
+	delta := streamEntrySize(fields)
+	s.events.emit(key, EventXAdd)
+	s.aof.append(aofRecord{Op: "xadd", Key: key, ID: added.String(), Fields: fields, Count: maxLen})
+
+	var trimmed []StreamEntry
+	if maxLen > 0 {
+		trimmed = stream.Trim(maxLen)
+		for _, entry := range trimmed {
+			delta -= streamEntrySize(entry.Fields)
+		}
+	}
+	s.adjust(key, delta)
+	if len(trimmed) > 0 {
+		s.events.emit(key, EventXTrim)
+	}
+	return added, nil
+}
+
+// XLen returns the number of entries in the stream at key.
+func (ss *ShardedStore) XLen(key string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if stream == nil || err != nil {
+		return 0, err
+	}
+	return stream.Len(), nil
+}
+
+// XRange returns up to count entries between the start and end bounds in ID order, see
+// parseRangeBound. A count that is not positive returns every entry in the range.
+func (ss *ShardedStore) XRange(key, start, end string, count int) ([]StreamEntry, error) {
+	from, err := parseRangeBound(start, false)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseRangeBound(end, true)
+	if err != nil {
+		return nil, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if stream == nil || err != nil {
+		return []StreamEntry{}, err
+	}
+	return stream.Range(from, to, count), nil
+}
+
+// XTrim removes the oldest entries of the stream at key so that at most maxLen remain,
+// and returns how many were removed. The stream is kept even when trimmed to nothing.
+func (ss *ShardedStore) XTrim(key string, maxLen int) (int, error) {
+	if maxLen < 0 {
+		return 0, ErrInvalidOptions
+	}
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if stream == nil || err != nil {
+		return 0, err
+	}
+	trimmed := stream.Trim(maxLen)
+	if len(trimmed) == 0 {
+		return 0, nil
+	}
+	var delta int64
+	for _, entry := range trimmed {
+		delta -= streamEntrySize(entry.Fields)
+	}
+	shard.adjust(key, delta)
+	shard.events.emit(key, EventXTrim)
+	shard.aof.append(aofRecord{Op: "xtrim", Key: key, Count: maxLen})
+	return len(trimmed), nil
+}
+
+// XRead returns the entries added after ids[i] to the stream at keys[i], keyed by stream
+// and leaving out streams with nothing new. The ID "$" stands for the last ID of the
+// stream when the call is made. With opts.Block it waits until an entry is added to one of
+// the streams or ctx is done, in which case it returns the context's error.
+func (ss *ShardedStore) XRead(ctx context.Context, keys, ids []string, opts XReadOptions) (map[string][]StreamEntry, error) {
+	if len(keys) == 0 || len(keys) != len(ids) {
+		return nil, ErrInvalidOptions
+	}
+	after := make([]StreamID, len(keys))
+	for i, id := range ids {
+		if id == "$" {
+			last, err := ss.xlastID(keys[i])
+			if err != nil {
+				return nil, err
+			}
+			after[i] = last
+			continue
+		}
+		parsed, err := ParseStreamID(id)
+		if err != nil {
+			return nil, err
+		}
+		after[i] = parsed
+	}
+
+	result, err := ss.blockingRead(ctx, keys, opts.Block, func() (map[string][]StreamEntry, error) {
+		return ss.xread(keys, nil, func(_ *Store, stream *Stream, i int) ([]StreamEntry, error) {
+			if after[i] == MaxStreamID {
+				return nil, nil
+			}
+			return stream.Range(after[i].next(), MaxStreamID, opts.Count), nil
+		})
+	})
+	if result == nil && err == nil {
+		result = map[string][]StreamEntry{}
+	}
+	return result, err
+}
+
+func (ss *ShardedStore) xlastID(key string) (StreamID, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if stream == nil || err != nil {
+		return StreamID{}, err
+	}
+	return stream.LastID(), nil
+}
+
+// xread runs read on the stream at each of keys and collects the non-empty results, or
+// returns nil when every result is empty. A missing key fails with missing, or is skipped
+// when missing is nil.
+func (ss *ShardedStore) xread(keys []string, missing error, read func(shard *Store, stream *Stream, i int) ([]StreamEntry, error)) (map[string][]StreamEntry, error) {
+	var result map[string][]StreamEntry
+	for i, key := range keys {
+		shard := ss.getShard(key)
+		shard.mutex.Lock()
+		stream, err := shard.streamAt(key, time.Now().UnixMilli())
+		var entries []StreamEntry
+		switch {
+		case err != nil:
+		case stream == nil:
+			err = missing
+		default:
+			entries, err = read(shard, stream, i)
+		}
+		shard.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			if result == nil {
+				result = make(map[string][]StreamEntry)
+			}
+			result[key] = entries
+		}
+	}
+	return result, nil
+}
+
+// XGroupCreate creates a consumer group on the stream at key that delivers the entries
+// after start; "$" delivers only entries added from now on. With mkStream a missing
+// stream is created empty, otherwise it returns ErrNoGroup.
+func (ss *ShardedStore) XGroupCreate(key, group, start string, mkStream bool) error {
+	var from StreamID
+	if start != "$" {
+		id, err := ParseStreamID(start)
+		if err != nil {
+			return err
+		}
+		from = id
+	}
+	if mkStream {
+		if err := ss.ensureCapacity(key); err != nil {
+			return err
+		}
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		if !mkStream {
+			return ErrNoGroup
+		}
+		stream = NewStream()
+		shard.put(key, Entry{Value: stream})
+	}
+	if start == "$" {
+		from = stream.LastID()
+	}
+	if err := stream.CreateGroup(group, from); err != nil {
+		return err
+	}
+	shard.adjust(key, 0)
+	shard.events.emit(key, EventXGroupCreate)
+	shard.aof.append(aofRecord{Op: "xgroup", Key: key, Group: group, ID: from.String()})
+	return nil
+}
+
+// XReadGroup reads the streams at keys on behalf of consumer in group. The ID ">" delivers
+// entries never delivered to the group and records them as pending for consumer until
+// they are acknowledged; any other ID returns consumer's pending entries after it, with
+// nil fields for entries trimmed since. Only ">" reads block, as with XRead.
+func (ss *ShardedStore) XReadGroup(ctx context.Context, group, consumer string, keys, ids []string, opts XReadOptions) (map[string][]StreamEntry, error) {
+	if len(keys) == 0 || len(keys) != len(ids) || consumer == "" {
+		return nil, ErrInvalidOptions
+	}
+	after := make([]*StreamID, len(keys))
+	block := opts.Block
+	for i, id := range ids {
+		if id == ">" {
+			continue
+		}
+		parsed, err := ParseStreamID(id)
+		if err != nil {
+			return nil, err
+		}
+		after[i] = &parsed
+		block = false
+	}
+
+	result, err := ss.blockingRead(ctx, keys, block, func() (map[string][]StreamEntry, error) {
+		return ss.xread(keys, ErrNoGroup, func(shard *Store, stream *Stream, i int) ([]StreamEntry, error) {
+			if after[i] != nil {
+				return stream.PendingFor(group, consumer, *after[i], opts.Count)
+			}
+			// Delivery times are kept to the millisecond so replaying the log restores them exactly.
+			now := time.Now().UnixMilli()
+			entries, err := stream.ReadGroup(group, consumer, opts.Count, opts.NoAck, time.UnixMilli(now))
+			if len(entries) > 0 {
+				shard.adjust(keys[i], 0)
+				shard.aof.append(aofRecord{Time: now, Op: "xreadgroup", Key: keys[i], Group: group, Consumer: consumer,
+					Count: len(entries), NoAck: opts.NoAck})
+			}
+			return entries, err
+		})
+	})
+	if result == nil && err == nil {
+		result = map[string][]StreamEntry{}
+	}
+	return result, err
+}
+
+// XAck acknowledges entries delivered to group, removing them from its pending entries,
+// and returns how many were pending.
+func (ss *ShardedStore) XAck(key, group string, ids ...string) (int, error) {
+	parsed, err := parseStreamIDs(ids)
+	if err != nil {
+		return 0, err
+	}
+	acked := 0
+	err = ss.withGroup(key, func(shard *Store, stream *Stream) error {
+		acked, err = stream.Ack(group, parsed...)
+		if acked > 0 {
+			shard.adjust(key, 0)
+			shard.aof.append(aofRecord{Op: "xack", Key: key, Group: group, IDs: ids})
+		}
+		return err
+	})
+	return acked, err
+}
+
+// XPending returns up to count entries pending in group with IDs between the start and
+// end bounds, see XRange, limited to consumer unless it is empty.
+func (ss *ShardedStore) XPending(key, group, start, end string, count int, consumer string) ([]PendingEntry, error) {
+	from, err := parseRangeBound(start, false)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseRangeBound(end, true)
+	if err != nil {
+		return nil, err
+	}
+	var pending []PendingEntry
+	err = ss.withGroup(key, func(_ *Store, stream *Stream) error {
+		pending, err = stream.Pending(group, from, to, count, consumer)
+		return err
+	})
+	return pending, err
+}
+
+// XClaim gives consumer the entries among ids that are pending in group and have not been
+// delivered for at least minIdle, typically because their consumer died, and returns them.
+func (ss *ShardedStore) XClaim(key, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry, error) {
+	if consumer == "" {
+		return nil, ErrInvalidOptions
+	}
+	parsed, err := parseStreamIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var claimed []StreamEntry
+	err = ss.withGroup(key, func(shard *Store, stream *Stream) error {
+		now := time.Now().UnixMilli()
+		claimed, err = stream.Claim(group, consumer, minIdle, parsed, time.UnixMilli(now))
+		if err == nil {
+			shard.adjust(key, 0)
+			shard.aof.append(aofRecord{Time: now, Op: "xclaim", Key: key, Group: group, Consumer: consumer,
+				MinIdle: minIdle, IDs: ids})
+		}
+		return err
+	})
+	return claimed, err
+}
+
+// withGroup calls fn with the shard and the stream at key under the shard lock, or
+// returns ErrNoGroup when the key does not exist.
+func (ss *ShardedStore) withGroup(key string, fn func(*Store, *Stream) error) error {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	stream, err := shard.streamAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrNoGroup
+	}
+	return fn(shard, stream)
+}
+
+func parseStreamIDs(ids []string) ([]StreamID, error) {
+	parsed := make([]StreamID, len(ids))
+	for i, id := range ids {
+		p, err := ParseStreamID(id)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = p
+	}
+	return parsed, nil
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestXAddAndXRange(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	for _, id := range []string{"1-1", "1-2", "2-0", "3-5"} {
+		if _, err := store.XAdd("log", map[string]interface{}{"id": id}, XAddOptions{ID: id}); err != nil {
+			t.Fatalf("XAdd %s failed: %v", id, err)
+		}
+	}
+	if _, err := store.XAdd("log", map[string]interface{}{"x": 1}, XAddOptions{ID: "3-5"}); !errors.Is(err, ErrStreamIDTooSmall) {
+		t.Errorf("Expected ErrStreamIDTooSmall, got %v", err)
+	}
+	if _, err := store.XAdd("log", nil, XAddOptions{}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected an entry without fields to be rejected, got %v", err)
+	}
+	if store.Type("log") != TypeStream {
+		t.Errorf("Expected a stream, got %s", store.Type("log"))
+	}
+
+	cases := []struct {
+		start, end string
+		want       []string
+	}{
+		{"-", "+", []string{"1-1", "1-2", "2-0", "3-5"}},
+		{"1", "1", []string{"1-1", "1-2"}}, // an end without a sequence covers the millisecond
+		{"(1-1", "(3-5", []string{"1-2", "2-0"}},
+		{"2", "+", []string{"2-0", "3-5"}},
+	}
+	for _, c := range cases {
+		entries, err := store.XRange("log", c.start, c.end, 0)
+		if got := ids(entries); err != nil || len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) {
+			t.Errorf("XRange %s %s: expected %v, got %v (%v)", c.start, c.end, c.want, got, err)
+		}
+	}
+
+	store.Set("plain", "value", 0)
+	if _, err := store.XAdd("plain", map[string]interface{}{"x": 1}, XAddOptions{}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestXAddMaxLenAndXTrim(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	for i := 0; i < 10; i++ {
+		store.XAdd("log", map[string]interface{}{"n": i}, XAddOptions{MaxLen: 5})
+	}
+	if n, _ := store.XLen("log"); n != 5 {
+		t.Errorf("Expected MAXLEN to cap the stream at 5, got %d", n)
+	}
+	if removed, _ := store.XTrim("log", 2); removed != 3 {
+		t.Errorf("Expected 3 entries trimmed, got %d", removed)
+	}
+	entries, _ := store.XRange("log", "-", "+", 0)
+	if len(entries) != 2 || entries[1].Fields["n"] != 9 {
+		t.Errorf("Expected the newest entries to be kept, got %v", entries)
+	}
+	store.XTrim("log", 0)
+	if store.Type("log") != TypeStream {
+		t.Error("Expected an empty stream to be kept")
+	}
+}
+
+func TestXReadBlocksUntilXAdd(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.XAdd("log", map[string]interface{}{"n": 1}, XAddOptions{})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.XAdd("log", map[string]interface{}{"n": 2}, XAddOptions{})
+	}()
+
+	result, err := store.XRead(context.Background(), []string{"other", "log"}, []string{"0", "$"}, XReadOptions{Block: true})
+	if err != nil || len(result) != 1 || len(result["log"]) != 1 || result["log"][0].Fields["n"] != 2 {
+		t.Errorf("Expected only the entry added after the call, got %v (%v)", result, err)
+	}
+	if len(store.streamReaders.waiters) != 0 {
+		t.Error("Expected the reader to be unregistered")
+	}
+}
+
+func TestXReadTimeout(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if result, err := store.XRead(context.Background(), []string{"log"}, []string{"0"}, XReadOptions{}); err != nil || len(result) != 0 {
+		t.Errorf("Expected an empty non-blocking read, got %v (%v)", result, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := store.XRead(ctx, []string{"log"}, []string{"$"}, XReadOptions{Block: true}); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if len(store.streamReaders.waiters) != 0 {
+		t.Error("Expected the timed out reader to be unregistered")
+	}
+}
+
+func TestXReadGroupLifecycle(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+	ctx := context.Background()
+
+	if err := store.XGroupCreate("jobs", "workers", "$", false); !errors.Is(err, ErrNoGroup) {
+		t.Errorf("Expected ErrNoGroup without MKSTREAM, got %v", err)
+	}
+	if err := store.XGroupCreate("jobs", "workers", "$", true); err != nil {
+		t.Fatalf("XGroupCreate failed: %v", err)
+	}
+	store.XAdd("jobs", map[string]interface{}{"task": "a"}, XAddOptions{})
+	store.XAdd("jobs", map[string]interface{}{"task": "b"}, XAddOptions{})
+
+	first, _ := store.XReadGroup(ctx, "workers", "alice", []string{"jobs"}, []string{">"}, XReadOptions{Count: 1})
+	second, _ := store.XReadGroup(ctx, "workers", "bob", []string{"jobs"}, []string{">"}, XReadOptions{})
+	if len(first["jobs"]) != 1 || first["jobs"][0].Fields["task"] != "a" || len(second["jobs"]) != 1 || second["jobs"][0].Fields["task"] != "b" {
+		t.Fatalf("Expected alice to get a and bob to get b, got %v and %v", first, second)
+	}
+
+	// Reading with an ID replays the consumer's own pending entries.
+	history, _ := store.XReadGroup(ctx, "workers", "alice", []string{"jobs"}, []string{"0"}, XReadOptions{})
+	if len(history["jobs"]) != 1 || history["jobs"][0].ID != first["jobs"][0].ID {
+		t.Errorf("Expected alice's pending entry, got %v", history)
+	}
+
+	if acked, _ := store.XAck("jobs", "workers", first["jobs"][0].ID.String()); acked != 1 {
+		t.Errorf("Expected 1 acknowledgement, got %d", acked)
+	}
+	pending, _ := store.XPending("jobs", "workers", "-", "+", 0, "")
+	if len(pending) != 1 || pending[0].Consumer != "bob" {
+		t.Fatalf("Expected only bob's entry pending, got %+v", pending)
+	}
+
+	claimed, err := store.XClaim("jobs", "workers", "carol", 0, pending[0].ID.String())
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Expected carol to claim bob's entry, got %v (%v)", claimed, err)
+	}
+	if pending, _ := store.XPending("jobs", "workers", "-", "+", 0, "carol"); len(pending) != 1 || pending[0].Deliveries != 2 {
+		t.Errorf("Expected carol to own the entry after a second delivery, got %+v", pending)
+	}
+
+	if _, err := store.XReadGroup(ctx, "nobody", "alice", []string{"jobs"}, []string{">"}, XReadOptions{}); !errors.Is(err, ErrNoGroup) {
+		t.Errorf("Expected ErrNoGroup, got %v", err)
+	}
+}
+
+func TestXReadGroupBlocks(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.XGroupCreate("jobs", "workers", "$", true)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.XAdd("jobs", map[string]interface{}{"task": "late"}, XAddOptions{})
+	}()
+
+	result, err := store.XReadGroup(context.Background(), "workers", "alice", []string{"jobs"}, []string{">"}, XReadOptions{Block: true})
+	if err != nil || len(result["jobs"]) != 1 || result["jobs"][0].Fields["task"] != "late" {
+		t.Errorf("Expected the late entry, got %v (%v)", result, err)
+	}
+}
+
+func TestStreamSurvivesSnapshot(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.XAdd("jobs", map[string]interface{}{"task": "a"}, XAddOptions{ID: "5-0"})
+	store.XGroupCreate("jobs", "workers", "0", false)
+	store.XReadGroup(context.Background(), "workers", "alice", []string{"jobs"}, []string{">"}, XReadOptions{})
+	filename := filepath.Join(t.TempDir(), "data.json")
+	store.SaveStoreToFile(filename)
+
+	restored := NewShardedStore()
+	defer restored.Close()
+	if err := restored.LoadStoreFromFile(filename); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	entries, err := restored.XRange("jobs", "-", "+", 0)
+	if err != nil || len(entries) != 1 || entries[0].ID != (StreamID{5, 0}) || entries[0].Fields["task"] != "a" {
+		t.Errorf("Expected the entry to be restored, got %v (%v)", entries, err)
+	}
+	pending, err := restored.XPending("jobs", "workers", "-", "+", 0, "")
+	if err != nil || len(pending) != 1 || pending[0].Consumer != "alice" {
+		t.Errorf("Expected the pending entry to be restored, got %+v (%v)", pending, err)
+	}
+	if _, err := restored.XAdd("jobs", map[string]interface{}{"task": "b"}, XAddOptions{ID: "4-0"}); !errors.Is(err, ErrStreamIDTooSmall) {
+		t.Errorf("Expected the last ID to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"time"
+)
+
+// NoExpiration is returned by TTL for a key that exists but does not expire
+const NoExpiration time.Duration = -1
+
+// legacyExpirationLimit separates snapshot expirations written in Unix seconds from
+// millisecond ones: as milliseconds it is early 1973, as seconds it is far in the future.
+const legacyExpirationLimit = 100_000_000_000
+
+// normalizeExpiration converts an expiration read from an older snapshot, which stored
+// Unix seconds, to Unix milliseconds.
+func normalizeExpiration(expiration int64) int64 {
+	if expiration > 0 && expiration < legacyExpirationLimit {
+		return expiration * 1000
+	}
+	return expiration
+}
+
+// TTL returns the remaining time to live of key with millisecond precision,
+// or NoExpiration when the key does not expire. found is false when the key does not exist.
+func (ss *ShardedStore) TTL(key string) (ttl time.Duration, found bool) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	now := time.Now().UnixMilli()
+	entry, found := shard.lookup(key, now)
+	if !found {
+		return 0, false
+	}
+	if entry.Expiration == 0 {
+		return NoExpiration, true
+	}
+	return time.Duration(entry.Expiration-now) * time.Millisecond, true
+}
+
+// Expire sets the time to live of key, truncated to milliseconds. A ttl that is not
+// positive deletes the key. It reports whether the key existed.
+func (ss *ShardedStore) Expire(key string, ttl time.Duration) bool {
+	return ss.ExpireAt(key, time.Now().Add(ttl))
+}
+
+// ExpireAt makes key expire at the given time, truncated to milliseconds. A time in the
+// past deletes the key. It reports whether the key existed.
+func (ss *ShardedStore) ExpireAt(key string, at time.Time) bool {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	now := time.Now().UnixMilli()
+	entry, found := shard.lookup(key, now)
+	if !found {
+		return false
+	}
+	expiration := at.UnixMilli()
+	if expiration <= now {
+		shard.remove(key)
+		shard.events.emit(key, EventDel)
+		shard.aof.appendDel(key)
+		return true
+	}
+	shard.setExpiration(key, entry, expiration)
+	shard.events.emit(key, EventExpire)
+	shard.aof.append(aofRecord{Op: "pexpireat", Key: key, At: expiration})
+	return true
+}
+
+// Persist removes the time to live of key. It reports whether a TTL was removed.
+func (ss *ShardedStore) Persist(key string) bool {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	entry, found := shard.lookup(key, time.Now().UnixMilli())
+	if !found || entry.Expiration == 0 {
+		return false
+	}
+	shard.setExpiration(key, entry, 0)
+	shard.events.emit(key, EventPersist)
+	shard.aof.append(aofRecord{Op: "pexpireat", Key: key})
+	return true
+}
+
+// setExpiration replaces the expiration of a live entry, bumps its version and counts the
+// change towards the save points, keeping the value and its memory charge untouched.
+// Caller must hold the write lock.
+func (s *Store) setExpiration(key string, entry Entry, expiration int64) {
+	entry.Expiration = expiration
+	entry.Version = s.versions.Add(1)
+	s.changes.Add(1)
+	s.data[key] = entry
+	if expiration > 0 {
+		s.expires[key] = expiration
+	} else {
+		delete(s.expires, key)
+	}
+}
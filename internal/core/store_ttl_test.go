@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if _, found := store.TTL("missing"); found {
+		t.Error("Expected no TTL for a missing key")
+	}
+
+	store.Set("plain", "value", 0)
+	if ttl, found := store.TTL("plain"); !found || ttl != NoExpiration {
+		t.Errorf("Expected NoExpiration, got %v, %v", ttl, found)
+	}
+
+	store.Set("temp", "value", 10)
+	ttl, found := store.TTL("temp")
+	if !found || ttl <= 9*time.Second || ttl > 10*time.Second {
+		t.Errorf("Expected a TTL close to 10s, got %v", ttl)
+	}
+}
+
+func TestExpireMilliseconds(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	if store.Expire("missing", time.Second) {
+		t.Error("Expected Expire on a missing key to report false")
+	}
+
+	store.Set("key", "value", 0)
+	version := store.Version("key")
+	if !store.Expire("key", 50*time.Millisecond) {
+		t.Fatal("Expected Expire to report true")
+	}
+	if store.Version("key") == version {
+		t.Error("Expected Expire to bump the version")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, found := store.Get("key"); found {
+		t.Error("Expected 'key' to expire after 50ms")
+	}
+}
+
+func TestExpireInThePastDeletes(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("a", "value", 0)
+	store.Set("b", "value", 0)
+	if !store.Expire("a", 0) || !store.ExpireAt("b", time.Now().Add(-time.Minute)) {
+		t.Fatal("Expected both keys to exist")
+	}
+	if store.Type("a") != TypeNone || store.Type("b") != TypeNone {
+		t.Error("Expected keys with a past expiration to be deleted")
+	}
+}
+
+func TestExpireAtAndPersist(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.RPush("list", "a")
+	if !store.ExpireAt("list", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected ExpireAt to report true")
+	}
+	if ttl, _ := store.TTL("list"); ttl <= 59*time.Minute {
+		t.Errorf("Expected a TTL close to 1h, got %v", ttl)
+	}
+
+	if !store.Persist("list") {
+		t.Error("Expected Persist to remove the TTL")
+	}
+	if store.Persist("list") {
+		t.Error("Expected a second Persist to report false")
+	}
+	if ttl, _ := store.TTL("list"); ttl != NoExpiration {
+		t.Errorf("Expected NoExpiration after Persist, got %v", ttl)
+	}
+	shard := store.getShard("list")
+	shard.mutex.RLock()
+	_, indexed := shard.expires["list"]
+	shard.mutex.RUnlock()
+	if indexed {
+		t.Error("Expected Persist to clear the expiration index")
+	}
+}
+
+func TestNormalizeExpiration(t *testing.T) {
+	if got := normalizeExpiration(1700000000); got != 1700000000000 {
+		t.Errorf("Expected seconds to be converted, got %d", got)
+	}
+	if got := normalizeExpiration(1700000000000); got != 1700000000000 {
+		t.Errorf("Expected milliseconds to be kept, got %d", got)
+	}
+	if got := normalizeExpiration(0); got != 0 {
+		t.Errorf("Expected 0 to be kept, got %d", got)
+	}
+}
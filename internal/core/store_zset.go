@@ -0,0 +1,261 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrInvalidZAddOptions is returned by ZAdd when incompatible conditions are combined
+var ErrInvalidZAddOptions = errors.New("NX is incompatible with XX, GT and LT, and GT with LT")
+
+// ZAddOptions makes ZAdd conditional. NX only adds new members, XX only updates existing
+// ones, GT and LT only update a member when the new score is greater or lower.
+type ZAddOptions struct {
+	NX, XX, GT, LT bool
+}
+
+// zsetAt returns the live sorted set stored at key and records the access, or nil when
+// the key does not exist. Caller must hold the shard lock.
+func (s *Store) zsetAt(key string, now int64) (*ZSet, error) {
+	entry, found := s.lookup(key, now)
+	if !found {
+		return nil, nil
+	}
+	zset, ok := entry.Value.(*ZSet)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	s.touch(key, entry)
+	return zset, nil
+}
+
+// ZAdd adds members to the sorted set at key, creating it if needed, or updates their
+// scores, subject to opts. It returns the number of members added. NaN and infinite
+// scores return ErrNotFloat.
+func (ss *ShardedStore) ZAdd(key string, members []ZMember, opts ZAddOptions) (int, error) {
+	if (opts.NX && (opts.XX || opts.GT || opts.LT)) || (opts.GT && opts.LT) {
+		return 0, ErrInvalidZAddOptions
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) || math.IsInf(m.Score, 0) {
+			return 0, ErrNotFloat
+		}
+	}
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if err != nil || len(members) == 0 || (zset == nil && opts.XX) {
+		return 0, err
+	}
+	if zset == nil {
+		zset = NewZSet()
+		shard.put(key, Entry{Value: zset})
+	}
+
+	added := 0
+	var delta int64
+	var changed []ZMember
+	for _, m := range members {
+		old, found := zset.Score(m.Member)
+		switch {
+		case found && (opts.NX || (opts.GT && m.Score <= old) || (opts.LT && m.Score >= old)):
+			continue
+		case !found && opts.XX:
+			continue
+		}
+		if zset.Set(m.Member, m.Score) {
+			added++
+			delta += zmemberSize(m.Member)
+		}
+		changed = append(changed, m)
+	}
+	if len(changed) > 0 {
+		shard.adjust(key, delta)
+		shard.events.emit(key, EventZAdd)
+		shard.aof.append(aofRecord{Op: "zadd", Key: key, Scores: changed})
+	}
+	shard.removeIfEmpty(key, zset)
+	return added, nil
+}
+
+// ZIncrBy adds delta to the score of member, adding it with score delta when missing,
+// and returns the new score. A score that would overflow to infinity returns ErrNotFloat
+// and leaves the member unchanged.
+func (ss *ShardedStore) ZIncrBy(key, member string, delta float64) (float64, error) {
+	if err := ss.ensureCapacity(key); err != nil {
+		return 0, err
+	}
+
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	var score float64
+	if zset != nil {
+		score, _ = zset.Score(member)
+	}
+	score += delta
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, ErrNotFloat
+	}
+
+	if zset == nil {
+		zset = NewZSet()
+		shard.put(key, Entry{Value: zset})
+	}
+	var size int64
+	if zset.Set(member, score) {
+		size = zmemberSize(member)
+	}
+	shard.adjust(key, size)
+	shard.events.emit(key, EventZIncrBy)
+	shard.aof.append(aofRecord{Op: "zadd", Key: key, Scores: []ZMember{{member, score}}})
+	return score, nil
+}
+
+// ZScore returns the score of member in the sorted set at key.
+func (ss *ShardedStore) ZScore(key, member string) (float64, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return 0, false, err
+	}
+	score, found := zset.Score(member)
+	return score, found, nil
+}
+
+// ZRank returns the 0-based rank of member by ascending score, or descending when rev is set.
+func (ss *ShardedStore) ZRank(key, member string, rev bool) (int, bool, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return 0, false, err
+	}
+	rank, found := zset.Rank(member, rev)
+	return rank, found, nil
+}
+
+// ZCard returns the number of members in the sorted set, or 0 when it does not exist.
+func (ss *ShardedStore) ZCard(key string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZRange returns the members between ranks start and stop inclusive, see ZSet.Range.
+func (ss *ShardedStore) ZRange(key string, start, stop int, rev bool) ([]ZMember, error) {
+	return ss.zrange(key, func(z *ZSet) []ZMember { return z.Range(start, stop, rev) })
+}
+
+// ZRangeByScore returns the members within r in ascending order, see ZSet.RangeByScore.
+func (ss *ShardedStore) ZRangeByScore(key string, r ScoreRange, offset, count int) ([]ZMember, error) {
+	return ss.zrange(key, func(z *ZSet) []ZMember { return z.RangeByScore(r, offset, count) })
+}
+
+// ZRangeByLex returns the members within r in lexical order, see ZSet.RangeByLex.
+func (ss *ShardedStore) ZRangeByLex(key string, r LexRange, offset, count int) ([]ZMember, error) {
+	return ss.zrange(key, func(z *ZSet) []ZMember { return z.RangeByLex(r, offset, count) })
+}
+
+func (ss *ShardedStore) zrange(key string, query func(*ZSet) []ZMember) ([]ZMember, error) {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return []ZMember{}, err
+	}
+	return query(zset), nil
+}
+
+// ZRem removes members and returns how many existed. Removing the last member removes the key.
+func (ss *ShardedStore) ZRem(key string, members ...string) (int, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return 0, err
+	}
+	var removed []string
+	var delta int64
+	for _, member := range members {
+		if zset.Rem(member) {
+			removed = append(removed, member)
+			delta -= zmemberSize(member)
+		}
+	}
+	if len(removed) > 0 {
+		shard.adjust(key, delta)
+		shard.events.emit(key, EventZRem)
+		shard.aof.append(aofRecord{Op: "zrem", Key: key, Names: removed})
+	}
+	shard.removeIfEmpty(key, zset)
+	return len(removed), nil
+}
+
+// ZRemRangeByScore removes the members within r and returns how many were removed.
+func (ss *ShardedStore) ZRemRangeByScore(key string, r ScoreRange) (int, error) {
+	removed, err := ss.zremove(key, EventZRemRange, func(z *ZSet) []ZMember { return z.RemRangeByScore(r) })
+	return len(removed), err
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores.
+func (ss *ShardedStore) ZPopMin(key string, count int) ([]ZMember, error) {
+	return ss.zremove(key, EventZPopMin, func(z *ZSet) []ZMember { return z.PopMin(count) })
+}
+
+// ZPopMax removes and returns up to count members with the highest scores, highest first.
+func (ss *ShardedStore) ZPopMax(key string, count int) ([]ZMember, error) {
+	return ss.zremove(key, EventZPopMax, func(z *ZSet) []ZMember { return z.PopMax(count) })
+}
+
+func (ss *ShardedStore) zremove(key string, event EventType, remove func(*ZSet) []ZMember) ([]ZMember, error) {
+	shard := ss.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	zset, err := shard.zsetAt(key, time.Now().UnixMilli())
+	if zset == nil || err != nil {
+		return []ZMember{}, err
+	}
+	removed := remove(zset)
+	if len(removed) > 0 {
+		var delta int64
+		names := make([]string, len(removed))
+		for i, m := range removed {
+			delta -= zmemberSize(m.Member)
+			names[i] = m.Member
+		}
+		shard.adjust(key, delta)
+		shard.events.emit(key, event)
+		shard.aof.append(aofRecord{Op: "zrem", Key: key, Names: names})
+	}
+	shard.removeIfEmpty(key, zset)
+	return removed, nil
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestZAddOptions(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	added, err := store.ZAdd("board", []ZMember{{"alice", 10}, {"bob", 20}}, ZAddOptions{})
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 new members, got %d (%v)", added, err)
+	}
+
+	store.ZAdd("board", []ZMember{{"alice", 99}, {"carol", 5}}, ZAddOptions{NX: true})
+	if score, _, _ := store.ZScore("board", "alice"); score != 10 {
+		t.Errorf("Expected NX to keep alice at 10, got %v", score)
+	}
+	store.ZAdd("board", []ZMember{{"bob", 30}, {"dave", 1}}, ZAddOptions{XX: true})
+	if _, found, _ := store.ZScore("board", "dave"); found {
+		t.Error("Expected XX not to add dave")
+	}
+	store.ZAdd("board", []ZMember{{"bob", 25}, {"alice", 15}}, ZAddOptions{GT: true})
+	if score, _, _ := store.ZScore("board", "bob"); score != 30 {
+		t.Errorf("Expected GT to keep bob at 30, got %v", score)
+	}
+	if score, _, _ := store.ZScore("board", "alice"); score != 15 {
+		t.Errorf("Expected GT to raise alice to 15, got %v", score)
+	}
+	store.ZAdd("board", []ZMember{{"carol", 1}}, ZAddOptions{LT: true})
+	if score, _, _ := store.ZScore("board", "carol"); score != 1 {
+		t.Errorf("Expected LT to lower carol to 1, got %v", score)
+	}
+
+	if _, err := store.ZAdd("board", []ZMember{{"x", 1}}, ZAddOptions{NX: true, GT: true}); !errors.Is(err, ErrInvalidZAddOptions) {
+		t.Errorf("Expected ErrInvalidZAddOptions, got %v", err)
+	}
+	if _, err := store.ZAdd("other", []ZMember{{"x", 1}}, ZAddOptions{XX: true}); err != nil || store.Type("other") != TypeNone {
+		t.Error("Expected XX not to create the key")
+	}
+}
+
+func TestZIncrByAndRank(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.ZIncrBy("board", "alice", 5)
+	store.ZIncrBy("board", "bob", 3)
+	if score, _ := store.ZIncrBy("board", "bob", 4); score != 7 {
+		t.Errorf("Expected 7, got %v", score)
+	}
+	if rank, found, _ := store.ZRank("board", "bob", true); !found || rank != 0 {
+		t.Errorf("Expected bob at the top, got %d", rank)
+	}
+	top, _ := store.ZRange("board", 0, 0, true)
+	if len(top) != 1 || top[0] != (ZMember{"bob", 7}) {
+		t.Errorf("Unexpected top member %v", top)
+	}
+}
+
+func TestZSetRejectsInfiniteScores(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.ZIncrBy("board", "alice", 1e308)
+	if _, err := store.ZIncrBy("board", "alice", 1e308); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat on overflow, got %v", err)
+	}
+	if score, _, _ := store.ZScore("board", "alice"); score != 1e308 {
+		t.Errorf("Expected the score to be unchanged, got %v", score)
+	}
+	if _, err := store.ZAdd("board", []ZMember{{"bob", math.Inf(-1)}}, ZAddOptions{}); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat for an infinite score, got %v", err)
+	}
+	if err := store.SaveStoreToFile(t.TempDir() + "/data.json"); err != nil {
+		t.Errorf("Expected the store to stay saveable, got %v", err)
+	}
+}
+
+func TestZRangeByScoreAndRemove(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.ZAdd("events", []ZMember{{"a", 100}, {"b", 200}, {"c", 300}, {"d", 400}}, ZAddOptions{})
+	got, _ := store.ZRangeByScore("events", ScoreRange{Min: 150, Max: math.Inf(1)}, 0, 2)
+	if !reflect.DeepEqual(members(got), []string{"b", "c"}) {
+		t.Errorf("Unexpected range %v", got)
+	}
+
+	if removed, _ := store.ZRemRangeByScore("events", ScoreRange{Min: math.Inf(-1), Max: 200}); removed != 2 {
+		t.Errorf("Expected 2 removed members, got %d", removed)
+	}
+	if popped, _ := store.ZPopMax("events", 1); len(popped) != 1 || popped[0].Member != "d" {
+		t.Errorf("Unexpected ZPopMax %v", popped)
+	}
+	store.ZRem("events", "c")
+	if store.Type("events") != TypeNone {
+		t.Error("Expected the key to be removed with its last member")
+	}
+	if store.Stats().UsedMemory != 0 {
+		t.Errorf("Expected no memory in use, got %d", store.Stats().UsedMemory)
+	}
+}
+
+func TestZSetWrongType(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.SAdd("set", "a")
+	if _, err := store.ZAdd("set", []ZMember{{"a", 1}}, ZAddOptions{}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := store.ZRange("set", 0, -1, false); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestZSetSnapshotRoundTrip(t *testing.T) {
+	entry, err := restoreLegacyValue(Entry{Type: TypeZSet, Value: []interface{}{
+		map[string]interface{}{"member": "a", "score": 2.0},
+		map[string]interface{}{"member": "b", "score": 1.0},
+	}})
+	if err != nil {
+		t.Fatalf("restoreLegacyValue failed: %v", err)
+	}
+	zset, ok := entry.Value.(*ZSet)
+	if !ok || !reflect.DeepEqual(members(zset.Members()), []string{"b", "a"}) {
+		t.Fatalf("Expected a restored sorted set, got %v", entry.Value)
+	}
+}
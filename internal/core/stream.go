@@ -0,0 +1,413 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidStreamID is returned when a stream ID is not of the form "ms" or "ms-seq"
+	ErrInvalidStreamID = errors.New("invalid stream ID specified as stream command argument")
+
+	// ErrStreamIDTooSmall is returned when an explicit XADD ID does not exceed the last one
+	ErrStreamIDTooSmall = errors.New("the ID specified in XADD is equal or smaller than the target stream top item")
+
+	// ErrNoGroup is returned when a stream or consumer group does not exist
+	ErrNoGroup = errors.New("NOGROUP No such key or consumer group")
+
+	// ErrBusyGroup is returned when creating a consumer group that already exists
+	ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+)
+
+// StreamID identifies a stream entry: the creation time in Unix milliseconds and a
+// sequence number for entries created within the same millisecond.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID is greater than or equal to every ID
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID parses "ms-seq", or "ms" which stands for "ms-0".
+func ParseStreamID(s string) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	var seq uint64
+	if hasSeq {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return StreamID{}, ErrInvalidStreamID
+		}
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Less reports whether id sorts before other
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// next returns the smallest ID greater than id
+func (id StreamID) next() StreamID {
+	if id.Seq == math.MaxUint64 {
+		return StreamID{Ms: id.Ms + 1}
+	}
+	return StreamID{Ms: id.Ms, Seq: id.Seq + 1}
+}
+
+func (id StreamID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+func (id *StreamID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseStreamID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// StreamEntry is an entry of a stream: an ID and a set of fields
+type StreamEntry struct {
+	ID     StreamID               `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// PendingEntry is an entry delivered to a consumer of a group and not acknowledged yet
+type PendingEntry struct {
+	ID          StreamID  `json:"id"`
+	Consumer    string    `json:"consumer"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Deliveries  int       `json:"deliveries"`
+}
+
+// consumerGroup tracks the last entry handed out to the group and the entries its
+// consumers have not acknowledged yet.
+type consumerGroup struct {
+	LastDelivered StreamID                   `json:"last_delivered"`
+	Pending       map[StreamID]*PendingEntry `json:"-"`
+}
+
+// Stream is a concurrency-safe append-only log of entries ordered by ID, with consumer
+// groups. Lookups by ID are logarithmic.
+type Stream struct {
+	mutex   sync.RWMutex
+	entries []StreamEntry
+	trimmed int // entries trimmed from the front of the backing array since it was last compacted
+	lastID  StreamID
+	groups  map[string]*consumerGroup
+}
+
+func NewStream() *Stream {
+	return &Stream{groups: make(map[string]*consumerGroup)}
+}
+
+// Add appends an entry. A nil id generates one from now that is greater than the last
+// ID; an explicit id must be greater than the last ID.
+func (s *Stream) Add(id *StreamID, fields map[string]interface{}, now time.Time) (StreamID, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var newID StreamID
+	if id == nil {
+		newID = StreamID{Ms: uint64(now.UnixMilli())}
+		if !s.lastID.Less(newID) {
+			newID = s.lastID.next()
+		}
+	} else {
+		if !s.lastID.Less(*id) {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		newID = *id
+	}
+
+	s.entries = append(s.entries, StreamEntry{ID: newID, Fields: fields})
+	s.lastID = newID
+	return newID, nil
+}
+
+// Len returns the number of entries
+func (s *Stream) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.entries)
+}
+
+// LastID returns the ID of the last entry ever added, even when it was trimmed since
+func (s *Stream) LastID() StreamID {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastID
+}
+
+// Range returns up to count entries with IDs between start and end inclusive; a count
+// that is not positive returns all of them.
+func (s *Stream) Range(start, end StreamID, count int) []StreamEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.rangeFrom(s.search(start), end, count)
+}
+
+// Trim removes the oldest entries so that at most maxLen remain, and returns them.
+func (s *Stream) Trim(maxLen int) []StreamEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if maxLen < 0 || len(s.entries) <= maxLen {
+		return nil
+	}
+	cut := len(s.entries) - maxLen
+	removed := append([]StreamEntry(nil), s.entries[:cut]...)
+	// Clear the trimmed slots so their fields can be garbage collected, and only copy the
+	// tail into a new array once more entries have been trimmed than remain, which keeps
+	// trimming a capped stream amortized O(1) per entry.
+	clear(s.entries[:cut])
+	s.entries = s.entries[cut:]
+	s.trimmed += cut
+	if s.trimmed > len(s.entries) {
+		s.entries = append(make([]StreamEntry, 0, len(s.entries)), s.entries...)
+		s.trimmed = 0
+	}
+	return removed
+}
+
+// CreateGroup adds a consumer group that will deliver the entries after start.
+func (s *Stream) CreateGroup(name string, start StreamID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.groups[name]; found {
+		return ErrBusyGroup
+	}
+	s.groups[name] = &consumerGroup{LastDelivered: start, Pending: make(map[StreamID]*PendingEntry)}
+	return nil
+}
+
+// ReadGroup delivers up to count entries the group has not delivered yet to consumer and
+// records them as pending unless noAck is set.
+func (s *Stream) ReadGroup(group, consumer string, count int, noAck bool, now time.Time) ([]StreamEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	g, found := s.groups[group]
+	if !found {
+		return nil, ErrNoGroup
+	}
+	entries := s.rangeFrom(s.search(g.LastDelivered.next()), MaxStreamID, count)
+	for _, entry := range entries {
+		g.LastDelivered = entry.ID
+		if !noAck {
+			g.Pending[entry.ID] = &PendingEntry{ID: entry.ID, Consumer: consumer, DeliveredAt: now, Deliveries: 1}
+		}
+	}
+	return entries, nil
+}
+
+// PendingFor returns up to count entries pending for consumer with IDs after start, in
+// ID order; entries trimmed from the stream come back with nil fields.
+func (s *Stream) PendingFor(group, consumer string, after StreamID, count int) ([]StreamEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	g, found := s.groups[group]
+	if !found {
+		return nil, ErrNoGroup
+	}
+	entries := make([]StreamEntry, 0)
+	for _, p := range s.sortedPending(g) {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		if p.Consumer == consumer && after.Less(p.ID) {
+			entries = append(entries, StreamEntry{ID: p.ID, Fields: s.fields(p.ID)})
+		}
+	}
+	return entries, nil
+}
+
+// Ack removes ids from the group's pending entries and returns how many were pending.
+func (s *Stream) Ack(group string, ids ...StreamID) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	g, found := s.groups[group]
+	if !found {
+		return 0, ErrNoGroup
+	}
+	acked := 0
+	for _, id := range ids {
+		if _, pending := g.Pending[id]; pending {
+			delete(g.Pending, id)
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// Pending returns up to count pending entries of the group with IDs between start and
+// end, optionally only those of consumer, in ID order.
+func (s *Stream) Pending(group string, start, end StreamID, count int, consumer string) ([]PendingEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	g, found := s.groups[group]
+	if !found {
+		return nil, ErrNoGroup
+	}
+	pending := make([]PendingEntry, 0)
+	for _, p := range s.sortedPending(g) {
+		if count > 0 && len(pending) == count {
+			break
+		}
+		if p.ID.Less(start) || end.Less(p.ID) || (consumer != "" && p.Consumer != consumer) {
+			continue
+		}
+		pending = append(pending, *p)
+	}
+	return pending, nil
+}
+
+// Claim transfers the pending entries among ids that have been idle for at least minIdle
+// to consumer and returns them. Claimed entries count as delivered again. Pending entries
+// that were trimmed from the stream are dropped from the group instead.
+func (s *Stream) Claim(group, consumer string, minIdle time.Duration, ids []StreamID, now time.Time) ([]StreamEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	g, found := s.groups[group]
+	if !found {
+		return nil, ErrNoGroup
+	}
+	claimed := make([]StreamEntry, 0)
+	for _, id := range ids {
+		p, pending := g.Pending[id]
+		if !pending || now.Sub(p.DeliveredAt) < minIdle {
+			continue
+		}
+		fields := s.fields(id)
+		if fields == nil {
+			delete(g.Pending, id)
+			continue
+		}
+		p.Consumer = consumer
+		p.DeliveredAt = now
+		p.Deliveries++
+		claimed = append(claimed, StreamEntry{ID: id, Fields: fields})
+	}
+	return claimed, nil
+}
+
+// search returns the index of the first entry with an ID not less than id. Caller must hold the lock.
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.entries), func(i int) bool { return !s.entries[i].ID.Less(id) })
+}
+
+// rangeFrom copies up to count entries from index i while their ID does not exceed end.
+// Caller must hold the lock.
+func (s *Stream) rangeFrom(i int, end StreamID, count int) []StreamEntry {
+	entries := make([]StreamEntry, 0)
+	for ; i < len(s.entries) && !end.Less(s.entries[i].ID); i++ {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		entries = append(entries, s.entries[i])
+	}
+	return entries
+}
+
+// fields returns the fields of the entry with id, or nil when it is not in the stream.
+// Caller must hold the lock.
+func (s *Stream) fields(id StreamID) map[string]interface{} {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i].Fields
+	}
+	return nil
+}
+
+// sortedPending returns the pending entries of g in ID order. Caller must hold the lock.
+func (s *Stream) sortedPending(g *consumerGroup) []*PendingEntry {
+	pending := make([]*PendingEntry, 0, len(g.Pending))
+	for _, p := range g.Pending {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID.Less(pending[j].ID) })
+	return pending
+}
+
+// streamSnapshot is the JSON form of a stream, including its consumer groups
+type streamSnapshot struct {
+	LastID  StreamID                 `json:"last_id"`
+	Entries []StreamEntry            `json:"entries"`
+	Groups  map[string]groupSnapshot `json:"groups,omitempty"`
+}
+
+type groupSnapshot struct {
+	LastDelivered StreamID       `json:"last_delivered"`
+	Pending       []PendingEntry `json:"pending"`
+}
+
+// MarshalJSON encodes the entries, the last ID and the consumer groups with their pending entries
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.snapshot())
+}
+
+// snapshot copies the state of the stream. The copy does not share the entries with the
+// stream, which Trim clears in place.
+func (s *Stream) snapshot() streamSnapshot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshot := streamSnapshot{LastID: s.lastID, Entries: slices.Clone(s.entries), Groups: make(map[string]groupSnapshot)}
+	for name, g := range s.groups {
+		group := groupSnapshot{LastDelivered: g.LastDelivered, Pending: make([]PendingEntry, 0, len(g.Pending))}
+		for _, p := range s.sortedPending(g) {
+			group.Pending = append(group.Pending, *p)
+		}
+		snapshot.Groups[name] = group
+	}
+	return snapshot
+}
+
+// UnmarshalJSON restores a stream encoded by MarshalJSON
+func (s *Stream) UnmarshalJSON(data []byte) error {
+	var snapshot streamSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	s.restore(snapshot)
+	return nil
+}
+
+// restore replaces the state of a new stream with snapshot
+func (s *Stream) restore(snapshot streamSnapshot) {
+	s.entries = snapshot.Entries
+	s.lastID = snapshot.LastID
+	s.groups = make(map[string]*consumerGroup, len(snapshot.Groups))
+	for name, group := range snapshot.Groups {
+		g := &consumerGroup{LastDelivered: group.LastDelivered, Pending: make(map[StreamID]*PendingEntry)}
+		for i := range group.Pending {
+			g.Pending[group.Pending[i].ID] = &group.Pending[i]
+		}
+		s.groups[name] = g
+	}
+}
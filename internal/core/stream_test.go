@@ -0,0 +1,155 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func ids(entries []StreamEntry) []string {
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.ID.String()
+	}
+	return out
+}
+
+func TestParseStreamID(t *testing.T) {
+	if id, err := ParseStreamID("1526919030474-55"); err != nil || id != (StreamID{1526919030474, 55}) {
+		t.Errorf("Expected 1526919030474-55, got %v (%v)", id, err)
+	}
+	if id, err := ParseStreamID("7"); err != nil || id != (StreamID{7, 0}) {
+		t.Errorf("Expected 7-0, got %v (%v)", id, err)
+	}
+	for _, bad := range []string{"", "-", "1-", "x-1", "1-2-3"} {
+		if _, err := ParseStreamID(bad); !errors.Is(err, ErrInvalidStreamID) {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestStreamGeneratesMonotonicIDs(t *testing.T) {
+	s := NewStream()
+	now := time.UnixMilli(1000)
+
+	first, _ := s.Add(nil, map[string]interface{}{"n": 1}, now)
+	second, _ := s.Add(nil, map[string]interface{}{"n": 2}, now)
+	// A clock going backwards must not produce a smaller ID.
+	third, _ := s.Add(nil, map[string]interface{}{"n": 3}, time.UnixMilli(500))
+	if first != (StreamID{1000, 0}) || second != (StreamID{1000, 1}) || third != (StreamID{1000, 2}) {
+		t.Errorf("Expected 1000-0, 1000-1, 1000-2, got %v, %v, %v", first, second, third)
+	}
+	if _, err := s.Add(&StreamID{1000, 2}, map[string]interface{}{"n": 4}, now); !errors.Is(err, ErrStreamIDTooSmall) {
+		t.Errorf("Expected ErrStreamIDTooSmall, got %v", err)
+	}
+}
+
+func TestStreamRangeAndTrim(t *testing.T) {
+	s := NewStream()
+	for i := uint64(1); i <= 5; i++ {
+		s.Add(&StreamID{i, 0}, map[string]interface{}{"n": i}, time.Now())
+	}
+
+	if got := ids(s.Range(StreamID{2, 0}, StreamID{4, 0}, 0)); len(got) != 3 || got[0] != "2-0" || got[2] != "4-0" {
+		t.Errorf("Expected 2-0..4-0, got %v", got)
+	}
+	if got := ids(s.Range(StreamID{}, MaxStreamID, 2)); len(got) != 2 || got[1] != "2-0" {
+		t.Errorf("Expected the first two entries, got %v", got)
+	}
+
+	if removed := s.Trim(2); len(removed) != 3 || s.Len() != 2 {
+		t.Errorf("Expected 3 entries trimmed and 2 left, got %d and %d", len(removed), s.Len())
+	}
+	if s.LastID() != (StreamID{5, 0}) {
+		t.Errorf("Expected the last ID to survive trimming, got %v", s.LastID())
+	}
+}
+
+func TestStreamCappedTrimKeepsBackingArrayBounded(t *testing.T) {
+	s := NewStream()
+	for i := uint64(1); i <= 1000; i++ {
+		s.Add(&StreamID{i, 0}, map[string]interface{}{"n": i}, time.Now())
+		if removed := s.Trim(10); i > 10 && (len(removed) != 1 || removed[0].ID != StreamID{i - 10, 0}) {
+			t.Fatalf("Expected %d-0 trimmed, got %v", i-10, ids(removed))
+		}
+	}
+	if got := ids(s.Range(StreamID{}, MaxStreamID, 0)); len(got) != 10 || got[0] != "991-0" || got[9] != "1000-0" {
+		t.Errorf("Expected 991-0..1000-0, got %v", got)
+	}
+	if s.trimmed > 10 || cap(s.entries) > 40 {
+		t.Errorf("Expected trimmed entries to be compacted away, got %d trimmed and capacity %d", s.trimmed, cap(s.entries))
+	}
+}
+
+func TestStreamSnapshotSurvivesTrim(t *testing.T) {
+	s := NewStream()
+	for i := uint64(1); i <= 3; i++ {
+		s.Add(&StreamID{i, 0}, map[string]interface{}{"n": i}, time.Now())
+	}
+	snapshot := s.snapshot()
+	s.Trim(0)
+	if got := ids(snapshot.Entries); len(got) != 3 || got[0] != "1-0" || snapshot.Entries[0].Fields == nil {
+		t.Errorf("Expected the snapshot to keep 1-0..3-0, got %v", snapshot.Entries)
+	}
+}
+
+func TestStreamConsumerGroup(t *testing.T) {
+	s := NewStream()
+	start := time.UnixMilli(0)
+	for i := uint64(1); i <= 3; i++ {
+		s.Add(&StreamID{i, 0}, map[string]interface{}{"n": i}, start)
+	}
+	s.CreateGroup("workers", StreamID{})
+	if err := s.CreateGroup("workers", StreamID{}); !errors.Is(err, ErrBusyGroup) {
+		t.Errorf("Expected ErrBusyGroup, got %v", err)
+	}
+
+	a, _ := s.ReadGroup("workers", "alice", 2, false, start)
+	b, _ := s.ReadGroup("workers", "bob", 0, false, start)
+	if got := ids(a); len(got) != 2 || got[0] != "1-0" {
+		t.Errorf("Expected alice to get 1-0 and 2-0, got %v", got)
+	}
+	if got := ids(b); len(got) != 1 || got[0] != "3-0" {
+		t.Errorf("Expected bob to get 3-0, got %v", got)
+	}
+
+	if acked, _ := s.Ack("workers", StreamID{1, 0}, StreamID{9, 0}); acked != 1 {
+		t.Errorf("Expected 1 acknowledgement, got %d", acked)
+	}
+	pending, _ := s.Pending("workers", StreamID{}, MaxStreamID, 0, "")
+	if len(pending) != 2 || pending[0].Consumer != "alice" || pending[1].Consumer != "bob" {
+		t.Fatalf("Expected 2-0 pending for alice and 3-0 for bob, got %+v", pending)
+	}
+
+	// Only entries idle long enough change hands.
+	claimed, _ := s.Claim("workers", "carol", time.Minute, []StreamID{{2, 0}}, start.Add(time.Second))
+	if len(claimed) != 0 {
+		t.Errorf("Expected nothing claimed before the idle time, got %v", ids(claimed))
+	}
+	claimed, _ = s.Claim("workers", "carol", time.Minute, []StreamID{{2, 0}}, start.Add(time.Hour))
+	if got := ids(claimed); len(got) != 1 || got[0] != "2-0" {
+		t.Errorf("Expected carol to claim 2-0, got %v", got)
+	}
+	pending, _ = s.Pending("workers", StreamID{}, MaxStreamID, 0, "carol")
+	if len(pending) != 1 || pending[0].Deliveries != 2 {
+		t.Errorf("Expected 2-0 delivered twice and owned by carol, got %+v", pending)
+	}
+
+	if _, err := s.ReadGroup("missing", "alice", 0, false, start); !errors.Is(err, ErrNoGroup) {
+		t.Errorf("Expected ErrNoGroup, got %v", err)
+	}
+}
+
+func TestStreamClaimDropsTrimmedEntries(t *testing.T) {
+	s := NewStream()
+	s.Add(&StreamID{1, 0}, map[string]interface{}{"n": 1}, time.Now())
+	s.CreateGroup("workers", StreamID{})
+	s.ReadGroup("workers", "alice", 0, false, time.UnixMilli(0))
+	s.Trim(0)
+
+	claimed, _ := s.Claim("workers", "bob", 0, []StreamID{{1, 0}}, time.Now())
+	pending, _ := s.Pending("workers", StreamID{}, MaxStreamID, 0, "")
+	if len(claimed) != 0 || len(pending) != 0 {
+		t.Errorf("Expected the trimmed entry to leave the pending list, got %v and %+v", ids(claimed), pending)
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTxAborted is returned by Exec when a watched key changed since it was watched
+var ErrTxAborted = errors.New("transaction aborted: a watched key was modified")
+
+// Transaction queues commands and executes them atomically under the locks of every
+// shard they touch. It is not safe for concurrent use.
+type Transaction struct {
+	store   *ShardedStore
+	watched map[string]uint64
+	ops     []txOp
+}
+
+// TxResult is the outcome of one queued command. A failing command does not undo
+// the others, mirroring Redis' MULTI/EXEC semantics.
+type TxResult struct {
+	Value interface{}
+	Err   error
+}
+
+type txOp struct {
+	kind   string
+	key    string
+	value  interface{}
+	values []interface{}
+	ttl    int
+	delta  int64
+}
+
+// Multi starts a new transaction.
+func (ss *ShardedStore) Multi() *Transaction {
+	return &Transaction{store: ss, watched: make(map[string]uint64)}
+}
+
+// Watch records the current version of keys. Exec aborts if any of them changes before it runs.
+func (tx *Transaction) Watch(keys ...string) *Transaction {
+	for _, key := range keys {
+		tx.watched[key] = tx.store.Version(key)
+	}
+	return tx
+}
+
+// WatchVersion makes Exec abort unless key is still at version; 0 means the key must not exist.
+func (tx *Transaction) WatchVersion(key string, version uint64) *Transaction {
+	tx.watched[key] = version
+	return tx
+}
+
+// Set queues a write of value with an optional TTL (in seconds).
+func (tx *Transaction) Set(key string, value interface{}, ttl int) *Transaction {
+	tx.ops = append(tx.ops, txOp{kind: "set", key: key, value: value, ttl: ttl})
+	return tx
+}
+
+// Delete queues the removal of key.
+func (tx *Transaction) Delete(key string) *Transaction {
+	tx.ops = append(tx.ops, txOp{kind: "del", key: key})
+	return tx
+}
+
+// LPush queues a push of values at the head of the list at key.
+func (tx *Transaction) LPush(key string, values ...interface{}) *Transaction {
+	tx.ops = append(tx.ops, txOp{kind: "lpush", key: key, values: values})
+	return tx
+}
+
+// RPush queues a push of values at the tail of the list at key.
+func (tx *Transaction) RPush(key string, values ...interface{}) *Transaction {
+	tx.ops = append(tx.ops, txOp{kind: "rpush", key: key, values: values})
+	return tx
+}
+
+// IncrBy queues an increment of the counter at key by delta.
+func (tx *Transaction) IncrBy(key string, delta int64) *Transaction {
+	tx.ops = append(tx.ops, txOp{kind: "incrby", key: key, delta: delta})
+	return tx
+}
+
+// Len returns the number of queued commands.
+func (tx *Transaction) Len() int {
+	return len(tx.ops)
+}
+
+// Exec runs the queued commands as one atomic step and returns their results in order.
+// It returns ErrTxAborted without running anything when a watched key changed.
+func (tx *Transaction) Exec() ([]TxResult, error) {
+	ss := tx.store
+
+	keys := make([]string, 0, len(tx.watched)+len(tx.ops))
+	written := make([]string, 0, len(tx.ops))
+	for key := range tx.watched {
+		keys = append(keys, key)
+	}
+	for _, op := range tx.ops {
+		keys = append(keys, op.key)
+		if op.kind != "del" {
+			written = append(written, op.key)
+		}
+	}
+	if err := ss.ensureCapacity(written...); err != nil {
+		return nil, err
+	}
+
+	unlock := ss.lockShards(keys)
+	now := time.Now()
+	for key, version := range tx.watched {
+		entry, _ := ss.getShard(key).lookup(key, now.UnixMilli())
+		if entry.Version != version {
+			unlock()
+			return nil, ErrTxAborted
+		}
+	}
+
+	results := make([]TxResult, len(tx.ops))
+	var pushed []string
+	for i, op := range tx.ops {
+		shard := ss.getShard(op.key)
+		switch op.kind {
+		case "set":
+			shard.put(op.key, Entry{Value: op.value, Expiration: expirationAt(now, op.ttl)})
+			shard.events.emit(op.key, EventSet)
+			shard.aof.appendSet(op.key, shard.data[op.key])
+		case "del":
+			_, found := shard.lookup(op.key, now.UnixMilli())
+			shard.remove(op.key)
+			if found {
+				shard.events.emit(op.key, EventDel)
+				shard.aof.appendDel(op.key)
+			}
+			results[i].Value = found
+		case "lpush", "rpush":
+			results[i].Value, results[i].Err = shard.push(op.key, op.kind == "lpush", op.values, now.UnixMilli())
+			pushed = append(pushed, op.key)
+		case "incrby":
+			results[i].Value, results[i].Err = shard.incrBy(op.key, op.delta, now.UnixMilli())
+		}
+	}
+	unlock()
+
+	for _, key := range pushed {
+		ss.serveBlocked(key)
+	}
+	return results, nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionExec(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("old", "value", 0)
+	results, err := store.Multi().
+		Set("name", "alice", 0).
+		IncrBy("visits", 5).
+		RPush("log", "a", "b").
+		Delete("old").
+		Exec()
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	expected := []interface{}{nil, int64(5), 2, true}
+	for i, result := range results {
+		if result.Err != nil || result.Value != expected[i] {
+			t.Errorf("Result %d: expected %v, got %v (%v)", i, expected[i], result.Value, result.Err)
+		}
+	}
+	if val, _ := store.Get("name"); val != "alice" {
+		t.Errorf("Expected 'alice', got %v", val)
+	}
+	if _, found := store.Get("old"); found {
+		t.Error("Expected 'old' to be deleted")
+	}
+}
+
+func TestTransactionWatchAborts(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("balance", 100, 0)
+	tx := store.Multi().Watch("balance").Set("balance", 50, 0)
+	store.Set("balance", 200, 0)
+
+	if _, err := tx.Exec(); !errors.Is(err, ErrTxAborted) {
+		t.Fatalf("Expected ErrTxAborted, got %v", err)
+	}
+	if val, _ := store.Get("balance"); val != 200 {
+		t.Errorf("Expected the aborted transaction to leave 200, got %v", val)
+	}
+}
+
+func TestTransactionWatchMissingKey(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	tx := store.Multi().Watch("lock").Set("lock", "me", 0)
+	if _, err := tx.Exec(); err != nil {
+		t.Fatalf("Expected an untouched missing key to pass, got %v", err)
+	}
+
+	tx = store.Multi().WatchVersion("lock", 0).Set("lock", "other", 0)
+	if _, err := tx.Exec(); !errors.Is(err, ErrTxAborted) {
+		t.Errorf("Expected ErrTxAborted once the key exists, got %v", err)
+	}
+}
+
+func TestTransactionCommandErrorsDoNotAbort(t *testing.T) {
+	store := NewShardedStore()
+	defer store.Close()
+
+	store.Set("name", "alice", 0)
+	results, err := store.Multi().RPush("name", "x").Set("other", "ok", 0).Exec()
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType for the push, got %v", results[0].Err)
+	}
+	if val, _ := store.Get("other"); val != "ok" {
+		t.Errorf("Expected the remaining commands to run, got %v", val)
+	}
+	if store.Type("name") != TypeString {
+		t.Errorf("Expected 'name' to keep its string value, got %s", store.Type("name"))
+	}
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-memory-store/internal/persistence"
+)
+
+// ValueType tags the kind of value held by an Entry
+type ValueType string
+
+const (
+	TypeNone   ValueType = "none"   // the key does not exist
+	TypeString ValueType = "string" // a plain value written with Set
+	TypeList   ValueType = "list"
+	TypeHash   ValueType = "hash"
+	TypeSet    ValueType = "set"
+	TypeZSet   ValueType = "zset"
+	TypeStream ValueType = "stream"
+)
+
+var (
+	// ErrWrongType is returned when an operation is applied to a key holding another kind of value
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	// ErrNoSuchKey is returned by operations that modify an existing value in place
+	ErrNoSuchKey = errors.New("no such key")
+)
+
+// typeOf derives the type tag for a value
+func typeOf(value interface{}) ValueType {
+	switch value.(type) {
+	case *List:
+		return TypeList
+	case *Hash:
+		return TypeHash
+	case *Set:
+		return TypeSet
+	case *ZSet:
+		return TypeZSet
+	case *Stream:
+		return TypeStream
+	default:
+		return TypeString
+	}
+}
+
+// restoreLegacyValue rebuilds the value of an entry saved by a version 1 snapshot, where
+// collections were saved as plain JSON, through the decoder registered for its type.
+// Sorted sets and streams were saved in another shape than their decoders take.
+func restoreLegacyValue(entry Entry) (Entry, error) {
+	contents := entry.Value
+	switch entry.Type {
+	case "", TypeString:
+		return entry, nil
+	case TypeZSet:
+		items, _ := entry.Value.([]interface{})
+		scores := make(map[string]interface{}, len(items))
+		for _, item := range items {
+			m, _ := item.(map[string]interface{})
+			member, _ := m["member"].(string)
+			scores[member] = m["score"]
+		}
+		contents = scores
+	case TypeStream:
+		data, err := json.Marshal(entry.Value)
+		if err != nil {
+			return entry, err
+		}
+		var snapshot streamSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return entry, fmt.Errorf("stream: %w", err)
+		}
+		if contents, err = streamContents(snapshot); err != nil {
+			return entry, err
+		}
+	}
+	value, err := persistence.DecodeCollection(string(entry.Type), contents)
+	if err != nil {
+		return entry, err
+	}
+	entry.Value = value
+	return entry, nil
+}
+
+// lookup returns the live entry for key. Caller must hold the shard lock.
+func (s *Store) lookup(key string, now int64) (Entry, bool) {
+	entry, found := s.data[key]
+	if !found || entry.isExpired(now) {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// collection is a value that goes away with its key once it holds nothing
+type collection interface {
+	Len() int
+}
+
+// removeIfEmpty deletes key once its collection has nothing left, and logs the deletion
+// so that a replay does not carry the TTL of the emptied key over to a new one. Caller
+// must hold the write lock.
+func (s *Store) removeIfEmpty(key string, value collection) {
+	if value.Len() == 0 {
+		s.remove(key)
+		s.events.emit(key, EventDel)
+		s.aof.appendDel(key)
+	}
+}
+
+// Type returns the type of the value stored at key, or TypeNone when it does not exist.
+func (ss *ShardedStore) Type(key string) ValueType {
+	shard := ss.getShard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	entry, found := shard.lookup(key, time.Now().UnixMilli())
+	if !found {
+		return TypeNone
+	}
+	return entry.Type
+}
+
+// The collections are saved in snapshots through the values they hold, so nested
+// integers and blobs keep their types; see persistence.EncodeValue.
+func init() {
+	persistence.RegisterCollection(&List{}, persistence.Collection{
+		Tag:    string(TypeList),
+		Encode: func(value interface{}) (interface{}, error) { return value.(*List).GetAll(), nil },
+		Decode: func(contents interface{}) (interface{}, error) {
+			items, ok := contents.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("list holds %T", contents)
+			}
+			list := NewList()
+			list.RPush(items...)
+			return list, nil
+		},
+	})
+	persistence.RegisterCollection(&Hash{}, persistence.Collection{
+		Tag:    string(TypeHash),
+		Encode: func(value interface{}) (interface{}, error) { return value.(*Hash).GetAll(), nil },
+		Decode: func(contents interface{}) (interface{}, error) {
+			fields, ok := contents.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("hash holds %T", contents)
+			}
+			hash := NewHash()
+			for field, value := range fields {
+				hash.Set(field, value)
+			}
+			return hash, nil
+		},
+	})
+	persistence.RegisterCollection(&Set{}, persistence.Collection{
+		Tag: string(TypeSet),
+		Encode: func(value interface{}) (interface{}, error) {
+			members := value.(*Set).Members()
+			items := make([]interface{}, len(members))
+			for i, member := range members {
+				items[i] = member
+			}
+			return items, nil
+		},
+		Decode: func(contents interface{}) (interface{}, error) {
+			items, ok := contents.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("set holds %T", contents)
+			}
+			set := NewSet()
+			for _, item := range items {
+				member, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("set member is %T", item)
+				}
+				set.Add(member)
+			}
+			return set, nil
+		},
+	})
+	persistence.RegisterCollection(&ZSet{}, persistence.Collection{
+		Tag: string(TypeZSet),
+		Encode: func(value interface{}) (interface{}, error) {
+			scores := make(map[string]interface{})
+			for _, m := range value.(*ZSet).Members() {
+				scores[m.Member] = m.Score
+			}
+			return scores, nil
+		},
+		Decode: func(contents interface{}) (interface{}, error) {
+			scores, ok := contents.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("sorted set holds %T", contents)
+			}
+			zset := NewZSet()
+			for member, score := range scores {
+				s, ok := score.(float64)
+				if !ok {
+					return nil, fmt.Errorf("sorted set score is %T", score)
+				}
+				zset.Set(member, s)
+			}
+			return zset, nil
+		},
+	})
+	// Stream entries are encoded as [id, fields] pairs, and the IDs, consumer groups and
+	// pending entries, which hold no user values, as the JSON state of the stream.
+	persistence.RegisterCollection(&Stream{}, persistence.Collection{
+		Tag: string(TypeStream),
+		Encode: func(value interface{}) (interface{}, error) {
+			return streamContents(value.(*Stream).snapshot())
+		},
+		Decode: func(contents interface{}) (interface{}, error) {
+			parts, _ := contents.(map[string]interface{})
+			entries, _ := parts["entries"].([]interface{})
+			state, _ := parts["state"].([]byte)
+			var snapshot streamSnapshot
+			if err := json.Unmarshal(state, &snapshot); err != nil {
+				return nil, fmt.Errorf("stream state: %w", err)
+			}
+			for _, item := range entries {
+				pair, _ := item.([]interface{})
+				if len(pair) != 2 {
+					return nil, fmt.Errorf("stream entry is %v", item)
+				}
+				id, _ := pair[0].(string)
+				parsed, err := ParseStreamID(id)
+				if err != nil {
+					return nil, err
+				}
+				fields, _ := pair[1].(map[string]interface{})
+				snapshot.Entries = append(snapshot.Entries, StreamEntry{ID: parsed, Fields: fields})
+			}
+			stream := NewStream()
+			stream.restore(snapshot)
+			return stream, nil
+		},
+	})
+}
+
+// streamContents returns the contents a stream is encoded as, see init
+func streamContents(snapshot streamSnapshot) (interface{}, error) {
+	entries := make([]interface{}, len(snapshot.Entries))
+	for i, entry := range snapshot.Entries {
+		entries[i] = []interface{}{entry.ID.String(), entry.Fields}
+	}
+	snapshot.Entries = nil
+	state, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"entries": entries, "state": state}, nil
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidRange is returned when a score or lexical range bound cannot be parsed
+var ErrInvalidRange = errors.New("min or max is not a valid range bound")
+
+// ZMember is a member of a sorted set with its score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ScoreRange selects members by score. The zero value selects scores equal to 0;
+// use math.Inf for open ends.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinExclusive {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxExclusive {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// LexRange selects members lexically. An empty bound with its Unbounded flag set is open.
+type LexRange struct {
+	Min, Max                   string
+	MinExclusive, MaxExclusive bool
+	MinUnbounded, MaxUnbounded bool
+}
+
+func (r LexRange) aboveMin(member string) bool {
+	switch {
+	case r.MinUnbounded:
+		return true
+	case r.MinExclusive:
+		return member > r.Min
+	default:
+		return member >= r.Min
+	}
+}
+
+func (r LexRange) belowMax(member string) bool {
+	switch {
+	case r.MaxUnbounded:
+		return true
+	case r.MaxExclusive:
+		return member < r.Max
+	default:
+		return member <= r.Max
+	}
+}
+
+// ParseScoreRange parses Redis-style score bounds: a number, a number prefixed with "("
+// to exclude it, or "-inf" and "+inf".
+func ParseScoreRange(min, max string) (ScoreRange, error) {
+	var r ScoreRange
+	var err error
+	if r.Min, r.MinExclusive, err = parseScoreBound(min); err != nil {
+		return ScoreRange{}, err
+	}
+	if r.Max, r.MaxExclusive, err = parseScoreBound(max); err != nil {
+		return ScoreRange{}, err
+	}
+	return r, nil
+}
+
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	score, err := strconv.ParseFloat(strings.TrimPrefix(bound, "("), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false, ErrInvalidRange
+	}
+	return score, exclusive, nil
+}
+
+// ParseLexRange parses Redis-style lexical bounds: "[" or "(" followed by the member for
+// an inclusive or exclusive bound, or "-" and "+" for open ends.
+func ParseLexRange(min, max string) (LexRange, error) {
+	var r LexRange
+	var err error
+	if r.Min, r.MinExclusive, r.MinUnbounded, err = parseLexBound(min, "-"); err != nil {
+		return LexRange{}, err
+	}
+	if r.Max, r.MaxExclusive, r.MaxUnbounded, err = parseLexBound(max, "+"); err != nil {
+		return LexRange{}, err
+	}
+	return r, nil
+}
+
+func parseLexBound(bound, open string) (string, bool, bool, error) {
+	switch {
+	case bound == open:
+		return "", false, true, nil
+	case strings.HasPrefix(bound, "["):
+		return bound[1:], false, false, nil
+	case strings.HasPrefix(bound, "("):
+		return bound[1:], true, false, nil
+	}
+	return "", false, false, ErrInvalidRange
+}
+
+// ZSet is a concurrency-safe sorted set: unique string members ordered by score, with
+// logarithmic inserts, removals, rank lookups and range queries.
+type ZSet struct {
+	mutex  sync.RWMutex
+	scores map[string]float64
+	list   *skipList
+}
+
+func NewZSet() *ZSet {
+	return &ZSet{scores: make(map[string]float64), list: newSkipList()}
+}
+
+// Score returns the score of member
+func (z *ZSet) Score(member string) (float64, bool) {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+
+	score, found := z.scores[member]
+	return score, found
+}
+
+// Set adds member with score, or moves it to score when it exists. It reports whether the member was added.
+func (z *ZSet) Set(member string, score float64) bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	old, found := z.scores[member]
+	if found {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+	z.scores[member] = score
+	z.list.insert(score, member)
+	return !found
+}
+
+// Rem removes member and reports whether it existed
+func (z *ZSet) Rem(member string) bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	return z.remove(member)
+}
+
+// Len returns the number of members
+func (z *ZSet) Len() int {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	return len(z.scores)
+}
+
+// Rank returns the 0-based position of member by ascending score, or by descending score when rev is set
+func (z *ZSet) Rank(member string, rev bool) (int, bool) {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+
+	score, found := z.scores[member]
+	if !found {
+		return 0, false
+	}
+	rank := z.list.rank(score, member) - 1
+	if rev {
+		rank = z.list.length - 1 - rank
+	}
+	return rank, true
+}
+
+// Range returns the members between ranks start and stop inclusive, ascending or, when rev
+// is set, descending. Negative ranks count from the end.
+func (z *ZSet) Range(start, stop int, rev bool) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+
+	length := z.list.length
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []ZMember{}
+	}
+
+	members := make([]ZMember, 0, stop-start+1)
+	if rev {
+		for x := z.list.byRank(length - start); len(members) < cap(members); x = x.backward {
+			members = append(members, ZMember{Member: x.member, Score: x.score})
+		}
+		return members
+	}
+	for x := z.list.byRank(start + 1); len(members) < cap(members); x = x.level[0].forward {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+	}
+	return members
+}
+
+// RangeByScore returns the members within r in ascending order, skipping offset of them
+// and returning at most count; a negative count returns all of them.
+func (z *ZSet) RangeByScore(r ScoreRange, offset, count int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+
+	return collect(z.list.firstInScoreRange(r), offset, count, func(x *skipNode) bool {
+		return r.belowMax(x.score)
+	})
+}
+
+// RangeByLex returns the members within r in lexical order, like RangeByScore. All members
+// are expected to share the same score.
+func (z *ZSet) RangeByLex(r LexRange, offset, count int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+
+	return collect(z.list.firstInLexRange(r), offset, count, func(x *skipNode) bool {
+		return r.belowMax(x.member)
+	})
+}
+
+// RemRangeByScore removes the members within r and returns them
+func (z *ZSet) RemRangeByScore(r ScoreRange) []ZMember {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	removed := collect(z.list.firstInScoreRange(r), 0, -1, func(x *skipNode) bool {
+		return r.belowMax(x.score)
+	})
+	for _, m := range removed {
+		z.remove(m.Member)
+	}
+	return removed
+}
+
+// PopMin removes and returns up to count members with the lowest scores
+func (z *ZSet) PopMin(count int) []ZMember {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	popped := make([]ZMember, 0)
+	for len(popped) < count && z.list.length > 0 {
+		x := z.list.header.level[0].forward
+		popped = append(popped, ZMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+	return popped
+}
+
+// PopMax removes and returns up to count members with the highest scores, highest first
+func (z *ZSet) PopMax(count int) []ZMember {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	popped := make([]ZMember, 0)
+	for len(popped) < count && z.list.length > 0 {
+		x := z.list.tail
+		popped = append(popped, ZMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+	return popped
+}
+
+// Members returns all members in ascending order
+func (z *ZSet) Members() []ZMember {
+	return z.Range(0, -1, false)
+}
+
+// MarshalJSON encodes the sorted set as a JSON array of members and scores in ascending order
+func (z *ZSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(z.Members())
+}
+
+// remove deletes member from both indexes. Caller must hold the write lock.
+func (z *ZSet) remove(member string) bool {
+	score, found := z.scores[member]
+	if !found {
+		return false
+	}
+	delete(z.scores, member)
+	z.list.delete(score, member)
+	return true
+}
+
+// collect walks forward from x while inRange holds, skipping offset elements and
+// returning at most count of them; a negative count returns all of them.
+func collect(x *skipNode, offset, count int, inRange func(*skipNode) bool) []ZMember {
+	members := make([]ZMember, 0)
+	for ; x != nil && offset > 0 && inRange(x); offset-- {
+		x = x.level[0].forward
+	}
+	for ; x != nil && count != 0 && inRange(x); count-- {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+		x = x.level[0].forward
+	}
+	return members
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func members(zs []ZMember) []string {
+	names := make([]string, len(zs))
+	for i, m := range zs {
+		names[i] = m.Member
+	}
+	return names
+}
+
+func TestZSetOrdering(t *testing.T) {
+	z := NewZSet()
+	z.Set("carol", 30)
+	z.Set("alice", 10)
+	z.Set("bob", 20)
+	z.Set("bea", 20)
+
+	if got := members(z.Members()); !reflect.DeepEqual(got, []string{"alice", "bea", "bob", "carol"}) {
+		t.Errorf("Expected members ordered by score then name, got %v", got)
+	}
+	if z.Set("alice", 40) {
+		t.Error("Expected updating a score not to report an addition")
+	}
+	if rank, _ := z.Rank("alice", false); rank != 3 {
+		t.Errorf("Expected alice to move to rank 3, got %d", rank)
+	}
+	if rank, _ := z.Rank("alice", true); rank != 0 {
+		t.Errorf("Expected alice to have reverse rank 0, got %d", rank)
+	}
+}
+
+func TestZSetRange(t *testing.T) {
+	z := NewZSet()
+	for i := 0; i < 10; i++ {
+		z.Set(fmt.Sprintf("m%d", i), float64(i))
+	}
+
+	if got := members(z.Range(-3, -1, false)); !reflect.DeepEqual(got, []string{"m7", "m8", "m9"}) {
+		t.Errorf("Unexpected tail range %v", got)
+	}
+	if got := members(z.Range(0, 1, true)); !reflect.DeepEqual(got, []string{"m9", "m8"}) {
+		t.Errorf("Unexpected reverse range %v", got)
+	}
+	if got := z.Range(5, 2, false); len(got) != 0 {
+		t.Errorf("Expected an empty range, got %v", got)
+	}
+
+	r := ScoreRange{Min: 2, Max: 6, MinExclusive: true}
+	if got := members(z.RangeByScore(r, 1, 2)); !reflect.DeepEqual(got, []string{"m4", "m5"}) {
+		t.Errorf("Unexpected score range %v", got)
+	}
+	all := ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}
+	if got := z.RangeByScore(all, 0, -1); len(got) != 10 {
+		t.Errorf("Expected all members, got %d", len(got))
+	}
+}
+
+func TestZSetRangeByLex(t *testing.T) {
+	z := NewZSet()
+	for _, m := range []string{"a", "b", "c", "d", "e"} {
+		z.Set(m, 0)
+	}
+	r, err := ParseLexRange("(b", "[d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := members(z.RangeByLex(r, 0, -1)); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("Unexpected lex range %v", got)
+	}
+	r, _ = ParseLexRange("-", "(c")
+	if got := members(z.RangeByLex(r, 0, -1)); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Unexpected open lex range %v", got)
+	}
+	if _, err := ParseLexRange("b", "+"); err != ErrInvalidRange {
+		t.Errorf("Expected ErrInvalidRange, got %v", err)
+	}
+}
+
+func TestZSetPopAndRemRange(t *testing.T) {
+	z := NewZSet()
+	for i := 0; i < 6; i++ {
+		z.Set(fmt.Sprintf("m%d", i), float64(i))
+	}
+	if got := members(z.PopMin(2)); !reflect.DeepEqual(got, []string{"m0", "m1"}) {
+		t.Errorf("Unexpected PopMin %v", got)
+	}
+	if got := members(z.PopMax(1)); !reflect.DeepEqual(got, []string{"m5"}) {
+		t.Errorf("Unexpected PopMax %v", got)
+	}
+	if removed := z.RemRangeByScore(ScoreRange{Min: 2, Max: 3}); len(removed) != 2 || z.Len() != 1 {
+		t.Errorf("Expected 2 removed and 1 left, got %v and %d", removed, z.Len())
+	}
+}
+
+func TestParseScoreRange(t *testing.T) {
+	r, err := ParseScoreRange("(1.5", "+inf")
+	if err != nil || r.Min != 1.5 || !r.MinExclusive || !math.IsInf(r.Max, 1) {
+		t.Errorf("Unexpected range %+v (%v)", r, err)
+	}
+	if _, err := ParseScoreRange("abc", "1"); err != ErrInvalidRange {
+		t.Errorf("Expected ErrInvalidRange, got %v", err)
+	}
+}
+
+// TestSkipListRanks checks ranks and positions against a sorted reference after random updates.
+func TestSkipListRanks(t *testing.T) {
+	z := NewZSet()
+	scores := map[string]float64{}
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%d", rand.Intn(500))
+		if rand.Intn(4) == 0 {
+			z.Rem(member)
+			delete(scores, member)
+			continue
+		}
+		score := float64(rand.Intn(100))
+		z.Set(member, score)
+		scores[member] = score
+	}
+
+	expected := make([]string, 0, len(scores))
+	for member := range scores {
+		expected = append(expected, member)
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		a, b := expected[i], expected[j]
+		return scores[a] < scores[b] || (scores[a] == scores[b] && a < b)
+	})
+
+	if got := members(z.Members()); !reflect.DeepEqual(got, expected) {
+		t.Fatal("Skip list order differs from the reference")
+	}
+	for i, member := range expected {
+		if rank, _ := z.Rank(member, false); rank != i {
+			t.Fatalf("Expected %s at rank %d, got %d", member, i, rank)
+		}
+		if got := z.Range(i, i, false); got[0].Member != member {
+			t.Fatalf("Expected %s at position %d, got %s", member, i, got[0].Member)
+		}
+	}
+}
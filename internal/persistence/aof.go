@@ -0,0 +1,462 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls when appended records are flushed to stable storage.
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // fsync after every record; nothing acknowledged is lost
+	FsyncEverySec FsyncPolicy = "everysec" // fsync once per second; a crash loses at most a second
+	FsyncNo       FsyncPolicy = "no"       // leave flushing to the operating system
+)
+
+// ErrCorruptAOF is returned by ReplayAOF when a record in the middle of the file is damaged
+var ErrCorruptAOF = errors.New("append-only file is corrupt")
+
+// aofHeaderSize is the length and CRC-32C checksum written before every record
+const aofHeaderSize = 8
+
+// maxAOFRecordSize bounds the length of a record, so that a damaged length cannot make
+// replay allocate gigabytes
+const maxAOFRecordSize = 512 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// In an encrypted log every record is sealed on its own and prefixed with aofSealed. A
+// key record, prefixed with aofKeyRecord, names the key of the records that follow it
+// and carries its check tag; one is written whenever the log is opened or rewritten with
+// a keyring, so the records of a log can span several keys after a rotation. Plain
+// records are JSON and never start with either byte.
+var (
+	aofKeyRecord = []byte("\x00KEY")
+	aofSealed    = []byte{1}
+)
+
+// keyRecord returns the key record for the key id
+func keyRecord(id string, check []byte) []byte {
+	record := append([]byte{}, aofKeyRecord...)
+	record = append(record, byte(len(id)))
+	record = append(record, id...)
+	return append(record, check...)
+}
+
+// sealRecord encrypts record with aead, or returns it as is when aead is nil
+func sealRecord(aead cipher.AEAD, record []byte) []byte {
+	if aead == nil {
+		return record
+	}
+	return append(append([]byte{}, aofSealed...), seal(aead, record)...)
+}
+
+// ParseFsyncPolicy validates an fsync policy name
+func ParseFsyncPolicy(name string) (FsyncPolicy, error) {
+	switch policy := FsyncPolicy(name); policy {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown fsync policy %q", name)
+	}
+}
+
+// AOF is an append-only log of opaque records. Every record is written to the file as
+// soon as it is appended, so only the fsync policy decides what a machine crash can lose.
+type AOF struct {
+	mutex  sync.Mutex
+	path   string
+	file   *os.File
+	policy FsyncPolicy
+	size   int64
+	dirty  bool        // written since the last fsync
+	aead   cipher.AEAD // encrypts appended records; nil when the log is not encrypted
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenAOF opens filename for appending, creating it if needed. With FsyncEverySec a
+// background goroutine syncs the file every second until Close. With a keyring, the
+// records appended from now on are encrypted with its current key.
+func OpenAOF(filename string, policy FsyncPolicy, keyring *Keyring) (*AOF, error) {
+	if _, err := ParseFsyncPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	aof := &AOF{path: filename, file: file, policy: policy, size: info.Size(), stop: make(chan struct{}), done: make(chan struct{})}
+	if aead, id, check := keyring.currentCipher(); aead != nil {
+		n, err := file.Write(frame(keyRecord(id, check)))
+		aof.size += int64(n)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		aof.aead = aead
+	}
+	if policy == FsyncEverySec {
+		go aof.syncEverySecond()
+	} else {
+		close(aof.done)
+	}
+	return aof, nil
+}
+
+// frame prefixes record with its length and checksum
+func frame(record []byte) []byte {
+	framed := make([]byte, aofHeaderSize+len(record))
+	binary.BigEndian.PutUint32(framed[0:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(framed[4:8], crc32.Checksum(record, crcTable))
+	copy(framed[aofHeaderSize:], record)
+	return framed
+}
+
+// frameRecord seals and frames a record for the log, refusing records longer than
+// replay accepts
+func frameRecord(aead cipher.AEAD, record []byte) ([]byte, error) {
+	record = sealRecord(aead, record)
+	if len(record) > maxAOFRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the limit of %d", len(record), maxAOFRecordSize)
+	}
+	return frame(record), nil
+}
+
+// Append writes record to the log, and syncs it when the policy is FsyncAlways.
+func (a *AOF) Append(record []byte) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	framed, err := frameRecord(a.aead, record)
+	if err != nil {
+		return err
+	}
+	n, err := a.file.Write(framed)
+	a.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if a.policy == FsyncAlways {
+		return a.file.Sync()
+	}
+	a.dirty = true
+	return nil
+}
+
+// Size returns the current length of the log in bytes
+func (a *AOF) Size() int64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.size
+}
+
+// Sync flushes the log to stable storage
+func (a *AOF) Sync() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.sync()
+}
+
+func (a *AOF) sync() error {
+	if !a.dirty {
+		return nil
+	}
+	a.dirty = false
+	return a.file.Sync()
+}
+
+func (a *AOF) syncEverySecond() {
+	defer close(a.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.Sync()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Close syncs and closes the log
+func (a *AOF) Close() error {
+	if a.policy == FsyncEverySec {
+		close(a.stop)
+		<-a.done
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.dirty = true
+	if err := a.sync(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// AOFRewrite builds a replacement for an append-only file next to it. The live log keeps
+// receiving records until Commit swaps the new file in, so a crash during the rewrite
+// leaves the old log intact.
+type AOFRewrite struct {
+	file *os.File
+	size int64
+	aead cipher.AEAD
+}
+
+// NewAOFRewrite starts a replacement for the log at filename, in a temporary file beside
+// it. With a keyring the replacement is encrypted with its current key, whatever keys
+// the records of the old log were encrypted with.
+func NewAOFRewrite(filename string, keyring *Keyring) (*AOFRewrite, error) {
+	file, err := os.OpenFile(filename+".rewrite", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	rw := &AOFRewrite{file: file}
+	if aead, id, check := keyring.currentCipher(); aead != nil {
+		rw.aead = aead
+		if _, err := file.Write(frame(keyRecord(id, check))); err != nil {
+			rw.Abort()
+			return nil, err
+		}
+		rw.size = aofHeaderSize + int64(len(keyRecord(id, check)))
+	}
+	return rw, nil
+}
+
+// Append writes record to the replacement log
+func (rw *AOFRewrite) Append(record []byte) error {
+	framed, err := frameRecord(rw.aead, record)
+	if err != nil {
+		return err
+	}
+	n, err := rw.file.Write(framed)
+	rw.size += int64(n)
+	return err
+}
+
+// Commit syncs the replacement, renames it over the log of aof and makes aof append to it.
+// Records appended to aof before Commit returns must already be in the replacement.
+func (rw *AOFRewrite) Commit(aof *AOF) error {
+	if err := rw.file.Sync(); err != nil {
+		rw.Abort()
+		return err
+	}
+
+	aof.mutex.Lock()
+	defer aof.mutex.Unlock()
+
+	if err := rw.install(aof.path); err != nil {
+		return err
+	}
+	aof.file.Close()
+	aof.file = rw.file
+	aof.size = rw.size
+	aof.aead = rw.aead
+	aof.dirty = false
+	return nil
+}
+
+// Install syncs the replacement, renames it over the log at filename and closes it. It is
+// meant for logs that are not open for appending; see Commit for those that are.
+func (rw *AOFRewrite) Install(filename string) error {
+	if err := rw.file.Sync(); err != nil {
+		rw.Abort()
+		return err
+	}
+	if err := rw.install(filename); err != nil {
+		return err
+	}
+	return rw.file.Close()
+}
+
+// install renames the replacement over the log at filename
+func (rw *AOFRewrite) install(filename string) error {
+	if err := os.Rename(rw.file.Name(), filename); err != nil {
+		rw.Abort()
+		return err
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// Abort discards the replacement
+func (rw *AOFRewrite) Abort() {
+	rw.file.Close()
+	os.Remove(rw.file.Name())
+}
+
+// syncDir makes a rename in dir durable. Some platforms cannot sync directories; the
+// rename is still atomic there, only its durability is left to the OS.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// ReplayAOF calls apply with every record of filename in order and returns how many were
+// applied. A missing file replays nothing. A damaged final record, left behind by a crash
+// in the middle of an append, is discarded and cut from the file so new records follow
+// the last complete one; damage anywhere else returns ErrCorruptAOF, including a damaged
+// length that runs past the end of the file while complete records follow it. Encrypted records
+// are decrypted before apply sees them; ErrNoKey, ErrUnknownKey or ErrWrongKey is
+// returned when keyring lacks the key they were encrypted with, and ErrNotEncrypted when
+// the log starts unencrypted and keyring does not allow plaintext.
+func ReplayAOF(filename string, keyring *Keyring, apply func(record []byte) error) (int, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	var offset int64
+	var aead cipher.AEAD
+	plaintext := false // an unencrypted record has been accepted
+	applied := 0
+	header := make([]byte, aofHeaderSize)
+	for offset < size {
+		if _, err := io.ReadFull(file, header); err != nil {
+			return applied, truncateTail(file, offset, err)
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if length > maxAOFRecordSize {
+			return applied, fmt.Errorf("%w: record at offset %d claims %d bytes", ErrCorruptAOF, offset, length)
+		}
+		end := offset + aofHeaderSize + length
+		if end > size {
+			torn, err := tornTail(file, offset, size)
+			if err != nil {
+				return applied, err
+			}
+			if !torn {
+				return applied, fmt.Errorf("%w: record at offset %d runs past the end of the file", ErrCorruptAOF, offset)
+			}
+			return applied, truncateTail(file, offset, io.ErrUnexpectedEOF)
+		}
+		record := make([]byte, length)
+		if _, err := io.ReadFull(file, record); err != nil {
+			return applied, truncateTail(file, offset, err)
+		}
+		if crc32.Checksum(record, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			if end == size {
+				return applied, truncateTail(file, offset, nil)
+			}
+			return applied, fmt.Errorf("%w: bad checksum in record at offset %d", ErrCorruptAOF, offset)
+		}
+		switch {
+		case bytes.HasPrefix(record, aofKeyRecord):
+			if aead, err = readKeyRecord(keyring, record); err != nil {
+				return applied, fmt.Errorf("%s: record at offset %d: %w", filename, offset, err)
+			}
+			offset = end
+			continue
+		case aead != nil:
+			if !bytes.HasPrefix(record, aofSealed) {
+				return applied, fmt.Errorf("%w: unencrypted record at offset %d", ErrCorruptAOF, offset)
+			}
+			if record, err = open(aead, record[len(aofSealed):]); err != nil {
+				return applied, fmt.Errorf("%w: cannot decrypt record at offset %d", ErrCorruptAOF, offset)
+			}
+		case bytes.HasPrefix(record, aofSealed):
+			return applied, fmt.Errorf("%w: encrypted record at offset %d follows no key record", ErrCorruptAOF, offset)
+		case !plaintext:
+			if err := keyring.checkPlaintext(filename); err != nil {
+				return applied, err
+			}
+			plaintext = true
+		}
+		if err := apply(record); err != nil {
+			return applied, fmt.Errorf("replaying record at offset %d: %w", offset, err)
+		}
+		applied++
+		offset = end
+	}
+	return applied, nil
+}
+
+// readKeyRecord returns the key named by a key record
+func readKeyRecord(keyring *Keyring, record []byte) (cipher.AEAD, error) {
+	rest := record[len(aofKeyRecord):]
+	if len(rest) < 1 || len(rest) != 1+int(rest[0])+keyCheckSize {
+		return nil, fmt.Errorf("%w: malformed key record", ErrCorruptAOF)
+	}
+	return keyring.cipher(string(rest[1:1+rest[0]]), rest[1+rest[0]:])
+}
+
+// ReencryptAOF rewrites the log at filename with the current key of keyring, decrypting
+// its records with whichever keys of keyring they were written with. The log must not be
+// open for appending meanwhile; a running server rewrites its log with the new key on
+// its next rewrite instead.
+func ReencryptAOF(filename string, keyring *Keyring) error {
+	if keyring == nil {
+		return errors.New("no encryption key is configured")
+	}
+	rw, err := NewAOFRewrite(filename, keyring)
+	if err != nil {
+		return err
+	}
+	if _, err := ReplayAOF(filename, keyring.migrating(), rw.Append); err != nil {
+		rw.Abort()
+		return err
+	}
+	return rw.Install(filename)
+}
+
+// tornTail reports whether the bytes of file from offset to size can be a single record
+// cut short by a crash. They cannot when they are longer than any record, or when a
+// complete record starts within them, as after a length damaged in the middle of the log.
+// Empty records are never written, so zeroed space is not taken for records.
+func tornTail(file *os.File, offset, size int64) (bool, error) {
+	if size-offset > aofHeaderSize+maxAOFRecordSize {
+		return false, nil
+	}
+	tail := make([]byte, size-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil {
+		return false, err
+	}
+	for i := 1; i+aofHeaderSize <= len(tail); i++ {
+		length := int(binary.BigEndian.Uint32(tail[i : i+4]))
+		end := i + aofHeaderSize + length
+		if length > 0 && end <= len(tail) && crc32.Checksum(tail[i+aofHeaderSize:end], crcTable) == binary.BigEndian.Uint32(tail[i+4:i+8]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// truncateTail cuts an incomplete final record starting at offset. Errors other than a
+// short read are returned as is.
+func truncateTail(file *os.File, offset int64, readErr error) error {
+	if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+		return readErr
+	}
+	return file.Truncate(offset)
+}
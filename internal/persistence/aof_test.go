@@ -0,0 +1,148 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeAOF appends records to the log at filename, opened with keyring
+func writeAOF(t *testing.T, filename string, keyring *Keyring, records ...string) {
+	t.Helper()
+	aof, err := OpenAOF(filename, FsyncAlways, keyring)
+	if err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	for _, record := range records {
+		if err := aof.Append([]byte(record)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// replayAOF returns the records of the log at filename
+func replayAOF(filename string, keyring *Keyring) ([]string, error) {
+	var records []string
+	_, err := ReplayAOF(filename, keyring, func(record []byte) error {
+		records = append(records, string(record))
+		return nil
+	})
+	return records, err
+}
+
+func TestReplayAOFReturnsRecordsInOrder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	if records, err := replayAOF(filename, nil); err != nil || records != nil {
+		t.Fatalf("expected a missing log to replay nothing, got %v (%v)", records, err)
+	}
+
+	writeAOF(t, filename, nil, `{"op":"set","key":"a"}`, `{"op":"del","key":"a"}`)
+	writeAOF(t, filename, nil, `{"op":"set","key":"b"}`)
+	records, err := replayAOF(filename, nil)
+	if err != nil {
+		t.Fatalf("ReplayAOF failed: %v", err)
+	}
+	want := []string{`{"op":"set","key":"a"}`, `{"op":"del","key":"a"}`, `{"op":"set","key":"b"}`}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("expected %v, got %v", want, records)
+	}
+}
+
+func TestReplayAOFCutsTornTail(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	writeAOF(t, filename, nil, `{"key":"a"}`, `{"key":"b"}`)
+
+	// Simulate a crash in the middle of writing the last record.
+	info, _ := os.Stat(filename)
+	os.Truncate(filename, info.Size()-3)
+
+	records, err := replayAOF(filename, nil)
+	if err != nil {
+		t.Fatalf("ReplayAOF failed: %v", err)
+	}
+	if !reflect.DeepEqual(records, []string{`{"key":"a"}`}) {
+		t.Errorf("expected only the complete record, got %v", records)
+	}
+
+	writeAOF(t, filename, nil, `{"key":"c"}`)
+	if records, _ := replayAOF(filename, nil); !reflect.DeepEqual(records, []string{`{"key":"a"}`, `{"key":"c"}`}) {
+		t.Errorf("expected records appended after the repair to follow, got %v", records)
+	}
+}
+
+func TestReplayAOFRejectsCorruptRecord(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	writeAOF(t, filename, nil, `{"key":"a"}`, `{"key":"b"}`)
+
+	data, _ := os.ReadFile(filename)
+	data[10] ^= 0xff // inside the first record's payload
+	os.WriteFile(filename, data, 0o644)
+
+	if _, err := replayAOF(filename, nil); !errors.Is(err, ErrCorruptAOF) {
+		t.Errorf("expected ErrCorruptAOF, got %v", err)
+	}
+}
+
+func TestReplayAOFRejectsDamagedLength(t *testing.T) {
+	for name, length := range map[string]uint32{"past the end": 1 << 20, "over the limit": 1<<32 - 1} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "appendonly.aof")
+			writeAOF(t, filename, nil, `{"key":"a"}`, `{"key":"b"}`)
+
+			// A damaged length in the first record must not be mistaken for a torn tail
+			// and cut the complete records after it.
+			data, _ := os.ReadFile(filename)
+			binary.BigEndian.PutUint32(data[0:4], length)
+			os.WriteFile(filename, data, 0o644)
+
+			if _, err := replayAOF(filename, nil); !errors.Is(err, ErrCorruptAOF) {
+				t.Errorf("expected ErrCorruptAOF, got %v", err)
+			}
+			if info, _ := os.Stat(filename); info.Size() != int64(len(data)) {
+				t.Errorf("expected the file to be left at %d bytes, got %d", len(data), info.Size())
+			}
+		})
+	}
+}
+
+func TestAOFRewriteReplacesLog(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	writeAOF(t, filename, nil, `{"key":"a","n":1}`, `{"key":"a","n":2}`)
+	aof, err := OpenAOF(filename, FsyncNo, nil)
+	if err != nil {
+		t.Fatalf("OpenAOF failed: %v", err)
+	}
+	defer aof.Close()
+
+	rw, err := NewAOFRewrite(filename, nil)
+	if err != nil {
+		t.Fatalf("NewAOFRewrite failed: %v", err)
+	}
+	rw.Append([]byte(`{"key":"a","n":2}`))
+	if err := rw.Commit(aof); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := aof.Append([]byte(`{"key":"b"}`)); err != nil {
+		t.Fatalf("Append after the rewrite failed: %v", err)
+	}
+	if info, _ := os.Stat(filename); aof.Size() != info.Size() {
+		t.Errorf("expected the log size %d to match the file, got %d", info.Size(), aof.Size())
+	}
+
+	records, err := replayAOF(filename, nil)
+	if err != nil {
+		t.Fatalf("ReplayAOF failed: %v", err)
+	}
+	if want := []string{`{"key":"a","n":2}`, `{"key":"b"}`}; !reflect.DeepEqual(records, want) {
+		t.Errorf("expected %v, got %v", want, records)
+	}
+	if _, err := os.Stat(filename + ".rewrite"); !os.IsNotExist(err) {
+		t.Error("expected no temporary file to be left behind")
+	}
+}
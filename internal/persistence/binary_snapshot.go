@@ -0,0 +1,599 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// SnapshotFormat selects how SaveStoreToFile encodes a snapshot
+type SnapshotFormat string
+
+const (
+	FormatJSON   SnapshotFormat = "json"   // a header line and a JSON object, see SaveToFile
+	FormatBinary SnapshotFormat = "binary" // length-prefixed records, see SnapshotWriter
+)
+
+// Compression selects how a binary snapshot is compressed
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+)
+
+// ParseSnapshotFormat validates a snapshot format name
+func ParseSnapshotFormat(name string) (SnapshotFormat, error) {
+	switch format := SnapshotFormat(name); format {
+	case FormatJSON, FormatBinary:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown snapshot format %q", name)
+	}
+}
+
+// ParseCompression validates a compression name
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(name); compression {
+	case CompressionNone, CompressionGzip:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", name)
+	}
+}
+
+// A binary snapshot starts with a plain header: binaryMagic, the format version, the
+// compression, the creation time in Unix milliseconds and, since version 2, the key ID
+// as a length byte and the ID, followed by the key check tag when the ID is not empty.
+// The records follow, compressed as a whole when compression is on and then encrypted
+// in chunks when a key ID is set. Every record is its length as a uvarint,
+// its payload and the CRC-32C of the payload. An entry record holds a key, its expiration
+// and its value; the final record holds the number of entries, so a file cut short is
+// told apart from a complete one.
+var binaryMagic = []byte("MEMSNAP")
+
+// BinarySnapshotVersion is the version of the binary snapshot format
+const BinarySnapshotVersion = 2
+
+const (
+	binaryHeaderSize = 7 + 1 + 1 + 8 // up to the key ID
+
+	// maxRecordSize bounds the length read from a damaged record prefix, which is not
+	// covered by the record checksum
+	maxRecordSize = 512 << 20
+
+	recordEntry byte = 'E'
+	recordEnd   byte = 'Z'
+)
+
+var compressionCodes = map[Compression]byte{CompressionNone: 0, CompressionGzip: 1}
+
+// Value tags of the binary encoding, the counterpart of the TaggedValue tags
+const (
+	binNil byte = iota
+	binFalse
+	binTrue
+	binInt
+	binInt64
+	binUint64
+	binFloat
+	binString
+	binBytes
+	binArray
+	binObject
+	binCollection
+)
+
+// SnapshotFormatOf reports the format of the snapshot at filename from its first bytes.
+func SnapshotFormatOf(filename string) (SnapshotFormat, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	prefix := make([]byte, len(binaryMagic))
+	n, _ := io.ReadFull(file, prefix)
+	if bytes.Equal(prefix[:n], binaryMagic) {
+		return FormatBinary, nil
+	}
+	return FormatJSON, nil
+}
+
+// SnapshotWriter streams entries into a binary snapshot
+type SnapshotWriter struct {
+	out     *bufio.Writer
+	entries int
+}
+
+// SnapshotBatch holds entries encoded for a binary snapshot. Encoding is most of the
+// work of saving an entry, so a batch lets the caller encode entries while it holds a
+// lock on them and write them once it has released it.
+type SnapshotBatch struct {
+	records bytes.Buffer
+	record  bytes.Buffer
+	entries int
+}
+
+// binaryHeader is the plain header of a binary snapshot
+type binaryHeader struct {
+	version     byte
+	compression byte
+	created     int64
+	keyID       string
+	keyCheck    []byte
+}
+
+func (h binaryHeader) write(w io.Writer) error {
+	buf := make([]byte, 0, binaryHeaderSize+1+len(h.keyID)+len(h.keyCheck))
+	buf = append(buf, binaryMagic...)
+	buf = append(buf, h.version, h.compression)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.created))
+	buf = append(buf, byte(len(h.keyID)))
+	buf = append(buf, h.keyID...)
+	buf = append(buf, h.keyCheck...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readBinaryHeader reads the header of the binary snapshot at filename from file
+func readBinaryHeader(filename string, file io.Reader) (binaryHeader, error) {
+	var h binaryHeader
+	buf := make([]byte, binaryHeaderSize)
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return h, fmt.Errorf("%w: %s has no complete header", ErrCorruptSnapshot, filename)
+	}
+	if !bytes.Equal(buf[:len(binaryMagic)], binaryMagic) {
+		return h, fmt.Errorf("%s is not a binary snapshot", filename)
+	}
+	h.version = buf[len(binaryMagic)]
+	h.compression = buf[len(binaryMagic)+1]
+	h.created = int64(binary.BigEndian.Uint64(buf[len(binaryMagic)+2:]))
+	if h.version < 1 || h.version > BinarySnapshotVersion {
+		return h, fmt.Errorf("unsupported binary snapshot version %d", h.version)
+	}
+	if h.version < 2 {
+		return h, nil
+	}
+
+	var length [1]byte
+	if _, err := io.ReadFull(file, length[:]); err != nil {
+		return h, fmt.Errorf("%w: %s has no complete header", ErrCorruptSnapshot, filename)
+	}
+	if length[0] == 0 {
+		return h, nil
+	}
+	key := make([]byte, int(length[0])+keyCheckSize)
+	if _, err := io.ReadFull(file, key); err != nil {
+		return h, fmt.Errorf("%w: %s has no complete header", ErrCorruptSnapshot, filename)
+	}
+	h.keyID, h.keyCheck = string(key[:length[0]]), key[length[0]:]
+	return h, nil
+}
+
+// SaveBinarySnapshot writes a binary snapshot to filename. write streams the entries
+// into the snapshot in batches; nothing is buffered beyond the current batch. Like
+// SaveToFile, the snapshot replaces filename atomically once it is complete, and it is
+// encrypted with the current key of keyring when keyring is set.
+func SaveBinarySnapshot(filename string, compression Compression, keyring *Keyring, write func(w *SnapshotWriter) error) error {
+	code, found := compressionCodes[compression]
+	if !found {
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
+	return writeAtomic(filename, func(file *os.File) error {
+		aead, id, check := keyring.currentCipher()
+		header := binaryHeader{
+			version:     BinarySnapshotVersion,
+			compression: code,
+			created:     time.Now().UnixMilli(),
+			keyID:       id,
+			keyCheck:    check,
+		}
+		if err := header.write(file); err != nil {
+			return err
+		}
+
+		var sink io.Writer = file
+		var sealed *sealWriter
+		if aead != nil {
+			var err error
+			if sealed, err = newSealWriter(file, aead); err != nil {
+				return err
+			}
+			sink = sealed
+		}
+		var zip *gzip.Writer
+		if compression == CompressionGzip {
+			zip = gzip.NewWriter(sink)
+			sink = zip
+		}
+		w := &SnapshotWriter{out: bufio.NewWriterSize(sink, 1<<16)}
+		if err := write(w); err != nil {
+			return err
+		}
+		if err := w.finish(); err != nil {
+			return err
+		}
+		if zip != nil {
+			if err := zip.Close(); err != nil {
+				return err
+			}
+		}
+		if sealed != nil {
+			return sealed.Close()
+		}
+		return nil
+	})
+}
+
+// Add encodes key with its value and expiration in Unix milliseconds. The value is
+// encoded with its type, like EncodeValue.
+func (b *SnapshotBatch) Add(key string, value interface{}, expiration int64) error {
+	b.record.Reset()
+	b.record.WriteByte(recordEntry)
+	writeString(&b.record, key)
+	b.record.Write(binary.AppendVarint(nil, expiration))
+	if err := writeValue(&b.record, value); err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	if b.record.Len() > maxRecordSize {
+		return fmt.Errorf("key %q: entry of %d bytes exceeds the limit of %d", key, b.record.Len(), maxRecordSize)
+	}
+	appendRecord(&b.records, b.record.Bytes())
+	b.entries++
+	return nil
+}
+
+// Size returns the number of bytes encoded in the batch
+func (b *SnapshotBatch) Size() int {
+	return b.records.Len()
+}
+
+// WriteBatch appends the entries of batch to the snapshot and empties the batch
+func (w *SnapshotWriter) WriteBatch(batch *SnapshotBatch) error {
+	_, err := w.out.Write(batch.records.Bytes())
+	w.entries += batch.entries
+	batch.records.Reset()
+	batch.entries = 0
+	return err
+}
+
+func (w *SnapshotWriter) finish() error {
+	var end, framed bytes.Buffer
+	end.WriteByte(recordEnd)
+	end.Write(binary.AppendUvarint(nil, uint64(w.entries)))
+	appendRecord(&framed, end.Bytes())
+	if _, err := w.out.Write(framed.Bytes()); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// appendRecord frames payload with its length and checksum
+func appendRecord(buf *bytes.Buffer, payload []byte) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(payload))))
+	buf.Write(payload)
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.Checksum(payload, crcTable)))
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	buf.WriteString(s)
+}
+
+func writeValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(binNil)
+	case bool:
+		if v {
+			buf.WriteByte(binTrue)
+		} else {
+			buf.WriteByte(binFalse)
+		}
+	case int:
+		buf.WriteByte(binInt)
+		buf.Write(binary.AppendVarint(nil, int64(v)))
+	case int8, int16, int32, int64:
+		buf.WriteByte(binInt64)
+		buf.Write(binary.AppendVarint(nil, reflect.ValueOf(v).Int()))
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		buf.WriteByte(binUint64)
+		buf.Write(binary.AppendUvarint(nil, reflect.ValueOf(v).Uint()))
+	case float32:
+		return writeValue(buf, float64(v))
+	case float64:
+		buf.WriteByte(binFloat)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case string:
+		buf.WriteByte(binString)
+		writeString(buf, v)
+	case []byte:
+		buf.WriteByte(binBytes)
+		writeString(buf, string(v))
+	case []interface{}:
+		buf.WriteByte(binArray)
+		buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		for _, item := range v {
+			if err := writeValue(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		buf.WriteByte(binObject)
+		buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		// Sorted so that equal contents encode to equal bytes
+		fields := make([]string, 0, len(v))
+		for field := range v {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			writeString(buf, field)
+			if err := writeValue(buf, v[field]); err != nil {
+				return err
+			}
+		}
+	default:
+		collection, found := collectionFor(value)
+		if !found {
+			return fmt.Errorf("cannot encode value of type %T", value)
+		}
+		contents, err := collection.Encode(value)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(binCollection)
+		writeString(buf, collection.Tag)
+		return writeValue(buf, contents)
+	}
+	return nil
+}
+
+// LoadBinarySnapshot streams the entries of the binary snapshot at filename into apply,
+// in the order they were saved. A damaged record is detected by its checksum before it
+// is applied; a damaged or truncated file returns ErrCorruptSnapshot, possibly after
+// some entries were applied, so the caller must discard them. Encrypted snapshots need
+// the key named in their header: ErrNoKey, ErrUnknownKey or ErrWrongKey is returned
+// before anything is applied when keyring does not have it, and ErrNotEncrypted when the
+// snapshot is not encrypted and keyring does not allow plaintext.
+func LoadBinarySnapshot(filename string, keyring *Keyring, apply func(key string, value interface{}, expiration int64) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := readBinaryHeader(filename, file)
+	if err != nil {
+		return err
+	}
+
+	var source io.Reader = file
+	if header.keyID != "" {
+		aead, err := keyring.cipher(header.keyID, header.keyCheck)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if source, err = newOpenReader(file, aead); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, filename, err)
+		}
+	} else if err := keyring.checkPlaintext(filename); err != nil {
+		return err
+	}
+	switch header.compression {
+	case compressionCodes[CompressionNone]:
+	case compressionCodes[CompressionGzip]:
+		zip, err := gzip.NewReader(source)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, filename, err)
+		}
+		defer zip.Close()
+		source = zip
+	default:
+		return fmt.Errorf("unsupported snapshot compression %d", header.compression)
+	}
+
+	in := bufio.NewReaderSize(source, 1<<16)
+	corrupt := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrCorruptSnapshot, filename, fmt.Sprintf(format, args...))
+	}
+	for entries := 0; ; entries++ {
+		length, err := binary.ReadUvarint(in)
+		if err != nil {
+			return corrupt("truncated after %d entries", entries)
+		}
+		if length == 0 || length > maxRecordSize {
+			return corrupt("bad record length %d after %d entries", length, entries)
+		}
+		// Read through a growing buffer rather than allocating length bytes upfront, so
+		// that a damaged length cannot allocate much more than the file holds.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, in, int64(length)+4); err != nil {
+			return corrupt("truncated after %d entries", entries)
+		}
+		record := buf.Bytes()
+		payload := record[:length]
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(record[length:]) {
+			return corrupt("bad checksum in record %d", entries)
+		}
+
+		r := bytes.NewReader(payload[1:])
+		switch payload[0] {
+		case recordEntry:
+			key, err := readString(r)
+			if err != nil {
+				return corrupt("record %d: %v", entries, err)
+			}
+			expiration, err := binary.ReadVarint(r)
+			if err != nil {
+				return corrupt("record %d: %v", entries, err)
+			}
+			value, err := readValue(r)
+			if err != nil {
+				return corrupt("key %q: %v", key, err)
+			}
+			if err := apply(key, value, expiration); err != nil {
+				return err
+			}
+		case recordEnd:
+			count, err := binary.ReadUvarint(r)
+			if err != nil || count != uint64(entries) {
+				return corrupt("holds %d entries, end record says %d", entries, count)
+			}
+			return nil
+		default:
+			return corrupt("unknown record type %q", payload[0])
+		}
+	}
+}
+
+var errShortValue = errors.New("value cut short")
+
+func readString(r *bytes.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil || length > uint64(r.Len()) {
+		return "", errShortValue
+	}
+	s := make([]byte, length)
+	r.Read(s)
+	return string(s), nil
+}
+
+func readValue(r *bytes.Reader) (interface{}, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, errShortValue
+	}
+	switch tag {
+	case binNil:
+		return nil, nil
+	case binFalse:
+		return false, nil
+	case binTrue:
+		return true, nil
+	case binInt:
+		n, err := binary.ReadVarint(r)
+		return int(n), err
+	case binInt64:
+		return binary.ReadVarint(r)
+	case binUint64:
+		return binary.ReadUvarint(r)
+	case binFloat:
+		var bits [8]byte
+		if _, err := io.ReadFull(r, bits[:]); err != nil {
+			return nil, errShortValue
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(bits[:])), nil
+	case binString:
+		return readString(r)
+	case binBytes:
+		s, err := readString(r)
+		return []byte(s), err
+	case binArray:
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return nil, errShortValue
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case binObject:
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return nil, errShortValue
+		}
+		fields := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			field, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			if fields[field], err = readValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	case binCollection:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		collection, found := collectionsByTag[name]
+		if !found {
+			return nil, fmt.Errorf("unknown value type %q", name)
+		}
+		contents, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return collection.Decode(contents)
+	default:
+		return nil, fmt.Errorf("unknown value tag %d", tag)
+	}
+}
+
+// reencryptBinarySnapshot copies the records of a binary snapshot, still compressed,
+// into a new snapshot encrypted with the current key of keyring.
+func reencryptBinarySnapshot(filename string, keyring *Keyring) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := readBinaryHeader(filename, file)
+	if err != nil {
+		return err
+	}
+	if header.version < 2 {
+		return fmt.Errorf("%s is a version %d binary snapshot; load and save it once before encrypting it", filename, header.version)
+	}
+	var source io.Reader = file
+	if header.keyID != "" {
+		aead, err := keyring.cipher(header.keyID, header.keyCheck)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if source, err = newOpenReader(file, aead); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, filename, err)
+		}
+	}
+
+	return writeAtomic(filename, func(out *os.File) error {
+		aead, id, check := keyring.currentCipher()
+		header.keyID, header.keyCheck = id, check
+		if err := header.write(out); err != nil {
+			return err
+		}
+		sealed, err := newSealWriter(out, aead)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(sealed, source); err != nil {
+			if err == errDamagedStream {
+				return fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, filename, err)
+			}
+			return err
+		}
+		return sealed.Close()
+	})
+}
@@ -0,0 +1,114 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type binaryEntry struct {
+	value      interface{}
+	expiration int64
+}
+
+// saveBinary writes entries to a binary snapshot, one batch per entry
+func saveBinary(filename string, compression Compression, keyring *Keyring, entries map[string]binaryEntry) error {
+	return SaveBinarySnapshot(filename, compression, keyring, func(w *SnapshotWriter) error {
+		var batch SnapshotBatch
+		for key, entry := range entries {
+			if err := batch.Add(key, entry.value, entry.expiration); err != nil {
+				return err
+			}
+			if err := w.WriteBatch(&batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadBinary reads the entries of a binary snapshot
+func loadBinary(filename string, keyring *Keyring) (map[string]binaryEntry, error) {
+	entries := make(map[string]binaryEntry)
+	err := LoadBinarySnapshot(filename, keyring, func(key string, value interface{}, expiration int64) error {
+		entries[key] = binaryEntry{value, expiration}
+		return nil
+	})
+	return entries, err
+}
+
+func TestBinarySnapshotPreservesValueTypes(t *testing.T) {
+	entries := map[string]binaryEntry{
+		"counter": {int64(1) << 60, 0},
+		"session": {"token", 1700000000000},
+		"blob":    {[]byte{0, 1, 0xff}, 0},
+		"nested":  {map[string]interface{}{"n": 7, "items": []interface{}{uint64(1), "two", nil, true}}, 0},
+		"members": {testSet{"a": {}, "b": {}}, 0},
+		"large":   {strings.Repeat("v", 200<<10), 0},
+	}
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		t.Run(string(compression), func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "dump.bin")
+			if err := saveBinary(filename, compression, nil, entries); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if format, err := SnapshotFormatOf(filename); err != nil || format != FormatBinary {
+				t.Errorf("expected a binary snapshot, got %q (%v)", format, err)
+			}
+			loaded, err := loadBinary(filename, nil)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, entries) {
+				t.Errorf("expected %v, got %v", entries, loaded)
+			}
+		})
+	}
+}
+
+func TestBinarySnapshotRejectsDamage(t *testing.T) {
+	entries := make(map[string]binaryEntry)
+	for i := 0; i < 100; i++ {
+		entries[fmt.Sprintf("key:%d", i)] = binaryEntry{strings.Repeat("v", 20), 0}
+	}
+	filename := filepath.Join(t.TempDir(), "dump.bin")
+	if err := saveBinary(filename, CompressionNone, nil, entries); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	content, _ := os.ReadFile(filename)
+
+	damaged := append([]byte{}, content...)
+	damaged[len(damaged)/2] ^= 0xff
+	for name, data := range map[string][]byte{"flipped byte": damaged, "truncated": content[:len(content)-20]} {
+		os.WriteFile(filename, data, 0o644)
+		if _, err := loadBinary(filename, nil); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("%s: expected ErrCorruptSnapshot, got %v", name, err)
+		}
+	}
+}
+
+func TestBinarySnapshotRejectsUnknownValues(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump.bin")
+	if err := saveBinary(filename, CompressionNone, nil, map[string]binaryEntry{"bad": {struct{}{}, 0}}); err == nil {
+		t.Error("expected an unregistered type to be refused")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("expected a failed save to leave no snapshot")
+	}
+}
+
+func TestParseSnapshotOptions(t *testing.T) {
+	if _, err := ParseSnapshotFormat("xml"); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+	if _, err := ParseCompression("zstd"); err == nil {
+		t.Error("expected an unknown compression to be refused")
+	}
+	if format, err := ParseSnapshotFormat("binary"); err != nil || format != FormatBinary {
+		t.Errorf("expected the binary format, got %q (%v)", format, err)
+	}
+}
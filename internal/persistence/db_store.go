@@ -1,17 +1,23 @@
 package persistence
 
 import (
-	"time"
+	"encoding/json"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// DBEntry is a key as saved in the database. Type and Payload hold its value in the
+// tagged encoding of EncodeValue, so every value type is restored exactly.
 type DBEntry struct {
 	Key        string `gorm:"primaryKey"`
-	Value      string
-	Expiration int64
+	Type       string
+	Payload    string `gorm:"type:text"`
+	Expiration int64  // Unix milliseconds; 0 means the key does not expire
+
+	// Value holds rows saved before values were tagged, which were all strings
+	Value string
 }
 
 var db *gorm.DB
@@ -35,40 +41,59 @@ func InitDB(dsn string, dbType string) error {
 	return db.AutoMigrate(&DBEntry{})
 }
 
-// SaveToDB persists the current state of the store to the database.
-func SaveToDB(data map[string]interface{}) error {
+// SaveToDB replaces the contents of the database with entries, in one transaction, so a
+// failed save leaves the previous state intact.
+func SaveToDB(entries map[string]SnapshotEntry) error {
 	if db == nil {
 		return nil
 	}
 
-	for key, value := range data {
-		dbEntry := DBEntry{
+	rows := make([]DBEntry, 0, len(entries))
+	for key, entry := range entries {
+		rows = append(rows, DBEntry{
 			Key:        key,
-			Value:      value.(string),
-			Expiration: time.Now().Add(24 * time.Hour).Unix(),
-		}
-		db.Save(&dbEntry)
+			Type:       entry.Value.Type,
+			Payload:    string(entry.Value.Value),
+			Expiration: entry.Expiration,
+		})
 	}
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&DBEntry{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
 }
 
-// LoadFromDB loads data from the database to the in-memory store.
-func LoadFromDB() (map[string]interface{}, error) {
+// LoadFromDB loads the entries saved in the database, with their values in the tagged
+// encoding. Rows saved before values were tagged are returned as strings without an
+// expiration: those rows were all saved with one a day ahead of the save, which never
+// was a TTL of the key.
+func LoadFromDB() (map[string]SnapshotEntry, error) {
 	if db == nil {
 		return nil, nil
 	}
 
-	var entries []DBEntry
-	result := db.Find(&entries)
+	var rows []DBEntry
+	result := db.Find(&rows)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	data := make(map[string]interface{})
-	for _, entry := range entries {
-		if entry.Expiration == 0 || entry.Expiration > time.Now().Unix() {
-			data[entry.Key] = entry.Value
+	entries := make(map[string]SnapshotEntry, len(rows))
+	for _, row := range rows {
+		entry := SnapshotEntry{Value: TaggedValue{Type: row.Type, Value: json.RawMessage(row.Payload)}, Expiration: row.Expiration}
+		if row.Type == "" {
+			legacy, err := EncodeValue(row.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry = SnapshotEntry{Value: legacy}
 		}
+		entries[row.Key] = entry
 	}
-	return data, nil
+	return entries, nil
 }
@@ -0,0 +1,64 @@
+package persistence
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDBPreservesValuesAndExpiration(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "store.db"), "sqlite"); err != nil {
+		t.Skipf("sqlite is not available: %v", err)
+	}
+	defer func() { db = nil }()
+
+	entries := testEntries(t)
+	stale, _ := EncodeValue("x")
+	entries["stale"] = SnapshotEntry{Value: stale}
+	if err := SaveToDB(entries); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A key deleted since the last save must not come back
+	delete(entries, "stale")
+	if err := SaveToDB(entries); err != nil {
+		t.Fatalf("Second save failed: %v", err)
+	}
+
+	loaded, err := LoadFromDB()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, entries) {
+		t.Errorf("expected %v, got %v", entries, loaded)
+	}
+}
+
+func TestDBLoadsLegacyRowsWithoutExpiration(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "store.db"), "sqlite"); err != nil {
+		t.Skipf("sqlite is not available: %v", err)
+	}
+	defer func() { db = nil }()
+
+	// Rows written before values were tagged, with the expiration in Unix seconds that
+	// every key got a day ahead of the save, or that has passed since.
+	db.Create([]DBEntry{
+		{Key: "recent", Value: "a", Expiration: time.Now().Add(24 * time.Hour).Unix()},
+		{Key: "old", Value: "b", Expiration: time.Now().Add(-time.Hour).Unix()},
+	})
+
+	loaded, err := LoadFromDB()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for key, want := range map[string]string{"recent": "a", "old": "b"} {
+		entry := loaded[key]
+		if value, _ := DecodeValue(entry.Value); value != want {
+			t.Errorf("%s: expected %q, got %#v", key, want, value)
+		}
+		if entry.Expiration != 0 {
+			t.Errorf("%s: expected no expiration, got %d", key, entry.Expiration)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// TaggedValue is the type-preserving encoding of a value: a tag naming its type and
+// the JSON encoding of its contents. Integers, binary blobs and the values nested in
+// arrays, objects and collections decode to exactly the types they were encoded from,
+// where plain JSON would turn numbers into float64 and blobs into strings.
+type TaggedValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// Tags of the values known to this package. Integer types other than int, int64 and
+// uint64 are encoded as int64 or uint64, and float32 as float.
+const (
+	tagNil    = "nil"
+	tagBool   = "bool"
+	tagInt    = "int"
+	tagInt64  = "int64"
+	tagUint64 = "uint64"
+	tagFloat  = "float"
+	tagString = "string"
+	tagBytes  = "bytes"
+	tagArray  = "array"
+	tagObject = "object"
+)
+
+// Collection encodes values of a type this package does not know through the plain
+// values they hold. Encode returns the contents of a value, which are encoded in turn,
+// and Decode rebuilds the value from the decoded contents.
+type Collection struct {
+	Tag    string
+	Encode func(value interface{}) (interface{}, error)
+	Decode func(contents interface{}) (interface{}, error)
+}
+
+var (
+	collectionsByType = make(map[reflect.Type]Collection)
+	collectionsByTag  = make(map[string]Collection)
+)
+
+// RegisterCollection makes EncodeValue and DecodeValue handle values of the same type as
+// example. It is meant to be called from init functions, before any value is encoded.
+func RegisterCollection(example interface{}, collection Collection) {
+	collectionsByType[reflect.TypeOf(example)] = collection
+	collectionsByTag[collection.Tag] = collection
+}
+
+// collectionFor returns the registered collection for the type of value
+func collectionFor(value interface{}) (Collection, bool) {
+	collection, found := collectionsByType[reflect.TypeOf(value)]
+	return collection, found
+}
+
+// DecodeCollection rebuilds a value of the collection registered under tag from contents
+// in the form its Decode takes, for values saved before they were tagged.
+func DecodeCollection(tag string, contents interface{}) (interface{}, error) {
+	collection, found := collectionsByTag[tag]
+	if !found {
+		return nil, fmt.Errorf("unknown value type %q", tag)
+	}
+	return collection.Decode(contents)
+}
+
+// EncodeValue encodes value with its type. It fails for types that are neither known to
+// this package nor registered with RegisterCollection.
+func EncodeValue(value interface{}) (TaggedValue, error) {
+	var tag string
+	var contents interface{}
+	switch v := value.(type) {
+	case nil:
+		return TaggedValue{Type: tagNil}, nil
+	case bool:
+		tag, contents = tagBool, v
+	case int:
+		tag, contents = tagInt, v
+	case int8, int16, int32, int64:
+		tag, contents = tagInt64, reflect.ValueOf(v).Int()
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		tag, contents = tagUint64, reflect.ValueOf(v).Uint()
+	case float32:
+		tag, contents = tagFloat, float64(v)
+	case float64:
+		tag, contents = tagFloat, v
+	case string:
+		tag, contents = tagString, v
+	case []byte:
+		tag, contents = tagBytes, v
+	case []interface{}:
+		items := make([]TaggedValue, len(v))
+		for i, item := range v {
+			encoded, err := EncodeValue(item)
+			if err != nil {
+				return TaggedValue{}, err
+			}
+			items[i] = encoded
+		}
+		tag, contents = tagArray, items
+	case map[string]interface{}:
+		fields := make(map[string]TaggedValue, len(v))
+		for field, item := range v {
+			encoded, err := EncodeValue(item)
+			if err != nil {
+				return TaggedValue{}, err
+			}
+			fields[field] = encoded
+		}
+		tag, contents = tagObject, fields
+	default:
+		collection, found := collectionFor(value)
+		if !found {
+			return TaggedValue{}, fmt.Errorf("cannot encode value of type %T", value)
+		}
+		inner, err := collection.Encode(value)
+		if err != nil {
+			return TaggedValue{}, err
+		}
+		encoded, err := EncodeValue(inner)
+		if err != nil {
+			return TaggedValue{}, err
+		}
+		tag, contents = collection.Tag, encoded
+	}
+
+	raw, err := json.Marshal(contents)
+	if err != nil {
+		return TaggedValue{}, fmt.Errorf("cannot encode %s value: %w", tag, err)
+	}
+	return TaggedValue{Type: tag, Value: raw}, nil
+}
+
+// DecodeValue rebuilds a value encoded by EncodeValue.
+func DecodeValue(tagged TaggedValue) (interface{}, error) {
+	var err error
+	switch tagged.Type {
+	case tagNil:
+		return nil, nil
+	case tagBool:
+		var v bool
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagInt:
+		var v int
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagInt64:
+		var v int64
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagUint64:
+		var v uint64
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagFloat:
+		var v float64
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagString:
+		var v string
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagBytes:
+		var v []byte
+		err = json.Unmarshal(tagged.Value, &v)
+		return v, err
+	case tagArray:
+		var items []TaggedValue
+		if err := json.Unmarshal(tagged.Value, &items); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			if values[i], err = DecodeValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case tagObject:
+		var fields map[string]TaggedValue
+		if err := json.Unmarshal(tagged.Value, &fields); err != nil {
+			return nil, err
+		}
+		values := make(map[string]interface{}, len(fields))
+		for field, item := range fields {
+			if values[field], err = DecodeValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	collection, found := collectionsByTag[tagged.Type]
+	if !found {
+		return nil, fmt.Errorf("unknown value type %q", tagged.Type)
+	}
+	var inner TaggedValue
+	if err := json.Unmarshal(tagged.Value, &inner); err != nil {
+		return nil, err
+	}
+	contents, err := DecodeValue(inner)
+	if err != nil {
+		return nil, err
+	}
+	return collection.Decode(contents)
+}
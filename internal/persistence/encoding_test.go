@@ -0,0 +1,93 @@
+package persistence
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testSet is a collection type registered by the tests
+type testSet map[string]struct{}
+
+func init() {
+	RegisterCollection(testSet{}, Collection{
+		Tag: "test-set",
+		Encode: func(value interface{}) (interface{}, error) {
+			members := make([]interface{}, 0, len(value.(testSet)))
+			for member := range value.(testSet) {
+				members = append(members, member)
+			}
+			sort.Slice(members, func(i, j int) bool { return members[i].(string) < members[j].(string) })
+			return members, nil
+		},
+		Decode: func(contents interface{}) (interface{}, error) {
+			set := make(testSet)
+			for _, member := range contents.([]interface{}) {
+				set[member.(string)] = struct{}{}
+			}
+			return set, nil
+		},
+	})
+}
+
+func TestEncodeValuePreservesTypes(t *testing.T) {
+	for _, value := range []interface{}{
+		nil,
+		true,
+		7,
+		int64(1) << 60,
+		uint64(1) << 63,
+		2.5,
+		"text",
+		[]byte{0, 1, 0xff},
+		[]interface{}{int64(1), "two", nil, []byte("three")},
+		map[string]interface{}{"n": 7, "nested": map[string]interface{}{"f": 1.5}},
+		testSet{"a": {}, "b": {}},
+	} {
+		tagged, err := EncodeValue(value)
+		if err != nil {
+			t.Fatalf("EncodeValue(%#v) failed: %v", value, err)
+		}
+		// Round trip through JSON, as snapshots and the database store it
+		raw, _ := json.Marshal(tagged)
+		var stored TaggedValue
+		json.Unmarshal(raw, &stored)
+
+		decoded, err := DecodeValue(stored)
+		if err != nil {
+			t.Fatalf("DecodeValue(%s) failed: %v", raw, err)
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Errorf("expected %#v, got %#v", value, decoded)
+		}
+	}
+}
+
+func TestEncodeValueWidensIntegers(t *testing.T) {
+	for value, want := range map[interface{}]interface{}{int32(-3): int64(-3), uint8(3): uint64(3), float32(0.5): 0.5} {
+		tagged, _ := EncodeValue(value)
+		if decoded, _ := DecodeValue(tagged); decoded != want {
+			t.Errorf("%T: expected %#v, got %#v", value, want, decoded)
+		}
+	}
+}
+
+func TestEncodeValueRejectsUnknownTypes(t *testing.T) {
+	if _, err := EncodeValue(struct{}{}); err == nil {
+		t.Error("expected an unregistered type to be refused")
+	}
+	if _, err := DecodeValue(TaggedValue{Type: "unknown", Value: json.RawMessage("1")}); err == nil {
+		t.Error("expected an unknown tag to be refused")
+	}
+}
+
+func TestDecodeCollectionUsesRegisteredDecoder(t *testing.T) {
+	value, err := DecodeCollection("test-set", []interface{}{"a"})
+	if err != nil || !reflect.DeepEqual(value, testSet{"a": {}}) {
+		t.Errorf("expected the decoded set, got %#v (%v)", value, err)
+	}
+	if _, err := DecodeCollection("unknown", nil); err == nil {
+		t.Error("expected an unknown tag to be refused")
+	}
+}
@@ -0,0 +1,342 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNoKey is returned when reading an encrypted file without a keyring
+	ErrNoKey = errors.New("file is encrypted but no encryption key is configured")
+
+	// ErrUnknownKey is returned when a file is encrypted with a key ID missing from the keyring
+	ErrUnknownKey = errors.New("file is encrypted with a key that is not configured")
+
+	// ErrWrongKey is returned when the key configured under a file's key ID is not the one
+	// the file was encrypted with
+	ErrWrongKey = errors.New("wrong encryption key")
+
+	// ErrNotEncrypted is returned when reading an unencrypted file with a keyring that does
+	// not allow plaintext
+	ErrNotEncrypted = errors.New("file is not encrypted but an encryption key is configured")
+)
+
+const (
+	// keyCheckSize is the length of the tag stored with a key ID to verify the key
+	keyCheckSize = 16
+
+	// sealedChunkSize is the plaintext length of every chunk of an encrypted stream but the last
+	sealedChunkSize = 64 << 10
+)
+
+// Keyring holds the AES keys used to encrypt files at rest, by key ID. The current key
+// encrypts new files; any key in the ring decrypts the files written with it, so old
+// keys stay in the ring until every file has been rewritten with the new one.
+type Keyring struct {
+	current        string
+	ciphers        map[string]cipher.AEAD
+	allowPlaintext bool
+}
+
+// NewKeyring returns an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{ciphers: make(map[string]cipher.AEAD)}
+}
+
+// Add puts an AES-128, AES-192 or AES-256 key in the ring under id and makes it current.
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 || strings.ContainsAny(id, " \t\r\n,:") {
+		return fmt.Errorf("invalid key ID %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+	k.ciphers[id] = aead
+	k.current = id
+	return nil
+}
+
+// Use makes the key with id current
+func (k *Keyring) Use(id string) error {
+	if _, found := k.ciphers[id]; !found {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	k.current = id
+	return nil
+}
+
+// AllowPlaintext lets the keyring read files that are not encrypted, as written before
+// encryption was enabled, logging each one. Without it they are refused with
+// ErrNotEncrypted, so that a replaced or planted file cannot be loaded unnoticed.
+func (k *Keyring) AllowPlaintext(allow bool) {
+	k.allowPlaintext = allow
+}
+
+// CurrentID returns the ID of the key that encrypts new files
+func (k *Keyring) CurrentID() string {
+	return k.current
+}
+
+// ParseKeyring reads keys written as id:base64key, separated by commas or new lines.
+// Blank lines and lines starting with # are skipped. The last key is current. An empty
+// spec returns a nil keyring, which leaves files unencrypted.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := NewKeyring()
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		id, encoded, found := strings.Cut(item, ":")
+		if !found {
+			return nil, fmt.Errorf("key %q must be written as id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if err := k.Add(strings.TrimSpace(id), key); err != nil {
+			return nil, err
+		}
+	}
+	if len(k.ciphers) == 0 {
+		return nil, nil
+	}
+	return k, nil
+}
+
+// LoadKeyring combines the keys in spec with those in keyFile, in the format of
+// ParseKeyring, and makes current the current key when it is set. It returns a nil
+// keyring when no key is configured.
+func LoadKeyring(spec, keyFile, current string) (*Keyring, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		spec += "\n" + string(data)
+	}
+	k, err := ParseKeyring(spec)
+	if err != nil || k == nil {
+		if err == nil && current != "" {
+			err = fmt.Errorf("%w: %q", ErrUnknownKey, current)
+		}
+		return nil, err
+	}
+	if current != "" {
+		if err := k.Use(current); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// checkPlaintext reports whether the unencrypted file filename may be read with the
+// keyring. Any file may be read without a keyring.
+func (k *Keyring) checkPlaintext(filename string) error {
+	if k == nil {
+		return nil
+	}
+	if !k.allowPlaintext {
+		return fmt.Errorf("%w: %s", ErrNotEncrypted, filename)
+	}
+	log.Printf("Reading unencrypted %s although encryption is enabled", filename)
+	return nil
+}
+
+// migrating returns a copy of the keyring that reads unencrypted files, for re-encrypting them
+func (k *Keyring) migrating() *Keyring {
+	copy := *k
+	copy.allowPlaintext = true
+	return &copy
+}
+
+// cipher returns the key with id, checked against the tag stored with the file.
+func (k *Keyring) cipher(id string, check []byte) (cipher.AEAD, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w (key %q)", ErrNoKey, id)
+	}
+	aead, found := k.ciphers[id]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	if !bytes.Equal(keyCheck(aead, id), check) {
+		return nil, fmt.Errorf("%w: the key configured as %q did not encrypt this file", ErrWrongKey, id)
+	}
+	return aead, nil
+}
+
+// currentCipher returns the current key, its ID and its check tag, or a nil cipher for a
+// nil keyring.
+func (k *Keyring) currentCipher() (cipher.AEAD, string, []byte) {
+	if k == nil {
+		return nil, "", nil
+	}
+	aead := k.ciphers[k.current]
+	return aead, k.current, keyCheck(aead, k.current)
+}
+
+// keyCheck authenticates the key ID with the key itself. Its plaintext is always empty, so
+// the fixed nonce reveals nothing; stored next to the ID, it tells a wrong key apart from
+// damaged data.
+func keyCheck(aead cipher.AEAD, id string) []byte {
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), nil, []byte("key-check:"+id))
+}
+
+// seal encrypts one record under a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plaintext []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// open decrypts a record sealed by seal
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed record too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+// sealWriter encrypts a stream in chunks of sealedChunkSize. Every chunk is framed as a
+// final flag, its sealed length and the sealed chunk; the nonce is a random per-stream
+// prefix followed by the chunk number, and the flag is authenticated, so chunks cannot be
+// reordered, dropped or cut off at the end without failing decryption.
+type sealWriter struct {
+	aead    cipher.AEAD
+	out     io.Writer
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+func newSealWriter(out io.Writer, aead cipher.AEAD) (*sealWriter, error) {
+	prefix := make([]byte, aead.NonceSize()-4)
+	rand.Read(prefix)
+	if _, err := out.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &sealWriter{aead: aead, out: out, prefix: prefix}, nil
+}
+
+// Write seals every chunk that is complete and followed by more data, straight from p
+// once the chunk buffered by the previous call is full. The last chunk stays buffered
+// until Close seals it as the final one.
+func (w *sealWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(w.buf) > 0 {
+		fill := min(sealedChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:fill]...)
+		p = p[fill:]
+		if len(p) == 0 {
+			return n, nil
+		}
+		if err := w.seal(w.buf, false); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[:0]
+	}
+	for len(p) > sealedChunkSize {
+		if err := w.seal(p[:sealedChunkSize], false); err != nil {
+			return 0, err
+		}
+		p = p[sealedChunkSize:]
+	}
+	w.buf = append(w.buf, p...)
+	return n, nil
+}
+
+// Close seals the final chunk; it does not close the underlying writer.
+func (w *sealWriter) Close() error {
+	return w.seal(w.buf, true)
+}
+
+func (w *sealWriter) seal(chunk []byte, final bool) error {
+	flag := []byte{0}
+	if final {
+		flag[0] = 1
+	}
+	sealed := w.aead.Seal(nil, w.nonce(), chunk, flag)
+	w.counter++
+
+	frame := append(flag, binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))...)
+	if _, err := w.out.Write(append(frame, sealed...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *sealWriter) nonce() []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, w.prefix...), w.counter)
+}
+
+// openReader decrypts a stream written by sealWriter. Damaged or truncated data fails
+// with errDamagedStream.
+type openReader struct {
+	sealWriter // nonce state
+	in         io.Reader
+	plain      []byte
+	done       bool
+}
+
+var errDamagedStream = errors.New("encrypted data is damaged or truncated")
+
+func newOpenReader(in io.Reader, aead cipher.AEAD) (*openReader, error) {
+	prefix := make([]byte, aead.NonceSize()-4)
+	if _, err := io.ReadFull(in, prefix); err != nil {
+		return nil, errDamagedStream
+	}
+	return &openReader{sealWriter: sealWriter{aead: aead, prefix: prefix}, in: in}, nil
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *openReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r.in, header); err != nil {
+		return errDamagedStream
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > sealedChunkSize+uint32(r.aead.Overhead()) {
+		return errDamagedStream
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r.in, sealed); err != nil {
+		return errDamagedStream
+	}
+	plain, err := r.aead.Open(nil, r.nonce(), sealed, header[:1])
+	if err != nil {
+		return errDamagedStream
+	}
+	r.counter++
+	r.plain = plain
+	r.done = header[0] == 1
+	return nil
+}
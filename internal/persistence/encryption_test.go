@@ -0,0 +1,240 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testKeyring parses keys written as id:seed, where every byte of the 32-byte key is seed.
+func testKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	var spec []string
+	for _, key := range keys {
+		id, seed, _ := strings.Cut(key, ":")
+		spec = append(spec, id+":"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(seed), 32)))
+	}
+	keyring, err := ParseKeyring(strings.Join(spec, ","))
+	if err != nil {
+		t.Fatalf("ParseKeyring failed: %v", err)
+	}
+	return keyring
+}
+
+// saveSnapshot writes entries as a snapshot of format, encrypted with keyring
+func saveSnapshot(t *testing.T, filename string, format SnapshotFormat, keyring *Keyring, entries map[string]binaryEntry) {
+	t.Helper()
+	var err error
+	if format == FormatBinary {
+		err = saveBinary(filename, CompressionGzip, keyring, entries)
+	} else {
+		data := make(map[string]SnapshotEntry)
+		for key, entry := range entries {
+			tagged, _ := EncodeValue(entry.value)
+			data[key] = SnapshotEntry{Value: tagged, Expiration: entry.expiration}
+		}
+		err = SaveToFile(filename, data, len(data), keyring)
+	}
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+// loadSnapshot reads the entries of a snapshot of format
+func loadSnapshot(filename string, format SnapshotFormat, keyring *Keyring) (map[string]binaryEntry, error) {
+	if format == FormatBinary {
+		return loadBinary(filename, keyring)
+	}
+	var data map[string]SnapshotEntry
+	if _, err := LoadFromFile(filename, &data, keyring); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]binaryEntry)
+	for key, entry := range data {
+		value, err := DecodeValue(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = binaryEntry{value, entry.Expiration}
+	}
+	return entries, nil
+}
+
+func TestEncryptedSnapshotNeedsItsKey(t *testing.T) {
+	entries := map[string]binaryEntry{"greeting": {"top secret", 0}}
+	for _, format := range []SnapshotFormat{FormatJSON, FormatBinary} {
+		t.Run(string(format), func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "data.snapshot")
+			saveSnapshot(t, filename, format, testKeyring(t, "k1:a"), entries)
+			if content, _ := os.ReadFile(filename); bytes.Contains(content, []byte("top secret")) {
+				t.Error("expected the snapshot to be encrypted")
+			}
+
+			for _, tc := range []struct {
+				keyring *Keyring
+				err     error
+			}{
+				{nil, ErrNoKey},
+				{testKeyring(t, "k2:a"), ErrUnknownKey},
+				{testKeyring(t, "k1:b"), ErrWrongKey},
+			} {
+				if _, err := loadSnapshot(filename, format, tc.keyring); !errors.Is(err, tc.err) {
+					t.Errorf("expected %v, got %v", tc.err, err)
+				}
+			}
+
+			loaded, err := loadSnapshot(filename, format, testKeyring(t, "k1:a", "k2:b"))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, entries) {
+				t.Errorf("expected %v, got %v", entries, loaded)
+			}
+		})
+	}
+}
+
+func TestSealedStreamSpansManyChunks(t *testing.T) {
+	aead, _, _ := testKeyring(t, "k1:a").currentCipher()
+	for _, size := range []int{0, 1, sealedChunkSize, 3*sealedChunkSize + 17} {
+		plain := bytes.Repeat([]byte("0123456789"), size/10+1)[:size]
+		var sealed bytes.Buffer
+		w, _ := newSealWriter(&sealed, aead)
+		// Odd write sizes, so chunks are filled across calls
+		for rest := plain; len(rest) > 0; {
+			n := min(len(rest), 1000+sealedChunkSize/3)
+			w.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		r, err := newOpenReader(bytes.NewReader(sealed.Bytes()), aead)
+		if err != nil {
+			t.Fatalf("%d bytes: newOpenReader failed: %v", size, err)
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("%d bytes: expected the plaintext back, got %d bytes (%v)", size, len(got), err)
+		}
+
+		// Cutting the stream at a chunk boundary must not pass for its end
+		if size > sealedChunkSize {
+			cut := sealed.Len() - (sealedChunkSize + aead.Overhead() + 5)
+			r, _ := newOpenReader(bytes.NewReader(sealed.Bytes()[:cut]), aead)
+			if _, err := io.ReadAll(r); !errors.Is(err, errDamagedStream) {
+				t.Errorf("%d bytes: expected a truncated stream to fail, got %v", size, err)
+			}
+		}
+	}
+}
+
+func TestEncryptedAOFFollowsKeyRotation(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	writeAOF(t, filename, testKeyring(t, "k1:a"), `{"key":"first"}`)
+	if content, _ := os.ReadFile(filename); bytes.Contains(content, []byte("first")) {
+		t.Error("expected the log to be encrypted")
+	}
+	// Rotate: k2 becomes current while k1 still decrypts the older records
+	writeAOF(t, filename, testKeyring(t, "k1:a", "k2:b"), `{"key":"second"}`)
+
+	if _, err := replayAOF(filename, testKeyring(t, "k2:b")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey while k1 records remain, got %v", err)
+	}
+	if _, err := replayAOF(filename, testKeyring(t, "k1:c", "k2:b")); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey, got %v", err)
+	}
+
+	if err := ReencryptAOF(filename, testKeyring(t, "k1:a", "k2:b")); err != nil {
+		t.Fatalf("ReencryptAOF failed: %v", err)
+	}
+	records, err := replayAOF(filename, testKeyring(t, "k2:b"))
+	if err != nil {
+		t.Fatalf("ReplayAOF with the new key alone failed: %v", err)
+	}
+	if want := []string{`{"key":"first"}`, `{"key":"second"}`}; !reflect.DeepEqual(records, want) {
+		t.Errorf("expected %v, got %v", want, records)
+	}
+}
+
+func TestReencryptSnapshot(t *testing.T) {
+	entries := map[string]binaryEntry{"greeting": {"hello", 0}}
+	for _, format := range []SnapshotFormat{FormatJSON, FormatBinary} {
+		t.Run(string(format), func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "data.snapshot")
+			saveSnapshot(t, filename, format, nil, entries)
+
+			if err := ReencryptSnapshot(filename, testKeyring(t, "k1:a")); err != nil {
+				t.Fatalf("encrypting failed: %v", err)
+			}
+			if err := ReencryptSnapshot(filename, testKeyring(t, "k1:a", "k2:b")); err != nil {
+				t.Fatalf("re-encrypting failed: %v", err)
+			}
+			loaded, err := loadSnapshot(filename, format, testKeyring(t, "k2:b"))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, entries) {
+				t.Errorf("expected %v, got %v", entries, loaded)
+			}
+		})
+	}
+}
+
+func TestUnencryptedFilesNeedPlaintextAllowed(t *testing.T) {
+	dir := t.TempDir()
+	entries := map[string]binaryEntry{"greeting": {"hello", 0}}
+	for _, format := range []SnapshotFormat{FormatJSON, FormatBinary} {
+		saveSnapshot(t, filepath.Join(dir, string(format)), format, nil, entries)
+	}
+	writeAOF(t, filepath.Join(dir, "appendonly.aof"), nil, `{"key":"greeting"}`)
+
+	load := func(name string, keyring *Keyring) error {
+		filename := filepath.Join(dir, name)
+		if name == "appendonly.aof" {
+			_, err := replayAOF(filename, keyring)
+			return err
+		}
+		_, err := loadSnapshot(filename, SnapshotFormat(name), keyring)
+		return err
+	}
+	for _, name := range []string{"json", "binary", "appendonly.aof"} {
+		if err := load(name, testKeyring(t, "k1:a")); !errors.Is(err, ErrNotEncrypted) {
+			t.Errorf("%s: expected ErrNotEncrypted, got %v", name, err)
+		}
+		keyring := testKeyring(t, "k1:a")
+		keyring.AllowPlaintext(true)
+		if err := load(name, keyring); err != nil {
+			t.Errorf("%s: expected plaintext to load once allowed, got %v", name, err)
+		}
+		if err := load(name, nil); err != nil {
+			t.Errorf("%s: expected plaintext to load without a keyring, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadKeyringSelectsCurrentKey(t *testing.T) {
+	keys := testKeyring(t, "k1:a", "k2:b")
+	if keys.CurrentID() != "k2" {
+		t.Errorf("expected the last key to be current, got %q", keys.CurrentID())
+	}
+
+	filename := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(filename, []byte("# keys\nk1:"+base64.StdEncoding.EncodeToString(make([]byte, 16))+"\n"), 0o600)
+	keyring, err := LoadKeyring("k0:"+base64.StdEncoding.EncodeToString(make([]byte, 32)), filename, "k0")
+	if err != nil || keyring.CurrentID() != "k0" {
+		t.Fatalf("expected k0 to be current, got %v (%v)", keyring, err)
+	}
+	if _, err := LoadKeyring("k1:"+base64.StdEncoding.EncodeToString(make([]byte, 7)), "", ""); err == nil {
+		t.Error("expected a key of invalid length to be rejected")
+	}
+	if keyring, err := LoadKeyring("", "", ""); keyring != nil || err != nil {
+		t.Errorf("expected no keyring without keys, got %v (%v)", keyring, err)
+	}
+}
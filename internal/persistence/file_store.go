@@ -1,36 +1,220 @@
 package persistence
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 var saveMutex sync.Mutex
 
-// SaveToFile saves the in-memory store to a JSON file.
-func SaveToFile(filename string, data interface{}) error {
+// snapshotFormat identifies the header line of a snapshot file
+const snapshotFormat = "memory-store-snapshot"
+
+// SnapshotVersion is the version of the snapshot format written by SaveToFile. Version 1
+// snapshots hold plain JSON values; version 2 snapshots hold SnapshotEntry values.
+const SnapshotVersion = 2
+
+// ErrCorruptSnapshot is returned by LoadFromFile when a snapshot fails verification
+var ErrCorruptSnapshot = errors.New("snapshot file is corrupt")
+
+// SnapshotEntry is a key as saved in a snapshot, with its value in the tagged encoding
+type SnapshotEntry struct {
+	Value      TaggedValue `json:"value"`
+	Expiration int64       `json:"expiration,omitempty"` // Unix milliseconds; 0 means the key does not expire
+}
+
+// SnapshotHeader is the first line of a snapshot file. The data follows on the next
+// line; Size and Checksum cover exactly those bytes.
+type SnapshotHeader struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Keys     int       `json:"keys"`
+	Size     int       `json:"size"`
+	Checksum uint32    `json:"checksum"` // CRC-32C of the data
+
+	// KeyID names the key the data is encrypted with, and KeyCheck verifies it; both
+	// are empty for unencrypted snapshots. Size and Checksum cover the encrypted data.
+	KeyID    string `json:"key_id,omitempty"`
+	KeyCheck []byte `json:"key_check,omitempty"`
+}
+
+// SaveToFile saves the in-memory store to a JSON file. keys is the number of entries in
+// data, recorded in the header. The snapshot is written to a temporary file that is
+// synced and renamed over filename, so a crash leaves either the old or the new
+// snapshot, never a partial one. With a keyring the data is encrypted with its current key.
+func SaveToFile(filename string, data interface{}, keys int, keyring *Keyring) error {
 	saveMutex.Lock()
 	defer saveMutex.Unlock()
 
-	file, err := os.Create(filename)
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return writeJSONSnapshot(filename, SnapshotVersion, keys, body, keyring)
+}
+
+// writeJSONSnapshot writes body under a header, encrypting it when keyring is set.
+func writeJSONSnapshot(filename string, version, keys int, body []byte, keyring *Keyring) error {
+	meta := SnapshotHeader{
+		Format:  snapshotFormat,
+		Version: version,
+		Created: time.Now().UTC(),
+		Keys:    keys,
+	}
+	if aead, id, check := keyring.currentCipher(); aead != nil {
+		var sealed bytes.Buffer
+		w, err := newSealWriter(&sealed, aead)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		body = sealed.Bytes()
+		meta.KeyID, meta.KeyCheck = id, check
+	}
+	meta.Size = len(body)
+	meta.Checksum = crc32.Checksum(body, crcTable)
+	header, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(filename, func(file *os.File) error {
+		for _, part := range [][]byte{header, {'\n'}, body, {'\n'}} {
+			if _, err := file.Write(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeAtomic writes a temporary file next to filename with write, syncs it and renames
+// it over filename. The temporary file is removed when anything fails.
+func writeAtomic(filename string, write func(file *os.File) error) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, base+".tmp-*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), filename); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// LoadFromFile loads the data from a JSON file to the in-memory store and returns the
+// version of the snapshot format, which tells how the data is encoded. Snapshots with a
+// header are verified first and ErrCorruptSnapshot is returned when their data does not
+// match it. Files written before headers were introduced hold the data alone; they are
+// loaded without verification and reported as version 0. Encrypted snapshots need the
+// key named in their header: ErrNoKey, ErrUnknownKey or ErrWrongKey is returned when
+// keyring does not have it. Unencrypted snapshots return ErrNotEncrypted when keyring is
+// set and does not allow plaintext.
+func LoadFromFile(filename string, data interface{}, keyring *Keyring) (int, error) {
+	header, body, err := readJSONSnapshot(filename, keyring)
+	if err != nil {
+		return header.Version, err
+	}
+	return header.Version, json.Unmarshal(body, data)
+}
+
+// readJSONSnapshot verifies a JSON snapshot and returns its header and decrypted data.
+func readJSONSnapshot(filename string, keyring *Keyring) (SnapshotHeader, []byte, error) {
+	var header SnapshotHeader
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return header, nil, err
+	}
+
+	line, body, _ := bytes.Cut(content, []byte{'\n'})
+	if json.Unmarshal(line, &header) != nil || header.Format != snapshotFormat {
+		return SnapshotHeader{}, content, keyring.checkPlaintext(filename)
+	}
+	if header.Version < 1 || header.Version > SnapshotVersion {
+		return header, nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	if len(body) != header.Size+1 || body[header.Size] != '\n' {
+		return header, nil, fmt.Errorf("%w: %s holds %d bytes of data, header says %d", ErrCorruptSnapshot, filename, len(body)-1, header.Size)
+	}
+	body = body[:header.Size]
+	if sum := crc32.Checksum(body, crcTable); sum != header.Checksum {
+		return header, nil, fmt.Errorf("%w: %s has checksum %08x, header says %08x", ErrCorruptSnapshot, filename, sum, header.Checksum)
+	}
+	if header.KeyID == "" {
+		return header, body, keyring.checkPlaintext(filename)
+	}
 
-	encoder := json.NewEncoder(file)
-	return encoder.Encode(data)
+	aead, err := keyring.cipher(header.KeyID, header.KeyCheck)
+	if err != nil {
+		return header, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	r, err := newOpenReader(bytes.NewReader(body), aead)
+	if err == nil {
+		body, err = io.ReadAll(r)
+	}
+	if err != nil {
+		return header, nil, fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, filename, err)
+	}
+	return header, body, nil
 }
 
-// LoadFromFile loads the data from a JSON file to the in-memory store.
-func LoadFromFile(filename string, data interface{}) error {
-	file, err := os.Open(filename)
+// ReencryptSnapshot rewrites the snapshot at filename, JSON or binary, with the current
+// key of keyring. It decrypts the snapshot with whichever key of keyring it was written
+// with, or reads it as is when it is not encrypted yet; the data itself is copied
+// without being decoded.
+func ReencryptSnapshot(filename string, keyring *Keyring) error {
+	if keyring == nil {
+		return errors.New("no encryption key is configured")
+	}
+	format, err := SnapshotFormatOf(filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
-	return decoder.Decode(data)
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
+	if format == FormatBinary {
+		return reencryptBinarySnapshot(filename, keyring)
+	}
+	header, body, err := readJSONSnapshot(filename, keyring.migrating())
+	if err != nil {
+		return err
+	}
+	if header.Format == "" {
+		return fmt.Errorf("%s has no snapshot header; load and save it once before encrypting it", filename)
+	}
+	return writeJSONSnapshot(filename, header.Version, header.Keys, body, keyring)
 }
@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testEntries returns snapshot entries holding a string and an expiring integer
+func testEntries(t *testing.T) map[string]SnapshotEntry {
+	t.Helper()
+	entries := make(map[string]SnapshotEntry)
+	for key, value := range map[string]interface{}{"greeting": "hello", "counter": int64(1) << 60} {
+		tagged, err := EncodeValue(value)
+		if err != nil {
+			t.Fatalf("EncodeValue failed: %v", err)
+		}
+		entries[key] = SnapshotEntry{Value: tagged}
+	}
+	counter := entries["counter"]
+	counter.Expiration = 1700000000000
+	entries["counter"] = counter
+	return entries
+}
+
+func TestSnapshotHasHeaderAndLeavesNoTempFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.json")
+	entries := testEntries(t)
+	for i := 0; i < 2; i++ {
+		if err := SaveToFile(filename, entries, len(entries), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	content, _ := os.ReadFile(filename)
+	line, _, _ := bytes.Cut(content, []byte{'\n'})
+	var header SnapshotHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.Format != snapshotFormat || header.Version != SnapshotVersion || header.Keys != 2 {
+		t.Errorf("unexpected header %+v (%v)", header, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected only the snapshot in %s, got %d files", dir, len(files))
+	}
+
+	var loaded map[string]SnapshotEntry
+	version, err := LoadFromFile(filename, &loaded, nil)
+	if err != nil || version != SnapshotVersion {
+		t.Fatalf("expected a version %d snapshot, got %d (%v)", SnapshotVersion, version, err)
+	}
+	if !reflect.DeepEqual(loaded, entries) {
+		t.Errorf("expected %v, got %v", entries, loaded)
+	}
+}
+
+func TestSnapshotRejectsCorruptFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	entries := testEntries(t)
+	SaveToFile(filename, entries, len(entries), nil)
+	content, _ := os.ReadFile(filename)
+
+	for name, data := range map[string][]byte{
+		"changed":   bytes.Replace(content, []byte("hello"), []byte("jello"), 1),
+		"truncated": content[:len(content)-10],
+	} {
+		os.WriteFile(filename, data, 0o644)
+		var loaded map[string]SnapshotEntry
+		if _, err := LoadFromFile(filename, &loaded, nil); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("%s: expected ErrCorruptSnapshot, got %v", name, err)
+		}
+	}
+}
+
+func TestSnapshotLoadsFileWithoutHeader(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(filename, []byte(`{"greeting":{"value":"hello","expiration":0}}`+"\n"), 0o644)
+
+	var loaded map[string]map[string]interface{}
+	version, err := LoadFromFile(filename, &loaded, nil)
+	if err != nil || version != 0 {
+		t.Fatalf("expected a version 0 snapshot, got %d (%v)", version, err)
+	}
+	if loaded["greeting"]["value"] != "hello" {
+		t.Errorf("expected hello, got %v", loaded["greeting"])
+	}
+}